@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+
+	dto_admin "firstpersoncode/go-uploader/dto/admin"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrOwnAccount refuses an admin changing their own status or role, so
+	// the last admin can't lock everyone out.
+	ErrOwnAccount = errors.New("you cannot change your own account")
+)
+
+type AdminService interface {
+	ListUsers(pagination dto_transaction.PaginationDTO) (*dto_admin.UserListResponseDTO, error)
+	GetUser(userID string) (*dto_admin.AdminUserDTO, error)
+	SetUserDisabled(actorID string, userID string, disabled bool) error
+	SetUserRole(actorID string, userID string, role Role) error
+	RevokeUserSessions(userID string) error
+	GetUserTransactions(userID string, pagination dto_transaction.PaginationDTO) (*dto_admin.UserTransactionsResponseDTO, error)
+	GetUserBalance(userID string) (*dto_transaction.BalanceResponseDTO, error)
+}
+
+type AdminHandler interface {
+	ListUsers(ctx *fiber.Ctx) error
+	GetUser(ctx *fiber.Ctx) error
+	DisableUser(ctx *fiber.Ctx) error
+	EnableUser(ctx *fiber.Ctx) error
+	UpdateRole(ctx *fiber.Ctx) error
+	RevokeSessions(ctx *fiber.Ctx) error
+	GetUserTransactions(ctx *fiber.Ctx) error
+	GetUserBalance(ctx *fiber.Ctx) error
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+
+	dto_apikey "firstpersoncode/go-uploader/dto/apikey"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"
+	ScopeUpload Scope = "upload"
+	// ScopeWrite covers changing and deleting what is stored: transactions,
+	// uploads and import profiles. An upload key can't undo earlier imports
+	// without it.
+	ScopeWrite Scope = "write"
+	// ScopeAccount covers session and credential management. It is never
+	// granted to API keys, so a leaked key cannot be used to mint new ones.
+	ScopeAccount Scope = "account"
+)
+
+// APIKeyPrefix marks raw API keys so they can be told apart from JWTs in an
+// Authorization header.
+const APIKeyPrefix = "gu_"
+
+var GrantableAPIKeyScopes = []Scope{ScopeRead, ScopeUpload, ScopeWrite}
+
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []Scope
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	Revoked    bool
+}
+
+type APIKeyRepository interface {
+	Save(key *APIKey) (*APIKey, error)
+	Update(key *APIKey) (*APIKey, error)
+	FindByID(id string) (*APIKey, error)
+	FindByHash(hash string) (*APIKey, error)
+	FindByUserID(userID string) ([]*APIKey, error)
+}
+
+type APIKeyService interface {
+	CreateAPIKey(userID string, request *dto_apikey.CreateAPIKeyRequestDTO) (*dto_apikey.CreateAPIKeyResponseDTO, error)
+	ListAPIKeys(userID string) ([]dto_apikey.APIKeyDTO, error)
+	RevokeAPIKey(userID string, keyID string) error
+}
+
+type APIKeyHandler interface {
+	Create(ctx *fiber.Ctx) error
+	List(ctx *fiber.Ctx) error
+	Revoke(ctx *fiber.Ctx) error
+}
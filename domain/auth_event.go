@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+type AuthEventType string
+
+const (
+	AuthEventSignInSuccess   AuthEventType = "SIGNIN_SUCCESS"
+	AuthEventSignInFailure   AuthEventType = "SIGNIN_FAILURE"
+	AuthEventSignInThrottled AuthEventType = "SIGNIN_THROTTLED"
+	AuthEventAccountLocked   AuthEventType = "ACCOUNT_LOCKED"
+
+	AuthEventPasswordChanged        AuthEventType = "PASSWORD_CHANGED"
+	AuthEventPasswordResetRequested AuthEventType = "PASSWORD_RESET_REQUESTED"
+	AuthEventPasswordReset          AuthEventType = "PASSWORD_RESET"
+)
+
+type AuthEvent struct {
+	ID        string
+	Type      AuthEventType
+	UserID    string
+	Username  string
+	IP        string
+	UserAgent string
+	Reason    string
+	CreatedAt time.Time
+}
+
+type AuthEventRepository interface {
+	Save(event *AuthEvent) (*AuthEvent, error)
+	FindByUsername(username string) ([]*AuthEvent, error)
+}
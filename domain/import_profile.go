@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrImportProfileNotFound = errors.New("import profile not found")
+	// ErrImportProfileNameTaken is returned for a profile named like another
+	// of the user's profiles.
+	ErrImportProfileNameTaken = errors.New("import profile name is already taken")
+	// ErrInvalidImportProfile is returned for a profile whose settings don't
+	// describe a usable layout.
+	ErrInvalidImportProfile = errors.New("invalid import profile")
+)
+
+// How an import profile tells debits from credits: by the type field, or by
+// the sign of the amount, with negative amounts being debits or credits.
+const (
+	SignConventionType           = "type"
+	SignConventionNegativeDebit  = "negative_debit"
+	SignConventionNegativeCredit = "negative_credit"
+)
+
+// ImportProfile describes the layout of one kind of statement file, so it
+// can be uploaded as it was exported.
+type ImportProfile struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// Delimiter and Quote are single characters, "," and `"` when empty.
+	Delimiter string `json:"delimiter"`
+	Quote     string `json:"quote"`
+	// SkipRows is the number of lines before the header or the first row.
+	SkipRows int `json:"skip_rows"`
+	// Columns maps transaction fields to the column they are read from,
+	// either a header name or a 1-based column number.
+	Columns map[string]string `json:"columns"`
+	// Defaults holds the value of a field that has no column, or whose
+	// column is empty.
+	Defaults map[string]string `json:"defaults"`
+	// DateLayout is a Go reference layout such as "02/01/2006 15:04". When
+	// empty, timestamps are Unix seconds or RFC 3339. Times without a zone
+	// are in TimeZone, an IANA name, or UTC when empty.
+	DateLayout string `json:"date_layout"`
+	TimeZone   string `json:"time_zone"`
+	// DecimalSeparator is "." when empty. Without a ThousandsSeparator,
+	// amounts can't have one.
+	DecimalSeparator   string `json:"decimal_separator"`
+	ThousandsSeparator string `json:"thousands_separator"`
+	// DecimalPlaces is how many the stored amount keeps: with 2, "12.34" is
+	// stored as 1234.
+	DecimalPlaces int `json:"decimal_places"`
+	// SignConvention is one of the SignConvention constants. When empty, it
+	// is SignConventionType if the type field has a column or a default,
+	// and SignConventionNegativeDebit otherwise.
+	SignConvention string    `json:"sign_convention"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ImportProfileRepository interface {
+	// Save assigns the profile a new ID. Save and Update fail with
+	// ErrImportProfileNameTaken if another of the user's profiles has the
+	// same name.
+	Save(profile *ImportProfile) (*ImportProfile, error)
+	Update(profile *ImportProfile) (*ImportProfile, error)
+	Delete(id string) error
+	FindByID(id string) (*ImportProfile, error)
+	// FindByUserID returns the user's profiles ordered by name.
+	FindByUserID(userID string) ([]*ImportProfile, error)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type LoginAttempt struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+type LoginAttemptRepository interface {
+	FindByKey(key string) (*LoginAttempt, error)
+	Save(attempt *LoginAttempt) error
+	Delete(key string) error
+}
+
+// LockoutError is returned when sign-in is refused because of earlier failed
+// attempts, before the password is even checked.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed sign-in attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+type PasswordResetToken struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Used      bool
+}
+
+type PasswordResetRepository interface {
+	Save(token *PasswordResetToken) (*PasswordResetToken, error)
+	Update(token *PasswordResetToken) (*PasswordResetToken, error)
+	FindByHash(hash string) (*PasswordResetToken, error)
+	DeleteByUserID(userID string) error
+}
+
+// Notifier delivers out-of-band messages to users, e.g. by email. Only a log
+// and a file based implementation exist for now.
+type Notifier interface {
+	SendPasswordReset(user *User, token string, expiresAt time.Time) error
+}
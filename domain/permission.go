@@ -0,0 +1,47 @@
+package domain
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionUsersRead        Permission = "users:read"
+	PermissionUsersManage      Permission = "users:manage"
+	PermissionSessionsRevoke   Permission = "sessions:revoke"
+	PermissionTransactionsRead Permission = "transactions:read_any"
+)
+
+var RolePermissions = map[Role][]Permission{
+	RoleUser: {},
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersManage,
+		PermissionSessionsRevoke,
+		PermissionTransactionsRead,
+	},
+}
+
+func (r Role) IsValid() bool {
+	_, exists := RolePermissions[r]
+	return exists
+}
+
+func (u *User) HasPermission(permission Permission) bool {
+	role := u.Role
+	if role == "" {
+		role = RoleUser
+	}
+
+	for _, granted := range RolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
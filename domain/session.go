@@ -1,33 +1,99 @@
 package domain
 
 import (
+	"errors"
+	"time"
+
 	dto_session "firstpersoncode/go-uploader/dto/session"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// ErrInvalidRefreshToken is returned for a refresh token that belongs to no
+// session, or to none it can still be used for.
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
 type Session struct {
-	ID           string
-	UserID       string
-	RefreshToken string
+	ID            string
+	UserID        string
+	FamilyID      string
+	RefreshToken  string
+	RefreshExpiry time.Time
+	Revoked       bool
+	CreatedAt     time.Time
+	LastSeenAt    time.Time
+	IP            string
+	UserAgent     string
+	// Set only on sessions built for API key requests; never persisted.
+	APIKeyID string
+	Scopes   []Scope
+}
+
+// HasScope reports whether the session may perform actions in scope. Signed-in
+// users can do everything, API key sessions only what the key was granted.
+func (s *Session) HasScope(scope Scope) bool {
+	if s.APIKeyID == "" {
+		return true
+	}
+
+	for _, granted := range s.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
 }
 
 type SessionRepository interface {
 	Save(session *Session) (*Session, error)
+	Update(session *Session) (*Session, error)
+	// Touch records client activity without writing anything else, so it
+	// can't undo a revocation made since the session was read.
+	Touch(id string, lastSeenAt time.Time, ip string, userAgent string) error
 	FindByID(id string) (*Session, error)
+	FindByRefreshToken(refreshToken string) (*Session, error)
+	FindByUserID(userID string) ([]*Session, error)
+	RevokeFamily(familyID string) error
+	Delete(id string) error
+	DeleteByUserID(userID string) error
 }
 
 type SessionService interface {
 	RegisterUser(credentials *dto_session.TokenRequestDTO) error
 	CreateSession(credentials *dto_session.TokenRequestDTO) (*dto_session.TokenResponseDTO, error)
+	CompleteMFASignIn(request *dto_session.MFASignInRequestDTO) (*dto_session.TokenResponseDTO, error)
+	SetupMFA(userID string) (*dto_session.MFASetupResponseDTO, error)
+	VerifyMFA(userID string, code string) (*dto_session.MFAVerifyResponseDTO, error)
 	GetUserSession(session *Session) (*dto_session.UserSessionDto, error)
 	RefreshToken(refreshToken string) (*dto_session.TokenResponseDTO, error)
+	RevokeSession(session *Session) error
+	RevokeAllSessions(userID string) error
+	// SignOutRefreshToken signs out the refresh token's session, or every
+	// session of its user, without needing a valid access token.
+	SignOutRefreshToken(refreshToken string, everywhere bool) error
+	ListSessions(current *Session) ([]dto_session.SessionDTO, error)
+	RevokeUserSession(userID string, sessionID string) error
+	GetJWKS() (*dto_session.JWKSDTO, error)
+	ChangePassword(session *Session, request *dto_session.PasswordChangeRequestDTO) error
+	RequestPasswordReset(request *dto_session.PasswordResetRequestDTO) error
+	ConfirmPasswordReset(request *dto_session.PasswordResetConfirmDTO) error
 }
 
 type SessionHandler interface {
 	SignUp(ctx *fiber.Ctx) error
 	SignIn(ctx *fiber.Ctx) error
+	SignInMFA(ctx *fiber.Ctx) error
+	SetupMFA(ctx *fiber.Ctx) error
+	VerifyMFA(ctx *fiber.Ctx) error
 	SignOut(ctx *fiber.Ctx) error
+	SignOutAll(ctx *fiber.Ctx) error
 	Session(ctx *fiber.Ctx) error
 	Refresh(ctx *fiber.Ctx) error
+	ListSessions(ctx *fiber.Ctx) error
+	DeleteSession(ctx *fiber.Ctx) error
+	JWKS(ctx *fiber.Ctx) error
+	ChangePassword(ctx *fiber.Ctx) error
+	RequestPasswordReset(ctx *fiber.Ctx) error
+	ConfirmPasswordReset(ctx *fiber.Ctx) error
 }
@@ -1,8 +1,13 @@
 package domain
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +20,10 @@ const (
 	TransactionTypeCredit TransactionType = "CREDIT"
 )
 
+func (t TransactionType) IsValid() bool {
+	return t == TransactionTypeDebit || t == TransactionTypeCredit
+}
+
 type TransactionStatus string
 
 const (
@@ -23,7 +32,12 @@ const (
 	TransactionStatusPending TransactionStatus = "PENDING"
 )
 
+func (s TransactionStatus) IsValid() bool {
+	return s == TransactionStatusSuccess || s == TransactionStatusFailed || s == TransactionStatusPending
+}
+
 type Transaction struct {
+	ID          string            `json:"id"`
 	Timestamp   time.Time         `json:"timestamp"`
 	Name        string            `json:"name"`
 	Type        TransactionType   `json:"type"`
@@ -31,24 +45,168 @@ type Transaction struct {
 	Status      TransactionStatus `json:"status"`
 	Description string            `json:"description"`
 	UserID      string            `json:"user_id"`
+	// BatchID is the upload the transaction came from, if any.
+	BatchID string `json:"batch_id"`
+	// ExternalID is the bank's own ID for the transaction, such as an OFX
+	// FITID, when the statement gives one. It is only unique within Account,
+	// the account the statement was for, when it says.
+	ExternalID string `json:"external_id"`
+	Account    string `json:"account"`
+}
+
+// Fingerprint identifies the transaction by what a statement says about it,
+// so the same row in two overlapping statements can be recognised. A bank's
+// ID for the transaction identifies it on its own within the account, and
+// the same row in two accounts' statements is two transactions. Parsers
+// leave a pending entry without the bank's ID, or the same entry once
+// booked, under that ID, would be taken for a duplicate of it.
+func (t *Transaction) Fingerprint() string {
+	if t.ExternalID != "" {
+		hash := sha256.Sum256([]byte("external\x1f" + t.Account + "\x1f" + t.ExternalID))
+		return hex.EncodeToString(hash[:16])
+	}
+
+	content := fmt.Appendf(nil, "%d\x1f%s\x1f%s\x1f%d\x1f%s", t.Timestamp.Unix(), t.Name, t.Type, t.Amount, t.Description)
+	if t.Account != "" {
+		content = append([]byte(t.Account+"\x1f"), content...)
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:16])
+}
+
+// Validate checks the rules every stored transaction follows, whatever it
+// was parsed from.
+func (t *Transaction) Validate() error {
+	switch {
+	case t.Timestamp.IsZero():
+		return fmt.Errorf("%w: timestamp is required", ErrInvalidTransaction)
+	case strings.TrimSpace(t.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidTransaction)
+	case strings.TrimSpace(t.Description) == "":
+		return fmt.Errorf("%w: description is required", ErrInvalidTransaction)
+	case !t.Type.IsValid():
+		return fmt.Errorf("%w: type must be DEBIT or CREDIT", ErrInvalidTransaction)
+	case !t.Status.IsValid():
+		return fmt.Errorf("%w: status must be SUCCESS, FAILED or PENDING", ErrInvalidTransaction)
+	case t.Timestamp.Unix() <= 0:
+		return fmt.Errorf("%w: timestamp must be after 1970-01-01", ErrInvalidTransaction)
+	case t.Amount < 0:
+		return fmt.Errorf("%w: amount must not be negative", ErrInvalidTransaction)
+	}
+
+	return nil
+}
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrInvalidTransaction is returned for a transaction breaking the rules
+	// Validate checks.
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrInvalidFilter      = errors.New("invalid filter")
+	// ErrInvalidCursor is returned for a pagination cursor that is malformed,
+	// was tampered with, or doesn't match the requested sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// TransactionFilter selects some of a user's transactions. Fields left at
+// their zero value don't filter. Text matches ignore ASCII case.
+type TransactionFilter struct {
+	UserID string
+	// From and To bound the timestamp, both inclusive.
+	From      time.Time
+	To        time.Time
+	Types     []TransactionType
+	Statuses  []TransactionStatus
+	MinAmount *int64
+	MaxAmount *int64
+	Name      string
+	// DescriptionTerms must all appear somewhere in the description.
+	DescriptionTerms []string
+}
+
+// IssuesFilter is the preset behind the issues listing: the user's failed
+// and pending transactions.
+func IssuesFilter(userID string) TransactionFilter {
+	return TransactionFilter{
+		UserID:   userID,
+		Statuses: []TransactionStatus{TransactionStatusFailed, TransactionStatusPending},
+	}
+}
+
+// TransactionCursor selects a keyset page next to Anchor, a row identified
+// by its ID and its values for the sort keys: the rows that sort right
+// after it, or right before it when Backward is set.
+type TransactionCursor struct {
+	Anchor   Transaction
+	Backward bool
 }
 
 type TransactionRepository interface {
+	// SaveAll assigns every transaction a new ID.
 	SaveAll(transactions []Transaction) error
-	GetAll() []Transaction
-	GetAllByUserID(userID string) []Transaction
-	GetAllIssues(userID string, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO) ([]Transaction, int, error)
-	Clear()
+	FindByID(id string) (*Transaction, error)
+	// Update replaces the stored transaction with the same ID. Its owner
+	// can't be changed.
+	Update(transaction *Transaction) (*Transaction, error)
+	Delete(id string) error
+	// DeleteByBatchID deletes every transaction from the upload and returns
+	// how many there were.
+	DeleteByBatchID(batchID string) (int, error)
+	// CountFingerprints returns how many of the user's stored transactions
+	// share each fingerprint found among transactions.
+	CountFingerprints(userID string, transactions []Transaction) (map[string]int, error)
+	GetAll() ([]Transaction, error)
+	GetAllByUserID(userID string) ([]Transaction, error)
+	// Search returns a page of the transactions matching filter, ordered by
+	// sorting and then ID, along with how many match in total. With a
+	// cursor, pagination.Page is ignored.
+	Search(filter TransactionFilter, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, cursor *TransactionCursor) ([]Transaction, int, error)
+	Clear() error
 }
 
 type TransactionService interface {
-	ParseAndStoreCSV(fileContent io.Reader, userID string) (*dto_transaction.UploadResponseDTO, error)
+	// ParseAndStoreStatement stores the transactions of a CSV, OFX, MT940 or
+	// camt.053 statement, told apart by its content, as one upload batch.
+	// When it fails with ErrInvalidRows, the response still reports the bad
+	// rows.
+	ParseAndStoreStatement(fileContent io.Reader, upload dto_transaction.UploadRequestDTO, userID string) (*dto_transaction.UploadResponseDTO, error)
 	CalculateBalance(userID string) (*dto_transaction.BalanceResponseDTO, error)
 	GetIssues(pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, userID string) (*dto_transaction.IssuesResponseDTO, error)
+	SearchTransactions(filter dto_transaction.TransactionFilterDTO, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, userID string) (*dto_transaction.TransactionListResponseDTO, error)
+	// GetTransaction, UpdateTransaction and DeleteTransaction only see the
+	// user's own transactions; anyone else's are reported as not found.
+	GetTransaction(id string, userID string) (*dto_transaction.TransactionDTO, error)
+	UpdateTransaction(id string, request dto_transaction.UpdateTransactionRequestDTO, userID string) (*dto_transaction.TransactionDTO, error)
+	DeleteTransaction(id string, userID string) error
+	// ListUploads, GetUpload and DeleteUpload likewise only see the user's
+	// own uploads. DeleteUpload rolls a completed upload back by deleting
+	// the transactions it stored.
+	ListUploads(pagination dto_transaction.PaginationDTO, userID string) (*dto_transaction.UploadListResponseDTO, error)
+	GetUpload(id string, userID string) (*dto_transaction.UploadDTO, error)
+	DeleteUpload(id string, userID string) (*dto_transaction.DeleteUploadResponseDTO, error)
+	// The import profile methods likewise only see the user's own profiles.
+	// Profiles are checked when saved, so they can always be used.
+	ListImportProfiles(userID string) ([]dto_transaction.ImportProfileDTO, error)
+	GetImportProfile(id string, userID string) (*dto_transaction.ImportProfileDTO, error)
+	CreateImportProfile(request dto_transaction.ImportProfileRequestDTO, userID string) (*dto_transaction.ImportProfileDTO, error)
+	UpdateImportProfile(id string, request dto_transaction.ImportProfileRequestDTO, userID string) (*dto_transaction.ImportProfileDTO, error)
+	DeleteImportProfile(id string, userID string) error
 }
 
 type TransactionHandler interface {
 	UploadStatement(ctx *fiber.Ctx) error
 	GetBalance(ctx *fiber.Ctx) error
 	GetIssues(ctx *fiber.Ctx) error
+	SearchTransactions(ctx *fiber.Ctx) error
+	GetTransaction(ctx *fiber.Ctx) error
+	UpdateTransaction(ctx *fiber.Ctx) error
+	DeleteTransaction(ctx *fiber.Ctx) error
+	ListUploads(ctx *fiber.Ctx) error
+	GetUpload(ctx *fiber.Ctx) error
+	DeleteUpload(ctx *fiber.Ctx) error
+	ListImportProfiles(ctx *fiber.Ctx) error
+	GetImportProfile(ctx *fiber.Ctx) error
+	CreateImportProfile(ctx *fiber.Ctx) error
+	UpdateImportProfile(ctx *fiber.Ctx) error
+	DeleteImportProfile(ctx *fiber.Ctx) error
 }
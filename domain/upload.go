@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type UploadStatus string
+
+const (
+	UploadStatusProcessing UploadStatus = "PROCESSING"
+	UploadStatusCompleted  UploadStatus = "COMPLETED"
+	UploadStatusFailed     UploadStatus = "FAILED"
+	UploadStatusRolledBack UploadStatus = "ROLLED_BACK"
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrInvalidRows fails an upload because of rows that couldn't be
+	// parsed.
+	ErrInvalidRows = errors.New("invalid rows")
+	// ErrInvalidStatement fails an upload of a file that isn't a statement
+	// it can read, as opposed to one that couldn't be read at all.
+	ErrInvalidStatement = errors.New("invalid statement")
+	// ErrDuplicateUpload rejects a statement, or some of its rows, that was
+	// uploaded before.
+	ErrDuplicateUpload = errors.New("duplicate upload")
+	// ErrIdempotencyKeyConflict is returned for an Idempotency-Key that is
+	// already taken by another upload.
+	ErrIdempotencyKeyConflict = errors.New("idempotency key conflict")
+	// ErrUploadNotCompleted refuses to roll back an upload that has nothing
+	// stored to roll back.
+	ErrUploadNotCompleted = errors.New("only completed uploads can be rolled back")
+)
+
+// UploadBatch records one statement upload. Every transaction it stored
+// carries its ID, so the upload can be rolled back as a whole.
+type UploadBatch struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	Filename string `json:"filename"`
+	// Checksum is the hex SHA-256 of the uploaded file.
+	Checksum   string       `json:"checksum"`
+	RowCount   int          `json:"row_count"`
+	UploadedAt time.Time    `json:"uploaded_at"`
+	Status     UploadStatus `json:"status"`
+	// IdempotencyKey is the client's key for the request that made the
+	// upload. Only uploads in progress or completed keep it.
+	IdempotencyKey string `json:"idempotency_key"`
+	// SkippedRows counts the rows that weren't stored because they had been
+	// uploaded before, FailedRows the invalid ones. RowCount only counts the
+	// stored ones.
+	SkippedRows int `json:"skipped_rows"`
+	FailedRows  int `json:"failed_rows"`
+	// DuplicateOf is the earlier upload of the very same file, if any.
+	DuplicateOf string `json:"duplicate_of"`
+	// Balances are the balances the statement states, by account, for
+	// formats that have them.
+	Balances []StatementBalance `json:"balances"`
+}
+
+// StatementBalance is what a statement states about one account's balance,
+// in cents. Booked is the sum of the statement's booked entries for the
+// account, credits less debits.
+type StatementBalance struct {
+	Account string `json:"account"`
+	Opening *int64 `json:"opening"`
+	Closing *int64 `json:"closing"`
+	Booked  int64  `json:"booked"`
+}
+
+// Mismatched reports whether the opening balance and the booked entries
+// don't add up to the closing balance, which can only be told when both
+// balances are stated.
+func (b StatementBalance) Mismatched() bool {
+	return b.Opening != nil && b.Closing != nil && *b.Opening+b.Booked != *b.Closing
+}
+
+type UploadBatchRepository interface {
+	// Save assigns the batch a new ID. Save and Update fail with
+	// ErrIdempotencyKeyConflict if another of the user's batches has the
+	// same idempotency key.
+	Save(batch *UploadBatch) (*UploadBatch, error)
+	Update(batch *UploadBatch) (*UploadBatch, error)
+	// RollBack marks the batch rolled back and deletes the transactions it
+	// stored, in one operation, and returns how many there were.
+	RollBack(id string) (int, error)
+	FindByID(id string) (*UploadBatch, error)
+	FindByIdempotencyKey(userID string, key string) (*UploadBatch, error)
+	// FindByChecksum returns the user's batches of files with the checksum,
+	// newest first.
+	FindByChecksum(userID string, checksum string) ([]*UploadBatch, error)
+	// FindByUserID returns a page of the user's batches, newest first, and
+	// how many there are in total.
+	FindByUserID(userID string, page int, limit int) ([]*UploadBatch, int, error)
+}
@@ -1,13 +1,27 @@
 package domain
 
+import "errors"
+
+var ErrUserNotFound = errors.New("user not found")
+
 type User struct {
 	ID       string
 	Username string
 	Password string
+	Role     Role
+	Disabled bool
+	// MFASecret is set by MFA setup but only enforced once MFAEnabled is
+	// switched on by a successful verification.
+	MFASecret        string
+	MFAEnabled       bool
+	MFALastUsedStep  int64
+	MFARecoveryCodes []string
 }
 
 type UserRepository interface {
 	Save(user *User) (*User, error)
+	Update(user *User) (*User, error)
 	FindByID(id string) (*User, error)
 	FindByUsername(username string) (*User, error)
+	FindAll(page int, limit int) ([]*User, int, error)
 }
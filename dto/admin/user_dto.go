@@ -0,0 +1,25 @@
+package dto_admin
+
+import dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+
+type AdminUserDTO struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	Disabled   bool   `json:"disabled"`
+	MFAEnabled bool   `json:"mfa_enabled"`
+}
+
+type UserListResponseDTO struct {
+	Users []AdminUserDTO `json:"users"`
+	Total int            `json:"total"`
+}
+
+type UpdateRoleRequestDTO struct {
+	Role string `json:"role"`
+}
+
+type UserTransactionsResponseDTO struct {
+	Transactions []dto_transaction.TransactionDTO `json:"transactions"`
+	Total        int                              `json:"total"`
+}
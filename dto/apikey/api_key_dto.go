@@ -0,0 +1,23 @@
+package dto_apikey
+
+import "time"
+
+type CreateAPIKeyRequestDTO struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type CreateAPIKeyResponseDTO struct {
+	APIKeyDTO
+	Key string `json:"key"`
+}
+
+type APIKeyDTO struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Revoked    bool      `json:"revoked"`
+}
@@ -14,6 +14,16 @@ func CreateSuccessResponse[T any](message string, data T) ResponseDTO[T] {
 	}
 }
 
+// CreateErrorResponseWithData is an error response that also carries details
+// of what went wrong.
+func CreateErrorResponseWithData[T any](message string, data T) ResponseDTO[T] {
+	return ResponseDTO[T]{
+		Status:  "error",
+		Message: message,
+		Data:    data,
+	}
+}
+
 func CreateErrorResponse(message string) ResponseDTO[any] {
 	return ResponseDTO[any]{
 		Status:  "error",
@@ -0,0 +1,21 @@
+package dto_session
+
+type MFASetupResponseDTO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type MFAVerifyRequestDTO struct {
+	Code string `json:"code"`
+}
+
+type MFAVerifyResponseDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFASignInRequestDTO struct {
+	MFAToken  string `json:"mfa_token"`
+	Code      string `json:"code"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
@@ -0,0 +1,15 @@
+package dto_session
+
+type PasswordChangeRequestDTO struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type PasswordResetRequestDTO struct {
+	Username string `json:"username"`
+}
+
+type PasswordResetConfirmDTO struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
@@ -0,0 +1,5 @@
+package dto_session
+
+type RefreshRequestDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -1,6 +1,8 @@
 package dto_session
 
 type TokenRequestDTO struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
 }
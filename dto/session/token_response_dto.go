@@ -3,6 +3,12 @@ package dto_session
 import "time"
 
 type TokenResponseDTO struct {
-	Token  string    `json:"token"`
-	Expiry time.Time `json:"expiry"`
+	Token         string    `json:"token,omitempty"`
+	Expiry        time.Time `json:"expiry"`
+	RefreshToken  string    `json:"refresh_token,omitempty"`
+	RefreshExpiry time.Time `json:"refresh_expiry,omitzero"`
+	// Set instead of the tokens above when the account has MFA enabled. The
+	// MFA token must be exchanged together with a code at /signin/mfa.
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
 }
@@ -3,6 +3,7 @@ package dto_session
 type UserDto struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 }
 
 type UserSessionDto struct {
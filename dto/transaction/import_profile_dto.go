@@ -0,0 +1,39 @@
+package dto_transaction
+
+import "time"
+
+// ImportProfileRequestDTO creates an import profile, or replaces one as a
+// whole.
+type ImportProfileRequestDTO struct {
+	Name      string            `json:"name"`
+	Delimiter string            `json:"delimiter"`
+	Quote     string            `json:"quote"`
+	SkipRows  int               `json:"skip_rows"`
+	Columns   map[string]string `json:"columns"`
+	Defaults  map[string]string `json:"defaults"`
+	// DateLayout is a Go reference layout, such as "02/01/2006 15:04".
+	DateLayout         string `json:"date_layout"`
+	TimeZone           string `json:"time_zone"`
+	DecimalSeparator   string `json:"decimal_separator"`
+	ThousandsSeparator string `json:"thousands_separator"`
+	DecimalPlaces      int    `json:"decimal_places"`
+	SignConvention     string `json:"sign_convention"`
+}
+
+type ImportProfileDTO struct {
+	ID                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Delimiter          string            `json:"delimiter"`
+	Quote              string            `json:"quote"`
+	SkipRows           int               `json:"skip_rows"`
+	Columns            map[string]string `json:"columns"`
+	Defaults           map[string]string `json:"defaults"`
+	DateLayout         string            `json:"date_layout"`
+	TimeZone           string            `json:"time_zone"`
+	DecimalSeparator   string            `json:"decimal_separator"`
+	ThousandsSeparator string            `json:"thousands_separator"`
+	DecimalPlaces      int               `json:"decimal_places"`
+	SignConvention     string            `json:"sign_convention"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+}
@@ -1,8 +1,18 @@
 package dto_transaction
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PaginationDTO selects a page either by number or, when Cursor is set, by
+// a next_cursor or prev_cursor token from a previous response. Page is
+// ignored in the latter case.
 type PaginationDTO struct {
-	Page  int `query:"page"`
-	Limit int `query:"limit"`
+	Page   int    `query:"page"`
+	Limit  int    `query:"limit"`
+	Cursor string `query:"cursor"`
 }
 
 type SortDirection string
@@ -12,7 +22,77 @@ const (
 	SortDesc SortDirection = "DESC"
 )
 
+// SortableFields are the transaction fields sortBy accepts. Every
+// repository must be able to order by each of them.
+var SortableFields = []string{"timestamp", "name", "type", "amount", "status"}
+
 type SortingDTO struct {
 	Sort   SortDirection `query:"sort"`
 	SortBy string        `query:"sortBy"`
 }
+
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Keys parses SortBy, a comma separated list of fields where a leading "-"
+// sorts that field in descending order, e.g. "status,-amount,timestamp".
+// Fields without a prefix follow Sort. An empty SortBy sorts by timestamp.
+func (s SortingDTO) Keys() ([]SortKey, error) {
+	var defaultDescending bool
+	switch SortDirection(strings.ToUpper(string(s.Sort))) {
+	case "", SortAsc:
+	case SortDesc:
+		defaultDescending = true
+	default:
+		return nil, fmt.Errorf("invalid sort direction: %s", s.Sort)
+	}
+
+	sortBy := s.SortBy
+	if strings.TrimSpace(sortBy) == "" {
+		sortBy = "timestamp"
+	}
+
+	var keys []SortKey
+	for _, field := range strings.Split(sortBy, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+
+		key := SortKey{Field: field, Descending: defaultDescending}
+		if strings.HasPrefix(field, "-") {
+			key = SortKey{Field: field[1:], Descending: true}
+		}
+
+		if !slices.Contains(SortableFields, key.Field) {
+			return nil, fmt.Errorf("invalid sortBy field: %s", field)
+		}
+
+		if slices.ContainsFunc(keys, func(existing SortKey) bool { return existing.Field == key.Field }) {
+			return nil, fmt.Errorf("duplicate sortBy field: %s", key.Field)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// FormatSortKeys is the inverse of Keys: it writes keys in the sortBy
+// syntax, with every direction spelled out by a prefix.
+func FormatSortKeys(keys []SortKey) string {
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Descending {
+			fields = append(fields, "-"+key.Field)
+		} else {
+			fields = append(fields, key.Field)
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+func (s SortingDTO) Validate() error {
+	_, err := s.Keys()
+	return err
+}
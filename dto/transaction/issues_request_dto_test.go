@@ -0,0 +1,61 @@
+package dto_transaction
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortingDTO_Keys(t *testing.T) {
+	cases := []struct {
+		sorting  SortingDTO
+		expected []SortKey
+	}{
+		{SortingDTO{}, []SortKey{{Field: "timestamp"}}},
+		{SortingDTO{Sort: "desc"}, []SortKey{{Field: "timestamp", Descending: true}}},
+		{SortingDTO{SortBy: "status,-amount,timestamp"}, []SortKey{{Field: "status"}, {Field: "amount", Descending: true}, {Field: "timestamp"}}},
+		{SortingDTO{Sort: SortDesc, SortBy: " Name , -Amount"}, []SortKey{{Field: "name", Descending: true}, {Field: "amount", Descending: true}}},
+	}
+
+	for _, c := range cases {
+		keys, err := c.sorting.Keys()
+		if err != nil {
+			t.Errorf("%+v: expected no error, got %v", c.sorting, err)
+			continue
+		}
+
+		if !slices.Equal(keys, c.expected) {
+			t.Errorf("%+v: expected %+v, got %+v", c.sorting, c.expected, keys)
+		}
+	}
+}
+
+func TestSortingDTO_Invalid(t *testing.T) {
+	for _, sorting := range []SortingDTO{
+		{SortBy: "user_id"},
+		{SortBy: "amount; DROP TABLE transactions"},
+		{SortBy: "amount,-amount"},
+		{SortBy: "amount,"},
+		{Sort: "sideways"},
+	} {
+		if err := sorting.Validate(); err == nil {
+			t.Errorf("%+v: expected a validation error", sorting)
+		}
+	}
+}
+
+func TestFormatSortKeys(t *testing.T) {
+	keys, err := SortingDTO{Sort: SortDesc, SortBy: "status, amount,-name"}.Keys()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	formatted := FormatSortKeys(keys)
+	if formatted != "-status,-amount,-name" {
+		t.Errorf("expected -status,-amount,-name, got %s", formatted)
+	}
+
+	parsed, err := SortingDTO{SortBy: formatted}.Keys()
+	if err != nil || !slices.Equal(parsed, keys) {
+		t.Errorf("expected %+v to round trip, got %+v, %v", keys, parsed, err)
+	}
+}
@@ -1,15 +1,29 @@
 package dto_transaction
 
-type IssuesResponseDTO struct {
+// TransactionListResponseDTO is a page of GET /transactions.
+type TransactionListResponseDTO struct {
 	Transactions []TransactionDTO `json:"transactions"`
 	Total        int              `json:"total"`
+	// NextCursor and PrevCursor are opaque tokens for the adjacent pages,
+	// passed back as the cursor query parameter. They are omitted at either
+	// end of the list.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
+// IssuesResponseDTO is a page of GET /issues, which lists transactions with
+// a preset filter.
+type IssuesResponseDTO = TransactionListResponseDTO
+
 type TransactionDTO struct {
+	ID          string `json:"id"`
 	Timestamp   string `json:"timestamp"`
 	Name        string `json:"name"`
 	Type        string `json:"type"`
 	Amount      int64  `json:"amount"`
 	Status      string `json:"status"`
 	Description string `json:"description"`
+	UploadID    string `json:"upload_id,omitempty"`
+	ExternalID  string `json:"external_id,omitempty"`
+	Account     string `json:"account,omitempty"`
 }
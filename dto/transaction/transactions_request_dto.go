@@ -0,0 +1,16 @@
+package dto_transaction
+
+// TransactionFilterDTO holds the GET /transactions filters as sent. Empty
+// fields don't filter. Type and Status take comma separated lists, From and
+// To an RFC 3339 time or a YYYY-MM-DD date, and Q words that must all appear
+// in the description.
+type TransactionFilterDTO struct {
+	From      string `query:"from"`
+	To        string `query:"to"`
+	Type      string `query:"type"`
+	Status    string `query:"status"`
+	MinAmount string `query:"minAmount"`
+	MaxAmount string `query:"maxAmount"`
+	Name      string `query:"name"`
+	Q         string `query:"q"`
+}
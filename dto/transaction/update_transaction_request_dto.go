@@ -0,0 +1,12 @@
+package dto_transaction
+
+// UpdateTransactionRequestDTO is a partial update: only the fields present
+// in the request body are changed. Timestamp is RFC 3339, as in responses.
+type UpdateTransactionRequestDTO struct {
+	Timestamp   *string `json:"timestamp"`
+	Name        *string `json:"name"`
+	Type        *string `json:"type"`
+	Amount      *int64  `json:"amount"`
+	Status      *string `json:"status"`
+	Description *string `json:"description"`
+}
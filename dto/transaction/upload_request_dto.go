@@ -0,0 +1,73 @@
+package dto_transaction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What to do with rows that were uploaded before: leave them out, refuse
+// the whole file, or store them again anyway.
+const (
+	OnDuplicateSkip   = "skip"
+	OnDuplicateReject = "reject"
+	OnDuplicateForce  = "force"
+)
+
+// What to do with invalid rows: fail the whole upload, or import the valid
+// rows without them.
+const (
+	OnErrorReject = "reject"
+	OnErrorSkip   = "skip"
+)
+
+// Text encodings a statement can be uploaded in. UTF-16 takes its byte order
+// from the BOM, and is little-endian without one.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16       = "utf-16"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+	EncodingISO88591    = "iso-8859-1"
+)
+
+// UploadRequestDTO describes a statement upload besides its content.
+type UploadRequestDTO struct {
+	Filename string
+	// IdempotencyKey comes from the Idempotency-Key header.
+	IdempotencyKey string
+	OnDuplicate    string `query:"onDuplicate"`
+	OnError        string `query:"onError"`
+	// Profile is the ID or name of the import profile describing the file.
+	// Without one, the file has the fixed six columns.
+	Profile string `query:"profile"`
+	// Encoding is one of the Encoding constants, in any case. Without one,
+	// it is detected.
+	Encoding string `query:"encoding"`
+}
+
+func (r UploadRequestDTO) Validate() error {
+	switch r.OnDuplicate {
+	case "", OnDuplicateSkip, OnDuplicateReject, OnDuplicateForce:
+	default:
+		return fmt.Errorf("invalid onDuplicate: %s", r.OnDuplicate)
+	}
+
+	switch r.OnError {
+	case "", OnErrorReject, OnErrorSkip:
+	default:
+		return fmt.Errorf("invalid onError: %s", r.OnError)
+	}
+
+	switch strings.ToLower(r.Encoding) {
+	case "", EncodingUTF8, EncodingUTF16, EncodingUTF16LE, EncodingUTF16BE, EncodingWindows1252, EncodingISO88591:
+	default:
+		return fmt.Errorf("invalid encoding: %s", r.Encoding)
+	}
+
+	if len(r.IdempotencyKey) > 255 {
+		return fmt.Errorf("idempotency key must be at most 255 characters")
+	}
+
+	return nil
+}
@@ -1,6 +1,64 @@
 package dto_transaction
 
 type UploadResponseDTO struct {
-	TotalRows    int    `json:"total_rows"`
-	UploadStatus string `json:"upload_status"`
+	UploadID string `json:"upload_id"`
+	// TotalRows counts every row in the file, SkippedRows those left out as
+	// duplicates and FailedRows the invalid ones.
+	TotalRows   int    `json:"total_rows"`
+	SkippedRows int    `json:"skipped_rows"`
+	FailedRows  int    `json:"failed_rows"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Errors explains what is wrong with the failed rows. It is capped, so
+	// it may list fewer rows than FailedRows.
+	Errors       []RowErrorDTO `json:"errors,omitempty"`
+	UploadStatus string        `json:"upload_status"`
+	// Balances are the balances the statement states, by account, for
+	// formats that have them.
+	Balances []StatementBalanceDTO `json:"balances,omitempty"`
+}
+
+// StatementBalanceDTO is what a statement states about one account's
+// balance, in cents. Booked is the sum of the statement's booked entries
+// for the account, credits less debits, and Mismatch is set when the
+// opening balance and Booked don't add up to the closing balance.
+type StatementBalanceDTO struct {
+	Account        string `json:"account,omitempty"`
+	OpeningBalance *int64 `json:"opening_balance,omitempty"`
+	ClosingBalance *int64 `json:"closing_balance,omitempty"`
+	Booked         int64  `json:"booked"`
+	Mismatch       bool   `json:"mismatch"`
+}
+
+// RowErrorDTO is one problem with a row of an uploaded file. Column and
+// Field are left out when the problem is with the row as a whole.
+type RowErrorDTO struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type UploadDTO struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	Checksum    string `json:"checksum"`
+	RowCount    int    `json:"row_count"`
+	SkippedRows int    `json:"skipped_rows"`
+	FailedRows  int    `json:"failed_rows"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	UploadedAt  string `json:"uploaded_at"`
+	Status      string `json:"status"`
+	// Balances are the balances the statement states, by account, for
+	// formats that have them.
+	Balances []StatementBalanceDTO `json:"balances,omitempty"`
+}
+
+type UploadListResponseDTO struct {
+	Uploads []UploadDTO `json:"uploads"`
+	Total   int         `json:"total"`
+}
+
+type DeleteUploadResponseDTO struct {
+	Upload      UploadDTO `json:"upload"`
+	DeletedRows int       `json:"deleted_rows"`
 }
@@ -1,6 +1,14 @@
 package config
 
+// EnvDevelopment is the APP_ENV of a local setup, where missing secrets are
+// made up at startup rather than refused.
+const EnvDevelopment = "development"
+
 type App struct {
-	CookieName     string
-	AllowedOrigins string
+	// Env is APP_ENV, "production" unless set.
+	Env               string
+	CookieName        string
+	RefreshCookieName string
+	AllowedOrigins    string
+	CursorSecret      string
 }
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type Auth struct {
+	MaxFailedAttempts   int
+	IPMaxFailedAttempts int
+	LockoutDuration     time.Duration
+	BackoffBase         time.Duration
+	MFAIssuer           string
+}
@@ -0,0 +1,19 @@
+package config
+
+type JWT struct {
+	Issuer      string
+	Audience    string
+	ActiveKeyID string
+	Keys        []JWTKey
+}
+
+// JWTKey describes one entry of JWT_KEYS. HS256 keys carry the shared secret
+// inline, RS256 and EdDSA keys point to a PEM file. A PEM file holding only a
+// public key makes the entry verify-only, which is how retired keys are kept
+// around until the tokens they signed have expired.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Secret    string
+	KeyFile   string
+}
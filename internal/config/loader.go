@@ -3,13 +3,20 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	App    App
-	Server Server
+	App      App
+	Server   Server
+	JWT      JWT
+	Auth     Auth
+	Password Password
+	Storage  Storage
 }
 
 func Get() *Config {
@@ -18,14 +25,132 @@ func Get() *Config {
 		log.Printf("Error loading .env file: %v", err)
 	}
 
+	cookieName := os.Getenv("SESSION_COOKIE_NAME")
+
 	return &Config{
 		App: App{
-			CookieName:     os.Getenv("SESSION_COOKIE_NAME"),
-			AllowedOrigins: os.Getenv("ALLOWED_ORIGINS"),
+			Env:               getEnv("APP_ENV", "production"),
+			CookieName:        cookieName,
+			RefreshCookieName: getEnv("REFRESH_COOKIE_NAME", cookieName+"_refresh"),
+			AllowedOrigins:    os.Getenv("ALLOWED_ORIGINS"),
+			CursorSecret:      os.Getenv("CURSOR_SECRET"),
 		},
 		Server: Server{
 			Host: os.Getenv("HOST"),
 			Port: os.Getenv("PORT"),
 		},
+		JWT: JWT{
+			Issuer:      getEnv("JWT_ISSUER", "go-uploader"),
+			Audience:    getEnv("JWT_AUDIENCE", "go-uploader"),
+			ActiveKeyID: os.Getenv("JWT_ACTIVE_KEY_ID"),
+			Keys:        parseJWTKeys(os.Getenv("JWT_KEYS")),
+		},
+		Auth: Auth{
+			MaxFailedAttempts:   getEnvInt("AUTH_MAX_FAILED_ATTEMPTS", 5),
+			IPMaxFailedAttempts: getEnvInt("AUTH_IP_MAX_FAILED_ATTEMPTS", 20),
+			LockoutDuration:     getEnvDuration("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+			BackoffBase:         getEnvDuration("AUTH_BACKOFF_BASE", time.Second),
+			MFAIssuer:           getEnv("MFA_ISSUER", "go-uploader"),
+		},
+		Password: Password{
+			MinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			RequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+			RequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			ResetTokenTTL: getEnvDuration("PASSWORD_RESET_TOKEN_TTL", 30*time.Minute),
+			Notifier:      getEnv("NOTIFIER", "log"),
+			NotifierFile:  getEnv("NOTIFIER_FILE", "notifications.log"),
+		},
+		Storage: Storage{
+			Driver:              getEnv("STORAGE_DRIVER", "memory"),
+			SQLitePath:          getEnv("SQLITE_PATH", "go-uploader.db"),
+			AutoMigrate:         getEnvBool("STORAGE_AUTO_MIGRATE", false),
+			JournalDir:          os.Getenv("STORAGE_JOURNAL_DIR"),
+			JournalCompactEvery: getEnvInt("STORAGE_JOURNAL_COMPACT_EVERY", 1000),
+		},
+	}
+}
+
+// parseJWTKeys reads a comma separated list of "kid:alg:secret-or-pem-path"
+// entries, e.g. "2024-01:RS256:/etc/keys/2024-01.pem,legacy:HS256:s3cr3t".
+func parseJWTKeys(value string) []JWTKey {
+	var keys []JWTKey
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			log.Printf("Ignoring malformed JWT_KEYS entry %q", entry)
+			continue
+		}
+
+		key := JWTKey{
+			ID:        strings.TrimSpace(parts[0]),
+			Algorithm: strings.ToUpper(strings.TrimSpace(parts[1])),
+		}
+
+		if key.Algorithm == "HS256" {
+			key.Secret = parts[2]
+		} else {
+			key.KeyFile = strings.TrimSpace(parts[2])
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %t", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
 	}
+	return parsed
 }
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+type Password struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	ResetTokenTTL time.Duration
+	Notifier      string
+	NotifierFile  string
+}
@@ -0,0 +1,14 @@
+package config
+
+type Storage struct {
+	// Driver is "memory" (the default) or "sqlite".
+	Driver     string
+	SQLitePath string
+	// AutoMigrate applies pending schema migrations at startup. When off, the
+	// server refuses to start until "migrate up" has been run.
+	AutoMigrate bool
+	// JournalDir makes the memory driver durable by journaling every change
+	// to this directory. Empty keeps everything in memory only.
+	JournalDir          string
+	JournalCompactEvery int
+}
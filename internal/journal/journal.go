@@ -0,0 +1,388 @@
+// Package journal makes in-memory stores durable. Every mutation is appended
+// to an fsync'd journal before it is applied, the journal is periodically
+// compacted into a snapshot, and both are replayed at startup.
+//
+// Journal records are framed as a 4 byte length, a 4 byte CRC-32 of the
+// payload and the JSON payload itself, so a record torn by a crash is
+// detected and dropped on the next start.
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	journalFile  = "journal.log"
+	snapshotFile = "snapshot.json"
+	headerSize   = 8
+	// maxRecordSize guards against allocating a garbage length read from a
+	// torn header.
+	maxRecordSize = 64 << 20
+)
+
+// Store is an in-memory repository whose state the journal persists.
+type Store interface {
+	// JournalName identifies the store's records and snapshot section.
+	JournalName() string
+	// ApplyRecord replays a mutation previously passed to Append.
+	ApplyRecord(op string, data json.RawMessage) error
+	// SnapshotState returns the store's complete state. It is only called
+	// while no mutation is in progress, but callers may still hold what the
+	// store handed out, so the state must be copies the store alone owns.
+	SnapshotState() any
+	// RestoreState replaces the store's state with a snapshot.
+	RestoreState(data json.RawMessage) error
+}
+
+type record struct {
+	Seq   uint64          `json:"seq"`
+	Store string          `json:"store"`
+	Op    string          `json:"op"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type snapshot struct {
+	// Seq is the last journal record included in the snapshot. Records up to
+	// it are skipped on replay, in case we crashed before truncating.
+	Seq    uint64                     `json:"seq"`
+	Stores map[string]json.RawMessage `json:"stores"`
+}
+
+// Journal is safe for concurrent use. A nil *Journal is valid and does
+// nothing, which is how the repositories run without persistence.
+type Journal struct {
+	dir          string
+	compactEvery int
+
+	// gate is held for reading by every mutation and for writing by Compact,
+	// so snapshots never see a half-applied mutation.
+	gate sync.RWMutex
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	seq     uint64
+	records int
+	stores  map[string]Store
+}
+
+// Open opens, or creates, the journal in dir. The stores must then be
+// registered with Load before any mutation is made. A compactEvery below 1
+// disables automatic compaction.
+func Open(dir string, compactEvery int) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{
+		dir:          dir,
+		compactEvery: compactEvery,
+		file:         file,
+		stores:       make(map[string]Store),
+	}, nil
+}
+
+// Load restores the stores from the latest snapshot and replays the journal
+// on top of it. A torn record at the end of the journal is cut off.
+func (j *Journal) Load(stores ...Store) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, store := range stores {
+		j.stores[store.JournalName()] = store
+	}
+
+	snapshotSeq, err := j.loadSnapshot()
+	if err != nil {
+		return err
+	}
+	j.seq = snapshotSeq
+
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(j.file)
+	var offset int64
+
+	for {
+		payload, err := readFrame(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Journal: discarding torn record at offset %d: %v", offset, err)
+			if err := j.truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+
+		var entry record
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			return fmt.Errorf("journal record at offset %d: %v", offset, err)
+		}
+
+		offset += headerSize + int64(len(payload))
+		j.records++
+
+		if entry.Seq <= snapshotSeq {
+			continue
+		}
+
+		store, exists := j.stores[entry.Store]
+		if !exists {
+			return fmt.Errorf("journal record %d: unknown store %q", entry.Seq, entry.Store)
+		}
+
+		if err := store.ApplyRecord(entry.Op, entry.Data); err != nil {
+			return fmt.Errorf("journal record %d: %v", entry.Seq, err)
+		}
+
+		j.seq = entry.Seq
+	}
+
+	j.size = offset
+	return nil
+}
+
+func (j *Journal) loadSnapshot() (uint64, error) {
+	content, err := os.ReadFile(filepath.Join(j.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var state snapshot
+	if err := json.Unmarshal(content, &state); err != nil {
+		return 0, fmt.Errorf("snapshot: %v", err)
+	}
+
+	for name, data := range state.Stores {
+		store, exists := j.stores[name]
+		if !exists {
+			return 0, fmt.Errorf("snapshot: unknown store %q", name)
+		}
+
+		if err := store.RestoreState(data); err != nil {
+			return 0, fmt.Errorf("snapshot of %s: %v", name, err)
+		}
+	}
+
+	return state.Seq, nil
+}
+
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, headerSize)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if n == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("short header")
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("record length %d is too large", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("short record")
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+
+	return payload, nil
+}
+
+// Begin must be called before a store applies a mutation, and the returned
+// function once it is done. The journal is compacted on release once enough
+// records have accumulated.
+func (j *Journal) Begin() func() {
+	if j == nil {
+		return func() {}
+	}
+
+	j.gate.RLock()
+
+	return func() {
+		j.gate.RUnlock()
+
+		if j.compactEvery > 0 && j.pendingRecords() >= j.compactEvery {
+			if err := j.Compact(); err != nil {
+				log.Printf("Journal: compaction failed: %v", err)
+			}
+		}
+	}
+}
+
+// Append durably records a mutation of store. It returns once the record
+// has been synced to disk; the store should only apply the mutation if it
+// succeeds.
+func (j *Journal) Append(store string, op string, data any) error {
+	if j == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, err := json.Marshal(record{Seq: j.seq + 1, Store: store, Op: op, Data: payload})
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, headerSize+len(entry))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(entry)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(entry))
+	copy(frame[headerSize:], entry)
+
+	if _, err := j.file.Write(frame); err != nil {
+		// Don't leave a partial record for the next one to be appended after.
+		j.truncate(j.size)
+		return fmt.Errorf("journal write failed: %v", err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		j.truncate(j.size)
+		return fmt.Errorf("journal sync failed: %v", err)
+	}
+
+	j.size += int64(len(frame))
+	j.seq++
+	j.records++
+	return nil
+}
+
+func (j *Journal) pendingRecords() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.records
+}
+
+// Compact writes the state of every store to a new snapshot and empties the
+// journal.
+func (j *Journal) Compact() error {
+	if j == nil {
+		return nil
+	}
+
+	j.gate.Lock()
+	defer j.gate.Unlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state := snapshot{Seq: j.seq, Stores: make(map[string]json.RawMessage, len(j.stores))}
+	for name, store := range j.stores {
+		data, err := json.Marshal(store.SnapshotState())
+		if err != nil {
+			return fmt.Errorf("snapshot of %s: %v", name, err)
+		}
+		state.Stores[name] = data
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(j.dir, snapshotFile), content); err != nil {
+		return err
+	}
+
+	if err := j.truncate(0); err != nil {
+		return err
+	}
+
+	j.records = 0
+	return nil
+}
+
+// Close compacts the journal and closes it.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+
+	err := j.Compact()
+
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (j *Journal) truncate(size int64) error {
+	if err := j.file.Truncate(size); err != nil {
+		return err
+	}
+
+	j.size = size
+	return j.file.Sync()
+}
+
+// writeFileAtomic replaces path with content so that a crash leaves either
+// the old or the new file, never a mix.
+func writeFileAtomic(path string, content []byte) error {
+	temp := path + ".tmp"
+
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
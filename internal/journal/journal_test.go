@@ -0,0 +1,191 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// counterStore keeps a list of appended values.
+type counterStore struct {
+	values []int
+}
+
+func (s *counterStore) JournalName() string {
+	return "counter"
+}
+
+func (s *counterStore) ApplyRecord(op string, data json.RawMessage) error {
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	s.values = append(s.values, value)
+	return nil
+}
+
+func (s *counterStore) SnapshotState() any {
+	return s.values
+}
+
+func (s *counterStore) RestoreState(data json.RawMessage) error {
+	return json.Unmarshal(data, &s.values)
+}
+
+func (s *counterStore) add(t *testing.T, j *Journal, value int) {
+	t.Helper()
+
+	release := j.Begin()
+	defer release()
+
+	if err := j.Append(s.JournalName(), "add", value); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	s.values = append(s.values, value)
+}
+
+func openTestJournal(t *testing.T, dir string, compactEvery int) (*Journal, *counterStore) {
+	t.Helper()
+
+	j, err := Open(dir, compactEvery)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+
+	store := &counterStore{}
+	if err := j.Load(store); err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+
+	return j, store
+}
+
+func TestJournal_Replay(t *testing.T) {
+	dir := t.TempDir()
+
+	j, store := openTestJournal(t, dir, 0)
+	for value := 1; value <= 3; value++ {
+		store.add(t, j, value)
+	}
+
+	// Simulate a crash: the journal is never closed or compacted
+	_, restored := openTestJournal(t, dir, 0)
+
+	if len(restored.values) != 3 || restored.values[2] != 3 {
+		t.Errorf("expected [1 2 3] after replay, got %v", restored.values)
+	}
+}
+
+func TestJournal_TornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	j, store := openTestJournal(t, dir, 0)
+	store.add(t, j, 1)
+	store.add(t, j, 2)
+
+	path := filepath.Join(dir, journalFile)
+	info, _ := os.Stat(path)
+
+	// Cut the last record in half, as a crash mid-write would
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("failed to truncate journal: %v", err)
+	}
+
+	j, restored := openTestJournal(t, dir, 0)
+	if len(restored.values) != 1 || restored.values[0] != 1 {
+		t.Fatalf("expected only the intact record to be replayed, got %v", restored.values)
+	}
+
+	// New records must not end up behind the torn one
+	restored.add(t, j, 3)
+
+	_, again := openTestJournal(t, dir, 0)
+	if len(again.values) != 2 || again.values[1] != 3 {
+		t.Errorf("expected [1 3], got %v", again.values)
+	}
+}
+
+func TestJournal_CorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	j, store := openTestJournal(t, dir, 0)
+	store.add(t, j, 1)
+	store.add(t, j, 2)
+
+	path := filepath.Join(dir, journalFile)
+	content, _ := os.ReadFile(path)
+	content[len(content)-2] ^= 0xff
+	os.WriteFile(path, content, 0600)
+
+	_, restored := openTestJournal(t, dir, 0)
+	if len(restored.values) != 1 {
+		t.Errorf("expected the record failing its checksum to be dropped, got %v", restored.values)
+	}
+}
+
+func TestJournal_Compaction(t *testing.T) {
+	dir := t.TempDir()
+
+	j, store := openTestJournal(t, dir, 3)
+	for value := 1; value <= 4; value++ {
+		store.add(t, j, value)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatalf("expected a snapshot after 3 records, got %v", err)
+	}
+
+	info, _ := os.Stat(filepath.Join(dir, journalFile))
+	if info.Size() == 0 {
+		t.Error("expected the record after the snapshot to be journaled")
+	}
+
+	_, restored := openTestJournal(t, dir, 3)
+	if len(restored.values) != 4 || restored.values[3] != 4 {
+		t.Errorf("expected [1 2 3 4] from snapshot and journal, got %v", restored.values)
+	}
+
+	if err := j.Close(); err != nil {
+		t.Fatalf("failed to close journal: %v", err)
+	}
+
+	info, _ = os.Stat(filepath.Join(dir, journalFile))
+	if info.Size() != 0 {
+		t.Error("expected Close to compact the journal")
+	}
+}
+
+func TestJournal_SnapshotWithoutTruncate(t *testing.T) {
+	dir := t.TempDir()
+
+	j, store := openTestJournal(t, dir, 0)
+	store.add(t, j, 1)
+	store.add(t, j, 2)
+
+	journalContent, _ := os.ReadFile(filepath.Join(dir, journalFile))
+
+	if err := j.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	// Crash between writing the snapshot and truncating the journal
+	os.WriteFile(filepath.Join(dir, journalFile), journalContent, 0600)
+
+	_, restored := openTestJournal(t, dir, 0)
+	if len(restored.values) != 2 {
+		t.Errorf("expected records already in the snapshot to be skipped, got %v", restored.values)
+	}
+}
+
+func TestJournal_Nil(t *testing.T) {
+	var j *Journal
+
+	j.Begin()()
+	if err := j.Append("counter", "add", 1); err != nil {
+		t.Errorf("expected a nil journal to accept records, got %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Errorf("expected a nil journal to close, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PermissionMiddleware checks the role of the signed-in user. It has to run
+// after SessionMiddleware.Handle, which provides the session.
+type PermissionMiddleware struct {
+	userRepo domain.UserRepository
+}
+
+func NewPermissionMiddleware(userRepo domain.UserRepository) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		userRepo: userRepo,
+	}
+}
+
+func (m *PermissionMiddleware) Require(permission domain.Permission) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		session := ctx.Locals("session").(*domain.Session)
+
+		user, err := m.userRepo.FindByID(session.UserID)
+		if err != nil {
+			return ctx.Status(401).JSON(dto.CreateErrorResponse(err.Error()))
+		}
+
+		if !user.HasPermission(permission) {
+			return ctx.Status(403).JSON(dto.CreateErrorResponse("Forbidden: Missing permission " + string(permission)))
+		}
+
+		ctx.Locals("user", user)
+
+		return ctx.Next()
+	}
+}
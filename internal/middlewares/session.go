@@ -1,6 +1,10 @@
 package middlewares
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"firstpersoncode/go-uploader/domain"
 	"firstpersoncode/go-uploader/dto"
 	"firstpersoncode/go-uploader/internal/config"
@@ -9,33 +13,155 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+const lastSeenInterval = time.Minute
+
 type SessionMiddleware struct {
-	repo domain.SessionRepository
+	repo       domain.SessionRepository
+	apiKeyRepo domain.APIKeyRepository
+	userRepo   domain.UserRepository
 }
 
-func NewSessionMiddleware(repo domain.SessionRepository) *SessionMiddleware {
+func NewSessionMiddleware(repo domain.SessionRepository, apiKeyRepo domain.APIKeyRepository, userRepo domain.UserRepository) *SessionMiddleware {
 	return &SessionMiddleware{
-		repo: repo,
+		repo:       repo,
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
 	}
 }
 
+// Handle authenticates the request from, in order, an Authorization header
+// (a JWT or an API key), an X-API-Key header, or the session cookie. Whatever
+// the method, downstream handlers find a *domain.Session under "session".
 func (s *SessionMiddleware) Handle(ctx *fiber.Ctx) error {
-	token := ctx.Cookies(config.Get().App.CookieName)
+	session, status, err := s.authenticate(ctx)
+	if err != nil {
+		return ctx.Status(status).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	ctx.Locals("session", session)
+
+	return ctx.Next()
+}
+
+// Optional is Handle for routes that take other credentials too: a request
+// it can't authenticate goes on without a session.
+func (s *SessionMiddleware) Optional(ctx *fiber.Ctx) error {
+	if session, _, err := s.authenticate(ctx); err == nil {
+		ctx.Locals("session", session)
+	}
+
+	return ctx.Next()
+}
+
+// authenticate returns the request's session or, with the status to answer
+// with, why there is none.
+func (s *SessionMiddleware) authenticate(ctx *fiber.Ctx) (*domain.Session, int, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		token = ctx.Get("X-API-Key")
+	}
 	if token == "" {
-		return ctx.Status(401).JSON(dto.CreateErrorResponse("Unauthorized: No session token"))
+		token = ctx.Cookies(config.Get().App.CookieName)
+	}
+	if token == "" {
+		return nil, 401, errors.New("Unauthorized: No session token")
+	}
+
+	if strings.HasPrefix(token, domain.APIKeyPrefix) {
+		return s.authenticateAPIKey(token)
 	}
 
 	claims, err := util.ValidateJWT(token)
 	if err != nil {
-		return ctx.Status(401).JSON(dto.CreateErrorResponse(err.Error()))
+		return nil, 401, err
 	}
 
 	session, err := s.repo.FindByID(claims.Sub)
 	if err != nil {
-		return ctx.Status(401).JSON(dto.CreateErrorResponse(err.Error()))
+		return nil, 401, err
 	}
 
-	ctx.Locals("session", session)
+	if session.Revoked {
+		return nil, 401, errors.New("Unauthorized: Session revoked")
+	}
 
-	return ctx.Next()
+	if s.isDisabled(session.UserID) {
+		return nil, 403, errors.New("Forbidden: Account disabled")
+	}
+
+	return s.touch(ctx, session), 0, nil
+}
+
+// RequireScope rejects API key requests whose key wasn't granted scope.
+// Requests authenticated by a signed-in session always pass.
+func (s *SessionMiddleware) RequireScope(scope domain.Scope) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		session := ctx.Locals("session").(*domain.Session)
+		if !session.HasScope(scope) {
+			return ctx.Status(403).JSON(dto.CreateErrorResponse("Forbidden: API key lacks the " + string(scope) + " scope"))
+		}
+
+		return ctx.Next()
+	}
+}
+
+func (s *SessionMiddleware) authenticateAPIKey(rawKey string) (*domain.Session, int, error) {
+	key, err := s.apiKeyRepo.FindByHash(util.HashToken(rawKey))
+	if err != nil || key.Revoked {
+		return nil, 401, errors.New("Unauthorized: Invalid API key")
+	}
+
+	if s.isDisabled(key.UserID) {
+		return nil, 403, errors.New("Forbidden: Account disabled")
+	}
+
+	if time.Since(key.LastUsedAt) >= lastSeenInterval {
+		used := *key
+		used.LastUsedAt = time.Now()
+		s.apiKeyRepo.Update(&used)
+	}
+
+	return &domain.Session{
+		UserID:   key.UserID,
+		APIKeyID: key.ID,
+		Scopes:   key.Scopes,
+	}, 0, nil
+}
+
+func (s *SessionMiddleware) isDisabled(userID string) bool {
+	user, err := s.userRepo.FindByID(userID)
+	return err == nil && user.Disabled
+}
+
+func bearerToken(ctx *fiber.Ctx) string {
+	header := ctx.Get(fiber.HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+
+	return ""
+}
+
+// touch records the client activity on the session. Writes are throttled so
+// a burst of requests from the same device doesn't rewrite it every time,
+// and only touch the activity fields, so a refresh revoking the session in
+// the meantime sticks.
+func (s *SessionMiddleware) touch(ctx *fiber.Ctx, session *domain.Session) *domain.Session {
+	ip := ctx.IP()
+	userAgent := ctx.Get(fiber.HeaderUserAgent)
+
+	if time.Since(session.LastSeenAt) < lastSeenInterval && session.IP == ip && session.UserAgent == userAgent {
+		return session
+	}
+
+	updated := *session
+	updated.LastSeenAt = time.Now()
+	updated.IP = ip
+	updated.UserAgent = userAgent
+
+	if err := s.repo.Touch(updated.ID, updated.LastSeenAt, updated.IP, updated.UserAgent); err != nil {
+		return session
+	}
+
+	return &updated
 }
@@ -0,0 +1,234 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil {
+		t.Fatalf("failed to query schema: %v", err)
+	}
+
+	return count > 0
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := openTestDB(t)
+
+	all, err := All()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(pending) != len(all) {
+		t.Errorf("expected every migration to be pending on a new database, got %d of %d", len(pending), len(all))
+	}
+
+	applied, err := Up(db)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(applied) != len(all) || !tableExists(t, db, "users") {
+		t.Fatalf("expected all migrations to be applied, got %d", len(applied))
+	}
+
+	// Running it again is a no-op
+	if applied, err := Up(db); err != nil || len(applied) != 0 {
+		t.Errorf("expected nothing left to apply, got %d, %v", len(applied), err)
+	}
+
+	statuses, err := GetStatus(db)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Errorf("expected migration %d to be applied", status.Version)
+		}
+	}
+
+	rolledBack, err := Down(db, len(all))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(rolledBack) != len(all) || rolledBack[0].Version != all[len(all)-1].Version {
+		t.Errorf("expected migrations to be rolled back newest first, got %+v", rolledBack)
+	}
+
+	if tableExists(t, db, "users") {
+		t.Error("expected the users table to be dropped")
+	}
+}
+
+func TestStatus_UnknownVersion(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := Up(db); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'from_the_future', 0)"); err != nil {
+		t.Fatalf("failed to insert version: %v", err)
+	}
+
+	if _, err := Pending(db); err == nil {
+		t.Error("expected a database migrated by a newer release to be refused")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(fstest.MapFS{
+		"sql/0002_add_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN c TEXT")},
+		"sql/0001_create.up.sql":       {Data: []byte("CREATE TABLE t (id INTEGER)")},
+		"sql/0001_create.down.sql":     {Data: []byte("DROP TABLE t")},
+		"sql/0002_add_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN c")},
+	}, "sql")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Name != "create" || migrations[1].Version != 2 || migrations[1].Down == "" {
+		t.Errorf("unexpected migrations: %+v", migrations)
+	}
+
+	for name, file := range map[string]string{
+		"missing direction": "sql/0001_create.sql",
+		"bad version":       "sql/first_create.up.sql",
+		"down only":         "sql/0001_create.down.sql",
+	} {
+		if _, err := load(fstest.MapFS{file: {Data: []byte("SELECT 1")}}, "sql"); err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestTransactionIDs_KeepRows(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := Up(db); err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+
+	all, err := All()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	// Back to the integer IDs of the first schema, with some rows in it.
+	if _, err := Down(db, len(all)-1); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+
+	for _, name := range []string{"b", "a", "c"} {
+		if _, err := db.Exec("INSERT INTO transactions (user_id, timestamp, name, type, amount, status, description) VALUES ('u', 1, ?, 'DEBIT', 1, 'FAILED', 'd')", name); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+
+	if _, err := Up(db); err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+
+	rows, err := db.Query("SELECT id, name FROM transactions ORDER BY rowid")
+	if err != nil {
+		t.Fatalf("failed to query transactions: %v", err)
+	}
+	defer rows.Close()
+
+	names := ""
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatalf("failed to scan: %v", err)
+		}
+
+		if len(id) != 32 {
+			t.Errorf("expected a 32 character hex ID, got %q", id)
+		}
+
+		names += name
+		ids[id] = true
+	}
+
+	if names != "bac" || len(ids) != 3 {
+		t.Errorf("expected the rows to keep their order and get unique IDs, got %s with %d IDs", names, len(ids))
+	}
+}
+
+func TestStatementBalances_KeepBalances(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := Up(db); err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+
+	// Back to a single opening and closing balance per upload.
+	if _, err := Down(db, 1); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO upload_batches (id, user_id, filename, checksum, row_count, uploaded_at, status, opening_balance, closing_balance) VALUES ('stated', 'u', 'f', 'c', 2, 1, 'COMPLETED', 1000, 1750)",
+		"INSERT INTO upload_batches (id, user_id, filename, checksum, row_count, uploaded_at, status) VALUES ('csv', 'u', 'f', 'c', 0, 1, 'COMPLETED')",
+		"INSERT INTO transactions (id, user_id, timestamp, name, type, amount, status, description, batch_id) VALUES ('1', 'u', 1, 'a', 'CREDIT', 1000, 'SUCCESS', 'd', 'stated')",
+		"INSERT INTO transactions (id, user_id, timestamp, name, type, amount, status, description, batch_id) VALUES ('2', 'u', 1, 'b', 'DEBIT', 250, 'SUCCESS', 'd', 'stated')",
+		"INSERT INTO transactions (id, user_id, timestamp, name, type, amount, status, description, batch_id) VALUES ('3', 'u', 1, 'c', 'DEBIT', 99, 'PENDING', 'd', 'stated')",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+
+	if _, err := Up(db); err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+
+	balances := make(map[string]string)
+	rows, err := db.Query("SELECT id, balances FROM upload_batches")
+	if err != nil {
+		t.Fatalf("failed to query upload batches: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, value string
+		if err := rows.Scan(&id, &value); err != nil {
+			t.Fatalf("failed to scan: %v", err)
+		}
+		balances[id] = value
+	}
+
+	if balances["stated"] != `[{"account":"","opening":1000,"closing":1750,"booked":750}]` || balances["csv"] != "null" {
+		t.Errorf("expected the stated balances to be kept with the booked rows summed, got %v", balances)
+	}
+}
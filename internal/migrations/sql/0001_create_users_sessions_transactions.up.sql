@@ -0,0 +1,43 @@
+-- IF NOT EXISTS lets databases created before migrations were introduced
+-- adopt this baseline without losing data.
+CREATE TABLE IF NOT EXISTS users (
+	id                 TEXT PRIMARY KEY,
+	username           TEXT NOT NULL UNIQUE,
+	password           TEXT NOT NULL,
+	role               TEXT NOT NULL DEFAULT '',
+	disabled           INTEGER NOT NULL DEFAULT 0,
+	mfa_secret         TEXT NOT NULL DEFAULT '',
+	mfa_enabled        INTEGER NOT NULL DEFAULT 0,
+	mfa_last_used_step INTEGER NOT NULL DEFAULT 0,
+	mfa_recovery_codes TEXT NOT NULL DEFAULT 'null'
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id             TEXT PRIMARY KEY,
+	user_id        TEXT NOT NULL,
+	family_id      TEXT NOT NULL,
+	refresh_token  TEXT NOT NULL UNIQUE,
+	refresh_expiry INTEGER NOT NULL,
+	revoked        INTEGER NOT NULL DEFAULT 0,
+	created_at     INTEGER NOT NULL,
+	last_seen_at   INTEGER NOT NULL,
+	ip             TEXT NOT NULL DEFAULT '',
+	user_agent     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_family_id ON sessions (family_id);
+
+CREATE TABLE IF NOT EXISTS transactions (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id     TEXT NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	amount      INTEGER NOT NULL,
+	status      TEXT NOT NULL,
+	description TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS transactions_user_id ON transactions (user_id);
+CREATE INDEX IF NOT EXISTS transactions_user_status_timestamp ON transactions (user_id, status, timestamp);
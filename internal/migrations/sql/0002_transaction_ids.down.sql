@@ -0,0 +1,20 @@
+CREATE TABLE transactions_old (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id     TEXT NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	amount      INTEGER NOT NULL,
+	status      TEXT NOT NULL,
+	description TEXT NOT NULL
+);
+
+INSERT INTO transactions_old (user_id, timestamp, name, type, amount, status, description)
+SELECT user_id, timestamp, name, type, amount, status, description
+FROM transactions ORDER BY rowid;
+
+DROP TABLE transactions;
+ALTER TABLE transactions_old RENAME TO transactions;
+
+CREATE INDEX transactions_user_id ON transactions (user_id);
+CREATE INDEX transactions_user_status_timestamp ON transactions (user_id, status, timestamp);
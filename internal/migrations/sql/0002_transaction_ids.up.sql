@@ -0,0 +1,23 @@
+-- Transactions get opaque TEXT IDs, which SQLite can't retrofit onto the
+-- INTEGER primary key, so the table is rebuilt. Rows keep their rowid order,
+-- which is still the upload order.
+CREATE TABLE transactions_new (
+	id          TEXT PRIMARY KEY,
+	user_id     TEXT NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	amount      INTEGER NOT NULL,
+	status      TEXT NOT NULL,
+	description TEXT NOT NULL
+);
+
+INSERT INTO transactions_new (id, user_id, timestamp, name, type, amount, status, description)
+SELECT lower(hex(randomblob(16))), user_id, timestamp, name, type, amount, status, description
+FROM transactions ORDER BY id;
+
+DROP TABLE transactions;
+ALTER TABLE transactions_new RENAME TO transactions;
+
+CREATE INDEX transactions_user_id ON transactions (user_id);
+CREATE INDEX transactions_user_status_timestamp ON transactions (user_id, status, timestamp, id);
@@ -0,0 +1,4 @@
+DROP INDEX transactions_batch_id;
+ALTER TABLE transactions DROP COLUMN batch_id;
+
+DROP TABLE upload_batches;
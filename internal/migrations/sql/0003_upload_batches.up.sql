@@ -0,0 +1,16 @@
+CREATE TABLE upload_batches (
+	id          TEXT PRIMARY KEY,
+	user_id     TEXT NOT NULL,
+	filename    TEXT NOT NULL,
+	checksum    TEXT NOT NULL,
+	row_count   INTEGER NOT NULL,
+	uploaded_at INTEGER NOT NULL,
+	status      TEXT NOT NULL
+);
+
+CREATE INDEX upload_batches_user_uploaded_at ON upload_batches (user_id, uploaded_at);
+
+-- Rows stored before uploads were tracked belong to no batch.
+ALTER TABLE transactions ADD COLUMN batch_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX transactions_batch_id ON transactions (batch_id);
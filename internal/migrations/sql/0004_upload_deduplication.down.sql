@@ -0,0 +1,7 @@
+DROP INDEX transactions_user_timestamp;
+DROP INDEX upload_batches_user_checksum;
+DROP INDEX upload_batches_user_idempotency_key;
+
+ALTER TABLE upload_batches DROP COLUMN duplicate_of;
+ALTER TABLE upload_batches DROP COLUMN skipped_rows;
+ALTER TABLE upload_batches DROP COLUMN idempotency_key;
@@ -0,0 +1,9 @@
+ALTER TABLE upload_batches ADD COLUMN idempotency_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE upload_batches ADD COLUMN skipped_rows INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE upload_batches ADD COLUMN duplicate_of TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX upload_batches_user_idempotency_key ON upload_batches (user_id, idempotency_key) WHERE idempotency_key != '';
+CREATE INDEX upload_batches_user_checksum ON upload_batches (user_id, checksum);
+
+-- Duplicate rows are looked up by the time span of the new statement.
+CREATE INDEX transactions_user_timestamp ON transactions (user_id, timestamp);
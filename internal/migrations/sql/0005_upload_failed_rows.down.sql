@@ -0,0 +1 @@
+ALTER TABLE upload_batches DROP COLUMN failed_rows;
@@ -0,0 +1 @@
+ALTER TABLE upload_batches ADD COLUMN failed_rows INTEGER NOT NULL DEFAULT 0;
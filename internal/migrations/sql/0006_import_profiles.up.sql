@@ -0,0 +1,14 @@
+CREATE TABLE import_profiles (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	delimiter  TEXT NOT NULL DEFAULT '',
+	quote      TEXT NOT NULL DEFAULT '',
+	skip_rows  INTEGER NOT NULL DEFAULT 0,
+	columns    TEXT NOT NULL DEFAULT 'null',
+	defaults   TEXT NOT NULL DEFAULT 'null',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX import_profiles_user_name ON import_profiles (user_id, name COLLATE NOCASE);
@@ -0,0 +1,6 @@
+ALTER TABLE import_profiles DROP COLUMN sign_convention;
+ALTER TABLE import_profiles DROP COLUMN decimal_places;
+ALTER TABLE import_profiles DROP COLUMN thousands_separator;
+ALTER TABLE import_profiles DROP COLUMN decimal_separator;
+ALTER TABLE import_profiles DROP COLUMN time_zone;
+ALTER TABLE import_profiles DROP COLUMN date_layout;
@@ -0,0 +1,6 @@
+ALTER TABLE import_profiles ADD COLUMN date_layout TEXT NOT NULL DEFAULT '';
+ALTER TABLE import_profiles ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE import_profiles ADD COLUMN decimal_separator TEXT NOT NULL DEFAULT '';
+ALTER TABLE import_profiles ADD COLUMN thousands_separator TEXT NOT NULL DEFAULT '';
+ALTER TABLE import_profiles ADD COLUMN decimal_places INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE import_profiles ADD COLUMN sign_convention TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- The bank's own ID for a transaction, where the statement format has one.
+ALTER TABLE transactions ADD COLUMN external_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE upload_batches DROP COLUMN closing_balance;
+ALTER TABLE upload_batches DROP COLUMN opening_balance;
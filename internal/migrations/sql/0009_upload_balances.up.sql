@@ -0,0 +1,4 @@
+-- The opening and closing balances a statement states, for formats that
+-- have them.
+ALTER TABLE upload_batches ADD COLUMN opening_balance INTEGER;
+ALTER TABLE upload_batches ADD COLUMN closing_balance INTEGER;
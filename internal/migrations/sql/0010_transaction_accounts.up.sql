@@ -0,0 +1,3 @@
+-- The account a statement was for, which a bank's ID for a transaction is
+-- only unique within.
+ALTER TABLE transactions ADD COLUMN account TEXT NOT NULL DEFAULT '';
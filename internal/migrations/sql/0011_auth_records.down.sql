@@ -0,0 +1,4 @@
+DROP TABLE password_reset_tokens;
+DROP TABLE auth_events;
+DROP TABLE login_attempts;
+DROP TABLE api_keys;
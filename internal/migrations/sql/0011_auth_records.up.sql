@@ -0,0 +1,46 @@
+-- API keys, sign-in throttling, the auth audit trail and password reset
+-- tokens, which were only ever kept in memory before.
+CREATE TABLE api_keys (
+	id           TEXT PRIMARY KEY,
+	user_id      TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	hash         TEXT NOT NULL UNIQUE,
+	scopes       TEXT NOT NULL DEFAULT 'null',
+	created_at   INTEGER NOT NULL,
+	last_used_at INTEGER NOT NULL,
+	revoked      INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE login_attempts (
+	key          TEXT PRIMARY KEY,
+	failures     INTEGER NOT NULL,
+	last_failure INTEGER NOT NULL,
+	locked_until INTEGER NOT NULL
+);
+
+CREATE TABLE auth_events (
+	id         TEXT PRIMARY KEY,
+	type       TEXT NOT NULL,
+	user_id    TEXT NOT NULL DEFAULT '',
+	username   TEXT NOT NULL DEFAULT '',
+	ip         TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	reason     TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+);
+
+CREATE INDEX auth_events_username ON auth_events (username);
+
+CREATE TABLE password_reset_tokens (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	used       INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX password_reset_tokens_user_id ON password_reset_tokens (user_id);
@@ -0,0 +1,8 @@
+ALTER TABLE upload_batches ADD COLUMN opening_balance INTEGER;
+ALTER TABLE upload_batches ADD COLUMN closing_balance INTEGER;
+
+UPDATE upload_batches SET
+	opening_balance = json_extract(balances, '$[0].opening'),
+	closing_balance = json_extract(balances, '$[0].closing');
+
+ALTER TABLE upload_batches DROP COLUMN balances;
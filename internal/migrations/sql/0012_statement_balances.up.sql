@@ -0,0 +1,18 @@
+-- A statement's balances by account, with the sum of its booked entries to
+-- check them against. Earlier uploads were for a single account, and their
+-- booked entries are summed from the rows they stored.
+ALTER TABLE upload_batches ADD COLUMN balances TEXT NOT NULL DEFAULT 'null';
+
+UPDATE upload_batches SET balances = json_array(json_object(
+	'account', '',
+	'opening', opening_balance,
+	'closing', closing_balance,
+	'booked', (
+		SELECT COALESCE(SUM(CASE type WHEN 'CREDIT' THEN amount ELSE -amount END), 0)
+		FROM transactions WHERE batch_id = upload_batches.id AND status = 'SUCCESS'
+	)
+))
+WHERE opening_balance IS NOT NULL OR closing_balance IS NOT NULL;
+
+ALTER TABLE upload_batches DROP COLUMN closing_balance;
+ALTER TABLE upload_batches DROP COLUMN opening_balance;
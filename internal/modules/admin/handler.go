@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"errors"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/dto"
+	dto_admin "firstpersoncode/go-uploader/dto/admin"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type adminHandler struct {
+	service domain.AdminService
+}
+
+func NewAdminHandler(service domain.AdminService) domain.AdminHandler {
+	return &adminHandler{service: service}
+}
+
+func (h *adminHandler) ListUsers(ctx *fiber.Ctx) error {
+	var pagination dto_transaction.PaginationDTO
+	if err := ctx.QueryParser(&pagination); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	response, err := h.service.ListUsers(pagination)
+	if err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Users retrieved successfully", response))
+}
+
+func (h *adminHandler) GetUser(ctx *fiber.Ctx) error {
+	response, err := h.service.GetUser(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("User retrieved successfully", response))
+}
+
+func (h *adminHandler) DisableUser(ctx *fiber.Ctx) error {
+	return h.setDisabled(ctx, true, "User disabled successfully")
+}
+
+func (h *adminHandler) EnableUser(ctx *fiber.Ctx) error {
+	return h.setDisabled(ctx, false, "User enabled successfully")
+}
+
+func (h *adminHandler) setDisabled(ctx *fiber.Ctx, disabled bool, message string) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := h.service.SetUserDisabled(session.UserID, ctx.Params("id"), disabled); err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse(message, map[string]interface{}{}))
+}
+
+func (h *adminHandler) UpdateRole(ctx *fiber.Ctx) error {
+	var request dto_admin.UpdateRoleRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := h.service.SetUserRole(session.UserID, ctx.Params("id"), domain.Role(request.Role)); err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("User role updated successfully", map[string]interface{}{}))
+}
+
+func (h *adminHandler) RevokeSessions(ctx *fiber.Ctx) error {
+	if err := h.service.RevokeUserSessions(ctx.Params("id")); err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("User sessions revoked successfully", map[string]interface{}{}))
+}
+
+func (h *adminHandler) GetUserTransactions(ctx *fiber.Ctx) error {
+	var pagination dto_transaction.PaginationDTO
+	if err := ctx.QueryParser(&pagination); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	response, err := h.service.GetUserTransactions(ctx.Params("id"), pagination)
+	if err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Transactions retrieved successfully", response))
+}
+
+func (h *adminHandler) GetUserBalance(ctx *fiber.Ctx) error {
+	response, err := h.service.GetUserBalance(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(adminErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Balance calculated successfully", response))
+}
+
+// adminErrorStatus reports an unknown role or an admin changing their own
+// account as 400 and a missing user as 404. Anything else is a failure to
+// carry out a valid request.
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidRole), errors.Is(err, domain.ErrOwnAccount):
+		return 400
+	case errors.Is(err, domain.ErrUserNotFound):
+		return 404
+	}
+
+	return 500
+}
@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"fmt"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_admin "firstpersoncode/go-uploader/dto/admin"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+type adminService struct {
+	userRepo           domain.UserRepository
+	sessionRepo        domain.SessionRepository
+	transactionRepo    domain.TransactionRepository
+	transactionService domain.TransactionService
+}
+
+func NewAdminService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, transactionRepo domain.TransactionRepository, transactionService domain.TransactionService) domain.AdminService {
+	return &adminService{
+		userRepo:           userRepo,
+		sessionRepo:        sessionRepo,
+		transactionRepo:    transactionRepo,
+		transactionService: transactionService,
+	}
+}
+
+func (s *adminService) ListUsers(pagination dto_transaction.PaginationDTO) (*dto_admin.UserListResponseDTO, error) {
+	pagination = normalizePagination(pagination)
+
+	users, total, err := s.userRepo.FindAll(pagination.Page, pagination.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %v", err)
+	}
+
+	result := make([]dto_admin.AdminUserDTO, 0, len(users))
+	for _, user := range users {
+		result = append(result, toAdminUserDTO(user))
+	}
+
+	return &dto_admin.UserListResponseDTO{
+		Users: result,
+		Total: total,
+	}, nil
+}
+
+func (s *adminService) GetUser(userID string) (*dto_admin.AdminUserDTO, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toAdminUserDTO(user)
+	return &result, nil
+}
+
+func (s *adminService) SetUserDisabled(actorID string, userID string, disabled bool) error {
+	if actorID == userID {
+		return fmt.Errorf("%w's status", domain.ErrOwnAccount)
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	updated := *user
+	updated.Disabled = disabled
+
+	if _, err := s.userRepo.Update(&updated); err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	if disabled {
+		return s.RevokeUserSessions(userID)
+	}
+
+	return nil
+}
+
+func (s *adminService) SetUserRole(actorID string, userID string, role domain.Role) error {
+	if !role.IsValid() {
+		return fmt.Errorf("%w: %s", domain.ErrInvalidRole, role)
+	}
+
+	if actorID == userID {
+		return fmt.Errorf("%w's role", domain.ErrOwnAccount)
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	updated := *user
+	updated.Role = role
+
+	if _, err := s.userRepo.Update(&updated); err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	return nil
+}
+
+func (s *adminService) RevokeUserSessions(userID string) error {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.DeleteByUserID(userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	return nil
+}
+
+func (s *adminService) GetUserTransactions(userID string, pagination dto_transaction.PaginationDTO) (*dto_admin.UserTransactionsResponseDTO, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, err
+	}
+
+	pagination = normalizePagination(pagination)
+
+	userTransactions, total, err := s.transactionRepo.Search(
+		domain.TransactionFilter{UserID: userID},
+		pagination,
+		dto_transaction.SortingDTO{SortBy: "-timestamp"},
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %v", err)
+	}
+
+	transactions := make([]dto_transaction.TransactionDTO, 0, len(userTransactions))
+	for _, tx := range userTransactions {
+		transactions = append(transactions, dto_transaction.TransactionDTO{
+			ID:          tx.ID,
+			Timestamp:   tx.Timestamp.Format(time.RFC3339),
+			Name:        tx.Name,
+			Type:        string(tx.Type),
+			Amount:      tx.Amount,
+			Status:      string(tx.Status),
+			Description: tx.Description,
+		})
+	}
+
+	return &dto_admin.UserTransactionsResponseDTO{
+		Transactions: transactions,
+		Total:        total,
+	}, nil
+}
+
+func (s *adminService) GetUserBalance(userID string) (*dto_transaction.BalanceResponseDTO, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, err
+	}
+
+	return s.transactionService.CalculateBalance(userID)
+}
+
+// maxPageLimit caps how many users or transactions one page lists.
+const maxPageLimit = 100
+
+func normalizePagination(pagination dto_transaction.PaginationDTO) dto_transaction.PaginationDTO {
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+
+	if pagination.Limit < 1 {
+		pagination.Limit = 10
+	}
+
+	pagination.Limit = min(pagination.Limit, maxPageLimit)
+
+	return pagination
+}
+
+func toAdminUserDTO(user *domain.User) dto_admin.AdminUserDTO {
+	role := user.Role
+	if role == "" {
+		role = domain.RoleUser
+	}
+
+	return dto_admin.AdminUserDTO{
+		ID:         user.ID,
+		Username:   user.Username,
+		Role:       string(role),
+		Disabled:   user.Disabled,
+		MFAEnabled: user.MFAEnabled,
+	}
+}
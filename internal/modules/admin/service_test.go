@@ -0,0 +1,193 @@
+package admin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"firstpersoncode/go-uploader/internal/modules/transaction"
+	"firstpersoncode/go-uploader/internal/repositories/repotest"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+func setupTestService(t *testing.T) (domain.AdminService, domain.UserRepository, domain.SessionRepository, domain.TransactionService) {
+	t.Helper()
+
+	storage := repotest.Open(t)
+	userRepo := storage.Users
+	sessionRepo := storage.Sessions
+	transactionRepo := storage.Transactions
+	transactionService := transaction.NewTransactionService(transactionRepo, storage.Uploads, storage.Profiles, util.NewCursorSigner("secret"))
+
+	for _, user := range []*domain.User{
+		{Username: "admin", Password: "hash", Role: domain.RoleAdmin},
+		{Username: "alice", Password: "hash", Role: domain.RoleUser},
+		{Username: "bob", Password: "hash"},
+	} {
+		if _, err := userRepo.Save(user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+	}
+
+	service := NewAdminService(userRepo, sessionRepo, transactionRepo, transactionService)
+	return service, userRepo, sessionRepo, transactionService
+}
+
+func userID(t *testing.T, userRepo domain.UserRepository, username string) string {
+	t.Helper()
+
+	user, err := userRepo.FindByUsername(username)
+	if err != nil {
+		t.Fatalf("failed to find user %s: %v", username, err)
+	}
+
+	return user.ID
+}
+
+func TestListUsers_Pagination(t *testing.T) {
+	service, _, _, _ := setupTestService(t)
+
+	response, err := service.ListUsers(dto_transaction.PaginationDTO{Page: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if response.Total != 3 {
+		t.Errorf("expected total 3, got %d", response.Total)
+	}
+
+	if len(response.Users) != 2 || response.Users[0].Username != "admin" || response.Users[1].Username != "alice" {
+		t.Errorf("unexpected first page: %+v", response.Users)
+	}
+
+	response, _ = service.ListUsers(dto_transaction.PaginationDTO{Page: 2, Limit: 2})
+	if len(response.Users) != 1 || response.Users[0].Username != "bob" {
+		t.Errorf("unexpected second page: %+v", response.Users)
+	}
+
+	if response.Users[0].Role != string(domain.RoleUser) {
+		t.Errorf("expected users without a role to be reported as %s, got %s", domain.RoleUser, response.Users[0].Role)
+	}
+}
+
+func TestNormalizePagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		given    dto_transaction.PaginationDTO
+		expected dto_transaction.PaginationDTO
+	}{
+		{"defaults", dto_transaction.PaginationDTO{}, dto_transaction.PaginationDTO{Page: 1, Limit: 10}},
+		{"within the cap", dto_transaction.PaginationDTO{Page: 3, Limit: 50}, dto_transaction.PaginationDTO{Page: 3, Limit: 50}},
+		{"over the cap", dto_transaction.PaginationDTO{Page: 1, Limit: 100000}, dto_transaction.PaginationDTO{Page: 1, Limit: maxPageLimit}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizePagination(tt.given); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetUserDisabled_RevokesSessions(t *testing.T) {
+	service, userRepo, sessionRepo, _ := setupTestService(t)
+	adminID, aliceID := userID(t, userRepo, "admin"), userID(t, userRepo, "alice")
+
+	sessionRepo.Save(&domain.Session{ID: "s1", UserID: aliceID, CreatedAt: time.Now()})
+
+	if err := service.SetUserDisabled(adminID, aliceID, true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user, _ := userRepo.FindByID(aliceID)
+	if !user.Disabled {
+		t.Error("expected user to be disabled")
+	}
+
+	if _, err := sessionRepo.FindByID("s1"); err == nil {
+		t.Error("expected sessions of a disabled user to be revoked")
+	}
+
+	if err := service.SetUserDisabled(adminID, aliceID, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user, _ = userRepo.FindByID(aliceID)
+	if user.Disabled {
+		t.Error("expected user to be enabled again")
+	}
+}
+
+func TestSetUserDisabled_Self(t *testing.T) {
+	service, userRepo, _, _ := setupTestService(t)
+	adminID := userID(t, userRepo, "admin")
+
+	if err := service.SetUserDisabled(adminID, adminID, true); !errors.Is(err, domain.ErrOwnAccount) {
+		t.Error("expected an admin not to be able to disable themselves")
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	service, userRepo, _, _ := setupTestService(t)
+	adminID, aliceID := userID(t, userRepo, "admin"), userID(t, userRepo, "alice")
+
+	if err := service.SetUserRole(adminID, aliceID, domain.RoleAdmin); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user, _ := userRepo.FindByID(aliceID)
+	if !user.HasPermission(domain.PermissionUsersManage) {
+		t.Error("expected promoted user to have admin permissions")
+	}
+
+	if err := service.SetUserRole(adminID, userID(t, userRepo, "bob"), domain.Role("root")); !errors.Is(err, domain.ErrInvalidRole) {
+		t.Error("expected an unknown role to be rejected")
+	}
+
+	if err := service.SetUserRole(adminID, adminID, domain.RoleUser); !errors.Is(err, domain.ErrOwnAccount) {
+		t.Error("expected an admin not to be able to change their own role")
+	}
+}
+
+func TestGetUserTransactions(t *testing.T) {
+	service, userRepo, _, transactionService := setupTestService(t)
+	aliceID := userID(t, userRepo, "alice")
+
+	csvData := `1624507883, JOHN DOE, DEBIT, 250000, SUCCESS, restaurant
+1624608050, E-COMMERCE A, DEBIT, 150000, FAILED, clothes
+1624512883, COMPANY A, CREDIT, 12000000, SUCCESS, salary`
+
+	if _, err := transactionService.ParseAndStoreStatement(strings.NewReader(csvData), dto_transaction.UploadRequestDTO{Filename: "statement.csv"}, aliceID); err != nil {
+		t.Fatalf("failed to store transactions: %v", err)
+	}
+
+	response, err := service.GetUserTransactions(aliceID, dto_transaction.PaginationDTO{Page: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if response.Total != 3 || len(response.Transactions) != 2 {
+		t.Errorf("expected 2 of 3 transactions, got %d of %d", len(response.Transactions), response.Total)
+	}
+
+	if response.Transactions[0].Name != "E-COMMERCE A" {
+		t.Errorf("expected newest transaction first, got %s", response.Transactions[0].Name)
+	}
+
+	balance, err := service.GetUserBalance(aliceID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if balance.Balance != 11750000 {
+		t.Errorf("expected balance 11750000, got %d", balance.Balance)
+	}
+
+	if _, err := service.GetUserTransactions("nobody", dto_transaction.PaginationDTO{}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Error("expected an unknown user to be rejected")
+	}
+}
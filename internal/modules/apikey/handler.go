@@ -0,0 +1,54 @@
+package apikey
+
+import (
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/dto"
+	dto_apikey "firstpersoncode/go-uploader/dto/apikey"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiKeyHandler struct {
+	service domain.APIKeyService
+}
+
+func NewAPIKeyHandler(service domain.APIKeyService) domain.APIKeyHandler {
+	return &apiKeyHandler{service: service}
+}
+
+func (h *apiKeyHandler) Create(ctx *fiber.Ctx) error {
+	var request dto_apikey.CreateAPIKeyRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := h.service.CreateAPIKey(session.UserID, &request)
+	if err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.Status(201).JSON(dto.CreateSuccessResponse("API key created successfully, store it now as it won't be shown again", response))
+}
+
+func (h *apiKeyHandler) List(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := h.service.ListAPIKeys(session.UserID)
+	if err != nil {
+		return ctx.Status(500).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("API keys retrieved successfully", response))
+}
+
+func (h *apiKeyHandler) Revoke(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := h.service.RevokeAPIKey(session.UserID, ctx.Params("id")); err != nil {
+		return ctx.Status(404).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("API key revoked successfully", map[string]interface{}{}))
+}
@@ -0,0 +1,131 @@
+package apikey
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_apikey "firstpersoncode/go-uploader/dto/apikey"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type apiKeyService struct {
+	repo domain.APIKeyRepository
+}
+
+func NewAPIKeyService(repo domain.APIKeyRepository) domain.APIKeyService {
+	return &apiKeyService{repo: repo}
+}
+
+func (s *apiKeyService) CreateAPIKey(userID string, request *dto_apikey.CreateAPIKeyRequestDTO) (*dto_apikey.CreateAPIKeyResponseDTO, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	scopes, err := parseScopes(request.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	rawKey := domain.APIKeyPrefix + util.GenerateRandomID() + util.GenerateRandomID()
+
+	key := &domain.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    rawKey[:len(domain.APIKeyPrefix)+8],
+		Hash:      util.HashToken(rawKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	newKey, err := s.repo.Save(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %v", err)
+	}
+
+	return &dto_apikey.CreateAPIKeyResponseDTO{
+		APIKeyDTO: toAPIKeyDTO(newKey),
+		Key:       rawKey,
+	}, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(userID string) ([]dto_apikey.APIKeyDTO, error) {
+	keys, err := s.repo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api keys: %v", err)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	result := make([]dto_apikey.APIKeyDTO, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, toAPIKeyDTO(key))
+	}
+
+	return result, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(userID string, keyID string) error {
+	key, err := s.repo.FindByID(keyID)
+	if err != nil || key.UserID != userID {
+		return fmt.Errorf("api key not found")
+	}
+
+	revoked := *key
+	revoked.Revoked = true
+
+	if _, err := s.repo.Update(&revoked); err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+
+	return nil
+}
+
+func parseScopes(values []string) ([]domain.Scope, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	scopes := make([]domain.Scope, 0, len(values))
+	for _, value := range values {
+		scope := domain.Scope(strings.ToLower(strings.TrimSpace(value)))
+
+		grantable := false
+		for _, allowed := range domain.GrantableAPIKeyScopes {
+			if scope == allowed {
+				grantable = true
+				break
+			}
+		}
+
+		if !grantable {
+			return nil, fmt.Errorf("invalid scope: %s", value)
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, nil
+}
+
+func toAPIKeyDTO(key *domain.APIKey) dto_apikey.APIKeyDTO {
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return dto_apikey.APIKeyDTO{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		Revoked:    key.Revoked,
+	}
+}
@@ -0,0 +1,143 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_apikey "firstpersoncode/go-uploader/dto/apikey"
+	"firstpersoncode/go-uploader/internal/repositories"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+func setupTestService() (domain.APIKeyService, domain.APIKeyRepository, string) {
+	repo := repositories.NewAPIKeyRepository()
+	service := NewAPIKeyService(repo)
+	userID := "tester"
+	return service, repo, userID
+}
+
+func TestCreateAPIKey_Success(t *testing.T) {
+	service, repo, userID := setupTestService()
+
+	response, err := service.CreateAPIKey(userID, &dto_apikey.CreateAPIKeyRequestDTO{
+		Name:   "nightly import",
+		Scopes: []string{"upload"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(response.Key, domain.APIKeyPrefix) {
+		t.Errorf("expected key to start with %s, got %s", domain.APIKeyPrefix, response.Key)
+	}
+
+	if !strings.HasPrefix(response.Key, response.Prefix) {
+		t.Errorf("expected prefix %s to identify the key", response.Prefix)
+	}
+
+	stored, err := repo.FindByID(response.ID)
+	if err != nil {
+		t.Fatalf("expected key to be stored, got %v", err)
+	}
+
+	if stored.Hash == response.Key || strings.Contains(stored.Hash, response.Key) {
+		t.Error("expected only a hash of the key to be stored")
+	}
+
+	found, err := repo.FindByHash(util.HashToken(response.Key))
+	if err != nil || found.ID != response.ID {
+		t.Error("expected key to be found by its hash")
+	}
+
+	if len(stored.Scopes) != 1 || stored.Scopes[0] != domain.ScopeUpload {
+		t.Errorf("expected scopes [upload], got %v", stored.Scopes)
+	}
+}
+
+func TestCreateAPIKey_InvalidScopes(t *testing.T) {
+	service, _, userID := setupTestService()
+
+	cases := map[string][]string{
+		"no scopes":     nil,
+		"unknown scope": {"delete-everything"},
+		"account scope": {"read", "account"},
+	}
+
+	for name, scopes := range cases {
+		_, err := service.CreateAPIKey(userID, &dto_apikey.CreateAPIKeyRequestDTO{Name: "key", Scopes: scopes})
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	if _, err := service.CreateAPIKey(userID, &dto_apikey.CreateAPIKeyRequestDTO{Scopes: []string{"read"}}); err == nil {
+		t.Error("expected error for missing name, got nil")
+	}
+}
+
+func TestListAPIKeys(t *testing.T) {
+	service, _, userID := setupTestService()
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := service.CreateAPIKey(userID, &dto_apikey.CreateAPIKeyRequestDTO{Name: name, Scopes: []string{"read"}}); err != nil {
+			t.Fatalf("failed to create key: %v", err)
+		}
+	}
+
+	if _, err := service.CreateAPIKey("someone-else", &dto_apikey.CreateAPIKeyRequestDTO{Name: "other", Scopes: []string{"read"}}); err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	keys, err := service.ListAPIKeys(userID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(keys) != 2 {
+		t.Errorf("expected 2 keys, got %d", len(keys))
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	service, repo, userID := setupTestService()
+
+	response, err := service.CreateAPIKey(userID, &dto_apikey.CreateAPIKeyRequestDTO{Name: "key", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	if err := service.RevokeAPIKey("someone-else", response.ID); err == nil {
+		t.Fatal("expected error when revoking another user's key, got nil")
+	}
+
+	if err := service.RevokeAPIKey(userID, response.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored, _ := repo.FindByID(response.ID)
+	if !stored.Revoked {
+		t.Error("expected key to be revoked")
+	}
+}
+
+func TestSessionHasScope(t *testing.T) {
+	signedIn := &domain.Session{UserID: "tester"}
+	if !signedIn.HasScope(domain.ScopeAccount) {
+		t.Error("expected signed-in sessions to have every scope")
+	}
+
+	readOnly := &domain.Session{UserID: "tester", APIKeyID: "key", Scopes: []domain.Scope{domain.ScopeRead}}
+	if !readOnly.HasScope(domain.ScopeRead) {
+		t.Error("expected read-only key to have the read scope")
+	}
+
+	if readOnly.HasScope(domain.ScopeUpload) || readOnly.HasScope(domain.ScopeAccount) {
+		t.Error("expected read-only key to be limited to the read scope")
+	}
+
+	uploadOnly := &domain.Session{UserID: "tester", APIKeyID: "key", Scopes: []domain.Scope{domain.ScopeUpload}}
+	if uploadOnly.HasScope(domain.ScopeWrite) {
+		t.Error("expected an upload key not to be able to change or delete what is stored")
+	}
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"time"
 
 	"firstpersoncode/go-uploader/domain"
@@ -41,40 +44,128 @@ func (h *authHandler) SignIn(ctx *fiber.Ctx) error {
 		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
 	}
 
+	credentials.IP = ctx.IP()
+	credentials.UserAgent = ctx.Get(fiber.HeaderUserAgent)
+
 	token_dto, err := h.service.CreateSession(&credentials)
 	if err != nil {
-		return ctx.Status(401).JSON(dto.CreateErrorResponse(err.Error()))
+		return signInError(ctx, err)
 	}
 
-	cookie := &fiber.Cookie{
-		Name:     config.Get().App.CookieName,
-		Value:    token_dto.Token,
-		Expires:  token_dto.Expiry,
-		HTTPOnly: true,
-		Secure:   false,
-		SameSite: "Lax",
+	if token_dto.MFARequired {
+		return ctx.JSON(dto.CreateSuccessResponse("MFA code required", token_dto))
 	}
 
-	ctx.Cookie(cookie)
+	setSessionCookies(ctx, token_dto)
 
 	return ctx.JSON(dto.CreateSuccessResponse("Signed in successfully", token_dto))
 }
 
-func (h *authHandler) SignOut(ctx *fiber.Ctx) error {
-	cookie := &fiber.Cookie{
-		Name:     config.Get().App.CookieName,
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HTTPOnly: true,
-		Secure:   false,
-		SameSite: "Lax",
+func (h *authHandler) SignInMFA(ctx *fiber.Ctx) error {
+	var request dto_session.MFASignInRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	request.IP = ctx.IP()
+	request.UserAgent = ctx.Get(fiber.HeaderUserAgent)
+
+	token_dto, err := h.service.CompleteMFASignIn(&request)
+	if err != nil {
+		return signInError(ctx, err)
 	}
 
-	ctx.Cookie(cookie)
+	setSessionCookies(ctx, token_dto)
+
+	return ctx.JSON(dto.CreateSuccessResponse("Signed in successfully", token_dto))
+}
+
+func (h *authHandler) SetupMFA(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := h.service.SetupMFA(session.UserID)
+	if err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("MFA setup started, confirm it with a code from your authenticator app", response))
+}
+
+func (h *authHandler) VerifyMFA(ctx *fiber.Ctx) error {
+	var request dto_session.MFAVerifyRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := h.service.VerifyMFA(session.UserID, request.Code)
+	if err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("MFA enabled successfully, store the recovery codes safely", response))
+}
+
+// SignOut signs out with the refresh token, from the refresh cookie or the
+// request body, so a client whose access token has expired can still sign
+// out. Without one, the request's own session is signed out.
+func (h *authHandler) SignOut(ctx *fiber.Ctx) error {
+	if status, err := h.signOut(ctx, false); err != nil {
+		return ctx.Status(status).JSON(dto.CreateErrorResponse(err.Error()))
+	}
 
 	return ctx.JSON(fiber.Map{"message": "Signed out successfully"})
 }
 
+func (h *authHandler) SignOutAll(ctx *fiber.Ctx) error {
+	if status, err := h.signOut(ctx, true); err != nil {
+		return ctx.Status(status).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(fiber.Map{"message": "Signed out of all sessions successfully"})
+}
+
+// signOut revokes the sessions SignOut or SignOutAll end and clears the
+// session cookies, or returns why it can't with the status to answer with.
+func (h *authHandler) signOut(ctx *fiber.Ctx, everywhere bool) (int, error) {
+	refreshToken := ctx.Cookies(config.Get().App.RefreshCookieName)
+	if refreshToken == "" && len(ctx.Body()) > 0 {
+		var request dto_session.RefreshRequestDTO
+		if err := ctx.BodyParser(&request); err != nil {
+			return 400, errors.New("Invalid request body")
+		}
+		refreshToken = request.RefreshToken
+	}
+
+	session, _ := ctx.Locals("session").(*domain.Session)
+
+	var err error
+	switch {
+	case refreshToken != "":
+		err = h.service.SignOutRefreshToken(refreshToken, everywhere)
+	case session == nil:
+		return 401, errors.New("Unauthorized: No session or refresh token")
+	case !session.HasScope(domain.ScopeAccount):
+		return 403, errors.New("Forbidden: API key lacks the " + string(domain.ScopeAccount) + " scope")
+	case everywhere:
+		err = h.service.RevokeAllSessions(session.UserID)
+	default:
+		err = h.service.RevokeSession(session)
+	}
+
+	clearSessionCookies(ctx)
+
+	if errors.Is(err, domain.ErrInvalidRefreshToken) {
+		return 401, err
+	}
+	if err != nil {
+		return 500, err
+	}
+
+	return 0, nil
+}
+
 func (h *authHandler) Session(ctx *fiber.Ctx) error {
 	session_dto, err := h.service.GetUserSession(ctx.Locals("session").(*domain.Session))
 	if err != nil {
@@ -85,5 +176,143 @@ func (h *authHandler) Session(ctx *fiber.Ctx) error {
 }
 
 func (h *authHandler) Refresh(ctx *fiber.Ctx) error {
-	return ctx.Status(501).JSON(fiber.Map{"error": "Not implemented"})
+	refreshToken := ctx.Cookies(config.Get().App.RefreshCookieName)
+	if refreshToken == "" {
+		var request dto_session.RefreshRequestDTO
+		if err := ctx.BodyParser(&request); err != nil {
+			return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+		}
+		refreshToken = request.RefreshToken
+	}
+
+	token_dto, err := h.service.RefreshToken(refreshToken)
+	if err != nil {
+		clearSessionCookies(ctx)
+		return ctx.Status(401).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	setSessionCookies(ctx, token_dto)
+
+	return ctx.JSON(dto.CreateSuccessResponse("Token refreshed successfully", token_dto))
+}
+
+func (h *authHandler) ListSessions(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	sessions, err := h.service.ListSessions(session)
+	if err != nil {
+		return ctx.Status(500).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Sessions retrieved successfully", sessions))
+}
+
+func (h *authHandler) DeleteSession(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := h.service.RevokeUserSession(session.UserID, ctx.Params("id")); err != nil {
+		return ctx.Status(404).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Session revoked successfully", map[string]interface{}{}))
+}
+
+func (h *authHandler) JWKS(ctx *fiber.Ctx) error {
+	jwks, err := h.service.GetJWKS()
+	if err != nil {
+		return ctx.Status(500).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	// Served bare, in the standard JWKS shape, so off-the-shelf JWT libraries
+	// in other services can consume it directly.
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return ctx.JSON(jwks)
+}
+
+func (h *authHandler) ChangePassword(ctx *fiber.Ctx) error {
+	var request dto_session.PasswordChangeRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := h.service.ChangePassword(session, &request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Password changed successfully", map[string]interface{}{}))
+}
+
+func (h *authHandler) RequestPasswordReset(ctx *fiber.Ctx) error {
+	var request dto_session.PasswordResetRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	if err := h.service.RequestPasswordReset(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("If the account exists, a password reset token has been sent", map[string]interface{}{}))
+}
+
+func (h *authHandler) ConfirmPasswordReset(ctx *fiber.Ctx) error {
+	var request dto_session.PasswordResetConfirmDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	if err := h.service.ConfirmPasswordReset(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Password reset successfully", map[string]interface{}{}))
+}
+
+func signInError(ctx *fiber.Ctx, err error) error {
+	var lockout *domain.LockoutError
+	if errors.As(err, &lockout) {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		return ctx.Status(429).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.Status(401).JSON(dto.CreateErrorResponse(err.Error()))
+}
+
+func setSessionCookies(ctx *fiber.Ctx, token_dto *dto_session.TokenResponseDTO) {
+	appConfig := config.Get().App
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     appConfig.CookieName,
+		Value:    token_dto.Token,
+		Expires:  token_dto.Expiry,
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     appConfig.RefreshCookieName,
+		Value:    token_dto.RefreshToken,
+		Expires:  token_dto.RefreshExpiry,
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+}
+
+func clearSessionCookies(ctx *fiber.Ctx) {
+	appConfig := config.Get().App
+
+	for _, name := range []string{appConfig.CookieName, appConfig.RefreshCookieName} {
+		ctx.Cookie(&fiber.Cookie{
+			Name:     name,
+			Value:    "",
+			Expires:  time.Now().Add(-1 * time.Hour),
+			HTTPOnly: true,
+			Secure:   false,
+			SameSite: "Lax",
+		})
+	}
 }
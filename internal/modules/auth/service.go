@@ -1,25 +1,67 @@
 package auth
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"firstpersoncode/go-uploader/domain"
 	dto_session "firstpersoncode/go-uploader/dto/session"
+	"firstpersoncode/go-uploader/internal/config"
 	"firstpersoncode/go-uploader/internal/util"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+	// Failed attempts allowed before exponential backoff kicks in, so a
+	// single typo doesn't make the user wait.
+	backoffFreeAttempts = 2
+
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaTokenPurpose      = "mfa"
+	mfaRecoveryCodeCount = 10
+)
+
 type authService struct {
+	mu          sync.Mutex
+	attemptsMu  sync.Mutex
+	mfaMu       sync.Mutex
 	userRepo    domain.UserRepository
 	sessionRepo domain.SessionRepository
+	attemptRepo domain.LoginAttemptRepository
+	eventRepo   domain.AuthEventRepository
+	resetRepo   domain.PasswordResetRepository
+	notifier    domain.Notifier
+	policy      config.Auth
+	passwords   config.Password
 }
 
-func NewAuthService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository) domain.SessionService {
+func NewAuthService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, attemptRepo domain.LoginAttemptRepository, eventRepo domain.AuthEventRepository, resetRepo domain.PasswordResetRepository, notifier domain.Notifier) domain.SessionService {
+	cfg := config.Get()
+
 	return &authService{
 		userRepo:    userRepo,
 		sessionRepo: sessionRepo,
+		attemptRepo: attemptRepo,
+		eventRepo:   eventRepo,
+		resetRepo:   resetRepo,
+		notifier:    notifier,
+		policy:      cfg.Auth,
+		passwords:   cfg.Password,
 	}
 }
 
@@ -28,6 +70,10 @@ func (s *authService) RegisterUser(credentials *dto_session.TokenRequestDTO) err
 		return fmt.Errorf("username and password are required")
 	}
 
+	if err := s.validatePassword(credentials.Password); err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %v", err)
@@ -36,6 +82,7 @@ func (s *authService) RegisterUser(credentials *dto_session.TokenRequestDTO) err
 	user := &domain.User{
 		Username: credentials.Username,
 		Password: string(hashedPassword),
+		Role:     domain.RoleUser,
 	}
 
 	if _, err := s.userRepo.Save(user); err != nil {
@@ -50,36 +97,211 @@ func (s *authService) CreateSession(credentials *dto_session.TokenRequestDTO) (*
 		return nil, fmt.Errorf("username and password are required")
 	}
 
+	reservation, err := s.reserveAttempt(credentials)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByUsername(credentials.Username)
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, s.recordFailure(reservation, "", "unknown username")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, s.recordFailure(reservation, user.ID, "wrong password")
 	}
 
-	session := &domain.Session{
-		UserID: user.ID,
+	if user.Disabled {
+		s.releaseAttempt(reservation, false)
+		s.recordEvent(domain.AuthEventSignInFailure, credentials, user.ID, "account disabled")
+		return nil, fmt.Errorf("account is disabled")
 	}
 
-	newSession, err := s.sessionRepo.Save(session)
+	// The failure counter is left alone until the second factor is in too,
+	// so the code itself can't be brute forced behind a known password.
+	if user.MFAEnabled {
+		s.releaseAttempt(reservation, false)
+
+		expiry := time.Now().Add(mfaChallengeTTL)
+		mfaToken, err := util.GeneratePurposeJWT(user.ID, mfaTokenPurpose, expiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate token: %v", err)
+		}
+
+		return &dto_session.TokenResponseDTO{
+			Expiry:      expiry,
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+
+	s.recordSuccess(reservation, user.ID)
+
+	return s.startSession(user, credentials.IP, credentials.UserAgent)
+}
+
+func (s *authService) CompleteMFASignIn(request *dto_session.MFASignInRequestDTO) (*dto_session.TokenResponseDTO, error) {
+	if request.MFAToken == "" || request.Code == "" {
+		return nil, fmt.Errorf("mfa token and code are required")
+	}
+
+	claims, err := util.ValidatePurposeJWT(request.MFAToken, mfaTokenPurpose)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %v", err)
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	user, err := s.userRepo.FindByID(claims.Sub)
+	if err != nil || !user.MFAEnabled || user.Disabled {
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	credentials := &dto_session.TokenRequestDTO{
+		Username:  user.Username,
+		IP:        request.IP,
+		UserAgent: request.UserAgent,
 	}
 
-	expiry := time.Now().Add(24 * time.Hour)
-	token, err := util.GenerateJWT(newSession.ID, expiry)
+	reservation, err := s.reserveAttempt(credentials)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %v", err)
+		return nil, err
 	}
 
-	return &dto_session.TokenResponseDTO{
-		Token:  token,
-		Expiry: expiry,
+	s.mfaMu.Lock()
+	defer s.mfaMu.Unlock()
+
+	// Reload under the lock so two requests can't both spend the same code.
+	user, err = s.userRepo.FindByID(claims.Sub)
+	if err != nil {
+		s.releaseAttempt(reservation, false)
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	updated, ok := consumeMFACode(user, request.Code)
+	if !ok {
+		return nil, s.recordFailure(reservation, user.ID, "wrong mfa code")
+	}
+
+	if _, err := s.userRepo.Update(updated); err != nil {
+		s.releaseAttempt(reservation, false)
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	s.recordSuccess(reservation, user.ID)
+
+	return s.startSession(updated, request.IP, request.UserAgent)
+}
+
+func (s *authService) SetupMFA(userID string) (*dto_session.MFASetupResponseDTO, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %v", err)
+	}
+
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("mfa is already enabled")
+	}
+
+	updated := *user
+	updated.MFASecret = util.GenerateTOTPSecret()
+
+	if _, err := s.userRepo.Update(&updated); err != nil {
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	return &dto_session.MFASetupResponseDTO{
+		Secret: updated.MFASecret,
+		URI:    util.TOTPURI(s.policy.MFAIssuer, updated.Username, updated.MFASecret),
 	}, nil
 }
 
+func (s *authService) VerifyMFA(userID string, code string) (*dto_session.MFAVerifyResponseDTO, error) {
+	s.mfaMu.Lock()
+	defer s.mfaMu.Unlock()
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %v", err)
+	}
+
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("mfa is already enabled")
+	}
+
+	if user.MFASecret == "" {
+		return nil, fmt.Errorf("mfa setup has not been started")
+	}
+
+	step, ok := util.ValidateTOTP(user.MFASecret, code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("invalid mfa code")
+	}
+
+	recoveryCodes := make([]string, 0, mfaRecoveryCodeCount)
+	hashedCodes := make([]string, 0, mfaRecoveryCodeCount)
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		raw := util.GenerateRandomID()
+		recoveryCode := raw[:5] + "-" + raw[5:10]
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		hashedCodes = append(hashedCodes, util.HashToken(recoveryCode))
+	}
+
+	updated := *user
+	updated.MFAEnabled = true
+	updated.MFALastUsedStep = step
+	updated.MFARecoveryCodes = hashedCodes
+
+	if _, err := s.userRepo.Update(&updated); err != nil {
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	return &dto_session.MFAVerifyResponseDTO{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// consumeMFACode checks code as a TOTP code first and as a recovery code
+// second, returning the user with the code marked as spent.
+func consumeMFACode(user *domain.User, code string) (*domain.User, bool) {
+	updated := *user
+
+	if step, ok := util.ValidateTOTP(user.MFASecret, code, time.Now()); ok {
+		if step <= user.MFALastUsedStep {
+			return nil, false
+		}
+		updated.MFALastUsedStep = step
+		return &updated, true
+	}
+
+	hashed := util.HashToken(strings.ToLower(strings.TrimSpace(code)))
+	for i, recoveryCode := range user.MFARecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(recoveryCode), []byte(hashed)) == 1 {
+			updated.MFARecoveryCodes = append(append([]string{}, user.MFARecoveryCodes[:i]...), user.MFARecoveryCodes[i+1:]...)
+			return &updated, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *authService) startSession(user *domain.User, ip string, userAgent string) (*dto_session.TokenResponseDTO, error) {
+	now := time.Now()
+	session := &domain.Session{
+		UserID:        user.ID,
+		RefreshExpiry: now.Add(refreshTokenTTL),
+		CreatedAt:     now,
+		LastSeenAt:    now,
+		IP:            ip,
+		UserAgent:     userAgent,
+	}
+
+	newSession, err := s.sessionRepo.Save(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	return s.issueTokens(newSession)
+}
+
 func (s *authService) GetUserSession(session *domain.Session) (*dto_session.UserSessionDto, error) {
 	user, err := s.userRepo.FindByID(session.UserID)
 	if err != nil {
@@ -92,11 +314,586 @@ func (s *authService) GetUserSession(session *domain.Session) (*dto_session.User
 		User: dto_session.UserDto{
 			ID:       user.ID,
 			Username: user.Username,
+			Role:     string(user.Role),
 		},
 		RefreshToken: session.RefreshToken,
 	}, nil
 }
 
 func (s *authService) RefreshToken(refreshToken string) (*dto_session.TokenResponseDTO, error) {
-	return nil, fmt.Errorf("not implemented")
+	if refreshToken == "" {
+		return nil, fmt.Errorf("refresh token is required")
+	}
+
+	// Serialize rotations so two requests racing with the same token cannot
+	// both be accepted before one of them marks it as used.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessionRepo.FindByRefreshToken(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	// A revoked session still holding this token means it was already rotated,
+	// so whoever presents it again may have stolen it: kill the whole family.
+	if session.Revoked {
+		if err := s.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke sessions: %v", err)
+		}
+		return nil, fmt.Errorf("refresh token reuse detected")
+	}
+
+	if time.Now().After(session.RefreshExpiry) {
+		session.Revoked = true
+		if _, err := s.sessionRepo.Update(session); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %v", err)
+		}
+		return nil, fmt.Errorf("refresh token expired")
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to find user: %v", err)
+	}
+
+	if err != nil || user.Disabled {
+		if err := s.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke sessions: %v", err)
+		}
+		return nil, fmt.Errorf("account is disabled")
+	}
+
+	session.Revoked = true
+	if _, err := s.sessionRepo.Update(session); err != nil {
+		return nil, fmt.Errorf("failed to rotate session: %v", err)
+	}
+
+	// The rotated session represents the same device, so it keeps the
+	// original sign-in time and client details.
+	rotated := &domain.Session{
+		UserID:        session.UserID,
+		FamilyID:      session.FamilyID,
+		RefreshExpiry: time.Now().Add(refreshTokenTTL),
+		CreatedAt:     session.CreatedAt,
+		LastSeenAt:    time.Now(),
+		IP:            session.IP,
+		UserAgent:     session.UserAgent,
+	}
+
+	newSession, err := s.sessionRepo.Save(rotated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	return s.issueTokens(newSession)
+}
+
+func (s *authService) RevokeSession(session *domain.Session) error {
+	if err := s.sessionRepo.Delete(session.ID); err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+
+	return nil
+}
+
+func (s *authService) RevokeAllSessions(userID string) error {
+	if err := s.sessionRepo.DeleteByUserID(userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	return nil
+}
+
+func (s *authService) SignOutRefreshToken(refreshToken string, everywhere bool) error {
+	if refreshToken == "" {
+		return domain.ErrInvalidRefreshToken
+	}
+
+	// A rotation in progress would otherwise save its new session after
+	// the family was revoked.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessionRepo.FindByRefreshToken(refreshToken)
+	if err != nil {
+		return domain.ErrInvalidRefreshToken
+	}
+
+	// Signing out everywhere takes a token that could still be refreshed;
+	// a rotated or expired one only ends the sessions descended from it.
+	if everywhere {
+		if session.Revoked || time.Now().After(session.RefreshExpiry) {
+			return domain.ErrInvalidRefreshToken
+		}
+		return s.RevokeAllSessions(session.UserID)
+	}
+
+	if err := s.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	return nil
+}
+
+func (s *authService) ListSessions(current *domain.Session) ([]dto_session.SessionDTO, error) {
+	sessions, err := s.sessionRepo.FindByUserID(current.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find sessions: %v", err)
+	}
+
+	now := time.Now()
+	result := make([]dto_session.SessionDTO, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Revoked || now.After(session.RefreshExpiry) {
+			continue
+		}
+
+		result = append(result, dto_session.SessionDTO{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			Current:    session.ID == current.ID,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeenAt.After(result[j].LastSeenAt)
+	})
+
+	return result, nil
+}
+
+func (s *authService) RevokeUserSession(userID string, sessionID string) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return fmt.Errorf("session not found")
+	}
+
+	return s.RevokeSession(session)
+}
+
+func (s *authService) GetJWKS() (*dto_session.JWKSDTO, error) {
+	publicKeys, err := util.JWTPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %v", err)
+	}
+
+	jwks := &dto_session.JWKSDTO{Keys: make([]dto_session.JWKDTO, 0, len(publicKeys))}
+	for _, publicKey := range publicKeys {
+		jwk := dto_session.JWKDTO{
+			Use: "sig",
+			Kid: publicKey.ID,
+			Alg: publicKey.Algorithm,
+		}
+
+		switch key := publicKey.Key.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(key)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+func (s *authService) ChangePassword(session *domain.Session, request *dto_session.PasswordChangeRequestDTO) error {
+	if request.CurrentPassword == "" || request.NewPassword == "" {
+		return fmt.Errorf("current and new password are required")
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to find user: %v", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.CurrentPassword)); err != nil {
+		return fmt.Errorf("current password is incorrect")
+	}
+
+	if err := s.setPassword(user, request.NewPassword); err != nil {
+		return err
+	}
+
+	// Everyone else holding a session for this account is signed out, the
+	// device that made the change stays signed in.
+	sessions, err := s.sessionRepo.FindByUserID(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find sessions: %v", err)
+	}
+
+	for _, other := range sessions {
+		if other.ID == session.ID {
+			continue
+		}
+
+		if err := s.sessionRepo.Delete(other.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %v", err)
+		}
+	}
+
+	s.recordEvent(domain.AuthEventPasswordChanged, &dto_session.TokenRequestDTO{Username: user.Username, IP: session.IP, UserAgent: session.UserAgent}, user.ID, "")
+
+	return nil
+}
+
+func (s *authService) RequestPasswordReset(request *dto_session.PasswordResetRequestDTO) error {
+	if request.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+
+	// Unknown usernames succeed silently so the endpoint can't be used to
+	// find out which accounts exist.
+	user, err := s.userRepo.FindByUsername(request.Username)
+	if err != nil {
+		return nil
+	}
+
+	// Only the newest token is valid
+	if err := s.resetRepo.DeleteByUserID(user.ID); err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens: %v", err)
+	}
+
+	rawToken := util.GenerateRandomID() + util.GenerateRandomID()
+	now := time.Now()
+
+	token := &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(rawToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.passwords.ResetTokenTTL),
+	}
+
+	if _, err := s.resetRepo.Save(token); err != nil {
+		return fmt.Errorf("failed to create reset token: %v", err)
+	}
+
+	if err := s.notifier.SendPasswordReset(user, rawToken, token.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to send reset token: %v", err)
+	}
+
+	s.recordEvent(domain.AuthEventPasswordResetRequested, &dto_session.TokenRequestDTO{Username: user.Username}, user.ID, "")
+
+	return nil
+}
+
+func (s *authService) ConfirmPasswordReset(request *dto_session.PasswordResetConfirmDTO) error {
+	if request.Token == "" || request.NewPassword == "" {
+		return fmt.Errorf("token and new password are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.resetRepo.FindByHash(util.HashToken(request.Token))
+	if err != nil || token.Used || time.Now().After(token.ExpiresAt) {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	if err := s.setPassword(user, request.NewPassword); err != nil {
+		return err
+	}
+
+	used := *token
+	used.Used = true
+	if _, err := s.resetRepo.Update(&used); err != nil {
+		return fmt.Errorf("failed to consume reset token: %v", err)
+	}
+
+	if err := s.sessionRepo.DeleteByUserID(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	// Proving control of the account lifts a lockout on the username
+	credentials := &dto_session.TokenRequestDTO{Username: user.Username}
+	s.attemptRepo.Delete(s.attemptKeys(credentials)[0].key)
+
+	s.recordEvent(domain.AuthEventPasswordReset, credentials, user.ID, "")
+
+	return nil
+}
+
+func (s *authService) setPassword(user *domain.User, password string) error {
+	if err := s.validatePassword(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	updated := *user
+	updated.Password = string(hashedPassword)
+
+	if _, err := s.userRepo.Update(&updated); err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	return nil
+}
+
+func (s *authService) validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < s.passwords.MinLength {
+		return fmt.Errorf("password must be at least %d characters", s.passwords.MinLength)
+	}
+
+	// bcrypt silently ignores everything past 72 bytes
+	if len(password) > 72 {
+		return fmt.Errorf("password must be at most 72 bytes")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if s.passwords.RequireUpper && !hasUpper {
+		return fmt.Errorf("password must contain an uppercase letter")
+	}
+	if s.passwords.RequireLower && !hasLower {
+		return fmt.Errorf("password must contain a lowercase letter")
+	}
+	if s.passwords.RequireDigit && !hasDigit {
+		return fmt.Errorf("password must contain a digit")
+	}
+	if s.passwords.RequireSymbol && !hasSymbol {
+		return fmt.Errorf("password must contain a symbol")
+	}
+
+	return nil
+}
+
+type attemptKey struct {
+	key         string
+	maxFailures int
+}
+
+func (s *authService) attemptKeys(credentials *dto_session.TokenRequestDTO) []attemptKey {
+	keys := []attemptKey{{
+		key:         "username:" + strings.ToLower(credentials.Username),
+		maxFailures: s.policy.MaxFailedAttempts,
+	}}
+
+	if credentials.IP != "" {
+		keys = append(keys, attemptKey{
+			key:         "ip:" + credentials.IP,
+			maxFailures: s.policy.IPMaxFailedAttempts,
+		})
+	}
+
+	return keys
+}
+
+// attemptReservation is a sign-in attempt counted as failed before the
+// credentials are checked, so attempts made in parallel can't all get past
+// the throttle before any of them fails.
+type attemptReservation struct {
+	credentials *dto_session.TokenRequestDTO
+	// previous holds each key's record from before the attempt, or nil,
+	// and reserved the record the attempt left.
+	previous map[string]*domain.LoginAttempt
+	reserved map[string]domain.LoginAttempt
+	// locked lists the keys the attempt locked out, should it fail.
+	locked []string
+}
+
+// reserveAttempt refuses the attempt while the username or the client IP is
+// locked out, or still inside the backoff window of its last failure, and
+// otherwise counts it as failed until it is released. Both happen under one
+// lock.
+func (s *authService) reserveAttempt(credentials *dto_session.TokenRequestDTO) (*attemptReservation, error) {
+	s.attemptsMu.Lock()
+	defer s.attemptsMu.Unlock()
+
+	reservation := &attemptReservation{
+		credentials: credentials,
+		previous:    make(map[string]*domain.LoginAttempt),
+		reserved:    make(map[string]domain.LoginAttempt),
+	}
+
+	now := time.Now()
+	keys := s.attemptKeys(credentials)
+	attempts := make([]*domain.LoginAttempt, len(keys))
+
+	for index, key := range keys {
+		attempt, err := s.attemptRepo.FindByKey(key.key)
+		if err != nil {
+			attempts[index] = &domain.LoginAttempt{Key: key.key}
+			continue
+		}
+
+		reservation.previous[key.key] = attempt
+		if s.isStale(attempt, now) {
+			attempts[index] = &domain.LoginAttempt{Key: key.key}
+			continue
+		}
+
+		retryAt := attempt.LastFailure.Add(s.backoff(attempt.Failures))
+		if attempt.LockedUntil.After(retryAt) {
+			retryAt = attempt.LockedUntil
+		}
+
+		if now.Before(retryAt) {
+			s.recordEvent(domain.AuthEventSignInThrottled, credentials, "", key.key)
+			return nil, &domain.LockoutError{RetryAfter: retryAt.Sub(now)}
+		}
+
+		counted := *attempt
+		attempts[index] = &counted
+	}
+
+	for index, key := range keys {
+		attempt := attempts[index]
+		attempt.Failures++
+		attempt.LastFailure = now
+
+		if key.maxFailures > 0 && attempt.Failures >= key.maxFailures {
+			attempt.Failures = 0
+			attempt.LockedUntil = now.Add(s.policy.LockoutDuration)
+			reservation.locked = append(reservation.locked, key.key)
+		}
+
+		if err := s.attemptRepo.Save(attempt); err != nil {
+			log.Printf("Failed to save login attempt for %s: %v", key.key, err)
+		}
+		reservation.reserved[key.key] = *attempt
+	}
+
+	return reservation, nil
+}
+
+// releaseAttempt takes back a reservation. Each key's record goes back to
+// what it was, unless another attempt has counted on it since, in which case
+// only this attempt is deducted. A sign-in also clears the username's
+// failures, but only those: signing in to one account must not wipe the
+// failures an IP racked up against others.
+func (s *authService) releaseAttempt(reservation *attemptReservation, signedIn bool) {
+	s.attemptsMu.Lock()
+	defer s.attemptsMu.Unlock()
+
+	for index, key := range s.attemptKeys(reservation.credentials) {
+		if index == 0 && signedIn {
+			s.attemptRepo.Delete(key.key)
+			continue
+		}
+
+		current, err := s.attemptRepo.FindByKey(key.key)
+		if err != nil {
+			continue
+		}
+
+		reserved := reservation.reserved[key.key]
+		switch previous := reservation.previous[key.key]; {
+		case current.Failures != reserved.Failures || !current.LastFailure.Equal(reserved.LastFailure) || !current.LockedUntil.Equal(reserved.LockedUntil):
+			if current.Failures > 0 {
+				current.Failures--
+				s.attemptRepo.Save(current)
+			}
+		case previous != nil:
+			s.attemptRepo.Save(previous)
+		default:
+			s.attemptRepo.Delete(key.key)
+		}
+	}
+}
+
+// recordFailure keeps the reserved attempt as failed, and reports the
+// lockout it brought about, if any.
+func (s *authService) recordFailure(reservation *attemptReservation, userID string, reason string) error {
+	s.recordEvent(domain.AuthEventSignInFailure, reservation.credentials, userID, reason)
+
+	if len(reservation.locked) == 0 {
+		return fmt.Errorf("invalid credentials")
+	}
+
+	for _, key := range reservation.locked {
+		s.recordEvent(domain.AuthEventAccountLocked, reservation.credentials, userID, key)
+	}
+
+	return &domain.LockoutError{RetryAfter: s.policy.LockoutDuration}
+}
+
+func (s *authService) recordSuccess(reservation *attemptReservation, userID string) {
+	s.releaseAttempt(reservation, true)
+	s.recordEvent(domain.AuthEventSignInSuccess, reservation.credentials, userID, "")
+}
+
+func (s *authService) recordEvent(eventType domain.AuthEventType, credentials *dto_session.TokenRequestDTO, userID string, reason string) {
+	event := &domain.AuthEvent{
+		Type:      eventType,
+		UserID:    userID,
+		Username:  credentials.Username,
+		IP:        credentials.IP,
+		UserAgent: credentials.UserAgent,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := s.eventRepo.Save(event); err != nil {
+		log.Printf("Failed to save auth event: %v", err)
+	}
+}
+
+// isStale reports whether an attempt record is old enough to be forgotten,
+// so a handful of typos spread over weeks never add up to a lockout.
+func (s *authService) isStale(attempt *domain.LoginAttempt, now time.Time) bool {
+	return now.After(attempt.LockedUntil) && now.Sub(attempt.LastFailure) > s.policy.LockoutDuration
+}
+
+func (s *authService) backoff(failures int) time.Duration {
+	if failures <= backoffFreeAttempts || s.policy.BackoffBase <= 0 {
+		return 0
+	}
+
+	delay := s.policy.BackoffBase
+	for i := backoffFreeAttempts + 1; i < failures && delay < s.policy.LockoutDuration; i++ {
+		delay *= 2
+	}
+
+	if delay > s.policy.LockoutDuration {
+		delay = s.policy.LockoutDuration
+	}
+
+	return delay
+}
+
+func (s *authService) issueTokens(session *domain.Session) (*dto_session.TokenResponseDTO, error) {
+	expiry := time.Now().Add(accessTokenTTL)
+	token, err := util.GenerateJWT(session.ID, expiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	return &dto_session.TokenResponseDTO{
+		Token:         token,
+		Expiry:        expiry,
+		RefreshToken:  session.RefreshToken,
+		RefreshExpiry: session.RefreshExpiry,
+	}, nil
 }
@@ -1,25 +1,50 @@
 package auth
 
 import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"firstpersoncode/go-uploader/domain"
 	dto_session "firstpersoncode/go-uploader/dto/session"
-	"firstpersoncode/go-uploader/internal/repositories"
+	"firstpersoncode/go-uploader/internal/config"
+	"firstpersoncode/go-uploader/internal/repositories/repotest"
+	"firstpersoncode/go-uploader/internal/util"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func setupTestService() (domain.SessionService, domain.UserRepository, domain.SessionRepository) {
-	userRepo := repositories.NewUserRepository()
-	sessionRepo := repositories.NewSessionRepository()
-	service := NewAuthService(userRepo, sessionRepo)
+// TestMain lets the tokens be signed with an ephemeral key, as no JWT_KEYS
+// are configured for the tests.
+func TestMain(m *testing.M) {
+	os.Setenv("APP_ENV", config.EnvDevelopment)
+	os.Exit(m.Run())
+}
+
+type testNotifier struct {
+	resetTokens []string
+}
+
+func (n *testNotifier) SendPasswordReset(user *domain.User, token string, expiresAt time.Time) error {
+	n.resetTokens = append(n.resetTokens, token)
+	return nil
+}
+
+func setupTestService(t *testing.T) (domain.SessionService, domain.UserRepository, domain.SessionRepository) {
+	t.Helper()
+
+	storage := repotest.Open(t)
+	userRepo := storage.Users
+	sessionRepo := storage.Sessions
+	service := NewAuthService(userRepo, sessionRepo, storage.LoginAttempts, storage.AuthEvents, storage.PasswordResets, &testNotifier{})
 	return service, userRepo, sessionRepo
 }
 
 func TestRegisterUser_Success(t *testing.T) {
-	service, userRepo, _ := setupTestService()
+	service, userRepo, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "testuser",
@@ -49,7 +74,7 @@ func TestRegisterUser_Success(t *testing.T) {
 }
 
 func TestRegisterUser_EmptyUsername(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "",
@@ -67,7 +92,7 @@ func TestRegisterUser_EmptyUsername(t *testing.T) {
 }
 
 func TestRegisterUser_EmptyPassword(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "testuser",
@@ -85,7 +110,7 @@ func TestRegisterUser_EmptyPassword(t *testing.T) {
 }
 
 func TestRegisterUser_DuplicateUsername(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "testuser",
@@ -106,7 +131,7 @@ func TestRegisterUser_DuplicateUsername(t *testing.T) {
 }
 
 func TestCreateSession_Success(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	// First register a user
 	registerCredentials := &dto_session.TokenRequestDTO{
@@ -137,16 +162,24 @@ func TestCreateSession_Success(t *testing.T) {
 		t.Error("expected expiry to be in the future")
 	}
 
-	// Verify expiry is approximately 24 hours from now
-	expectedExpiry := time.Now().Add(24 * time.Hour)
+	// Verify the access token is short-lived
+	expectedExpiry := time.Now().Add(accessTokenTTL)
 	diff := tokenResponse.Expiry.Sub(expectedExpiry)
 	if diff > time.Minute || diff < -time.Minute {
-		t.Errorf("expected expiry to be ~24 hours from now, got %v", tokenResponse.Expiry)
+		t.Errorf("expected expiry to be ~%v from now, got %v", accessTokenTTL, tokenResponse.Expiry)
+	}
+
+	if tokenResponse.RefreshToken == "" {
+		t.Error("expected refresh token to be issued")
+	}
+
+	if !tokenResponse.RefreshExpiry.After(tokenResponse.Expiry) {
+		t.Error("expected refresh token to outlive the access token")
 	}
 }
 
 func TestCreateSession_EmptyUsername(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "",
@@ -164,7 +197,7 @@ func TestCreateSession_EmptyUsername(t *testing.T) {
 }
 
 func TestCreateSession_EmptyPassword(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "testuser",
@@ -182,7 +215,7 @@ func TestCreateSession_EmptyPassword(t *testing.T) {
 }
 
 func TestCreateSession_InvalidUsername(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	credentials := &dto_session.TokenRequestDTO{
 		Username: "nonexistent",
@@ -200,7 +233,7 @@ func TestCreateSession_InvalidUsername(t *testing.T) {
 }
 
 func TestCreateSession_InvalidPassword(t *testing.T) {
-	service, _, _ := setupTestService()
+	service, _, _ := setupTestService(t)
 
 	// First register a user
 	registerCredentials := &dto_session.TokenRequestDTO{
@@ -229,7 +262,7 @@ func TestCreateSession_InvalidPassword(t *testing.T) {
 }
 
 func TestGetUserSession_Success(t *testing.T) {
-	service, userRepo, sessionRepo := setupTestService()
+	service, userRepo, sessionRepo := setupTestService(t)
 
 	// Register and create session
 	registerCredentials := &dto_session.TokenRequestDTO{
@@ -276,7 +309,7 @@ func TestGetUserSession_Success(t *testing.T) {
 }
 
 func TestGetUserSession_UserNotFound(t *testing.T) {
-	service, _, sessionRepo := setupTestService()
+	service, _, sessionRepo := setupTestService(t)
 
 	// Create a session with non-existent user ID
 	session := &domain.Session{
@@ -294,15 +327,929 @@ func TestGetUserSession_UserNotFound(t *testing.T) {
 	}
 }
 
-func TestRefreshToken_NotImplemented(t *testing.T) {
-	service, _, _ := setupTestService()
+func signInTestUser(t *testing.T, service domain.SessionService) *dto_session.TokenResponseDTO {
+	t.Helper()
+
+	credentials := &dto_session.TokenRequestDTO{
+		Username: "testuser",
+		Password: "password123",
+	}
+
+	if err := service.RegisterUser(credentials); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	tokenResponse, err := service.CreateSession(credentials)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	return tokenResponse
+}
+
+func TestRefreshToken_Rotation(t *testing.T) {
+	service, _, sessionRepo := setupTestService(t)
+
+	initial := signInTestUser(t, service)
+
+	refreshed, err := service.RefreshToken(initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if refreshed.Token == "" {
+		t.Error("expected a new access token")
+	}
+
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == initial.RefreshToken {
+		t.Error("expected the refresh token to be rotated")
+	}
+
+	oldSession, err := sessionRepo.FindByRefreshToken(initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected old session to be kept for reuse detection, got %v", err)
+	}
+
+	if !oldSession.Revoked {
+		t.Error("expected old session to be revoked after rotation")
+	}
+
+	newSession, err := sessionRepo.FindByRefreshToken(refreshed.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected new session to exist, got %v", err)
+	}
+
+	if newSession.FamilyID != oldSession.FamilyID {
+		t.Errorf("expected rotated session to stay in family %s, got %s", oldSession.FamilyID, newSession.FamilyID)
+	}
+
+	// The rotated token keeps working
+	if _, err := service.RefreshToken(refreshed.RefreshToken); err != nil {
+		t.Errorf("expected rotated token to be accepted, got %v", err)
+	}
+}
+
+func TestRefreshToken_Expired(t *testing.T) {
+	service, _, sessionRepo := setupTestService(t)
+
+	initial := signInTestUser(t, service)
+
+	session, err := sessionRepo.FindByRefreshToken(initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to find session: %v", err)
+	}
+
+	session.RefreshExpiry = time.Now().Add(-time.Minute)
+	if _, err := sessionRepo.Update(session); err != nil {
+		t.Fatalf("failed to update session: %v", err)
+	}
+
+	_, err = service.RefreshToken(initial.RefreshToken)
+	if err == nil {
+		t.Fatal("expected error for expired refresh token, got nil")
+	}
+
+	if err.Error() != "refresh token expired" {
+		t.Errorf("expected 'refresh token expired' error, got %v", err)
+	}
+}
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	service, _, sessionRepo := setupTestService(t)
+
+	initial := signInTestUser(t, service)
+
+	refreshed, err := service.RefreshToken(initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Replaying the already rotated token must be refused
+	_, err = service.RefreshToken(initial.RefreshToken)
+	if err == nil {
+		t.Fatal("expected error for reused refresh token, got nil")
+	}
+
+	if err.Error() != "refresh token reuse detected" {
+		t.Errorf("expected 'refresh token reuse detected' error, got %v", err)
+	}
+
+	// ...and the legitimate holder of the newest token is logged out too
+	session, err := sessionRepo.FindByRefreshToken(refreshed.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to find session: %v", err)
+	}
+
+	if !session.Revoked {
+		t.Error("expected every session in the family to be revoked")
+	}
+
+	if _, err := service.RefreshToken(refreshed.RefreshToken); err == nil {
+		t.Error("expected refresh with a revoked family to fail")
+	}
+}
+
+func TestRefreshToken_Invalid(t *testing.T) {
+	service, _, _ := setupTestService(t)
 
 	_, err := service.RefreshToken("some-refresh-token")
 	if err == nil {
-		t.Fatal("expected error for not implemented, got nil")
+		t.Fatal("expected error for unknown refresh token, got nil")
+	}
+
+	if err.Error() != "invalid refresh token" {
+		t.Errorf("expected 'invalid refresh token' error, got %v", err)
+	}
+
+	_, err = service.RefreshToken("")
+	if err == nil {
+		t.Fatal("expected error for empty refresh token, got nil")
+	}
+}
+
+// failingFindRepository fails to look up users by ID.
+type failingFindRepository struct {
+	domain.UserRepository
+}
+
+func (r failingFindRepository) FindByID(id string) (*domain.User, error) {
+	return nil, errors.New("disk failure")
+}
+
+func TestRefreshToken_UserLookupFailure(t *testing.T) {
+	storage := repotest.Open(t)
+	service := NewAuthService(failingFindRepository{storage.Users}, storage.Sessions, storage.LoginAttempts, storage.AuthEvents, storage.PasswordResets, &testNotifier{})
+
+	tokenResponse := signInTestUser(t, service)
+
+	_, err := service.RefreshToken(tokenResponse.RefreshToken)
+	if err == nil || !strings.Contains(err.Error(), "failed to find user") {
+		t.Fatalf("expected the lookup failure to be reported, got %v", err)
+	}
+
+	// A storage failure isn't taken for a disabled account
+	session, _ := storage.Sessions.FindByRefreshToken(tokenResponse.RefreshToken)
+	if session.Revoked {
+		t.Error("expected the session to be left alone")
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	service, _, sessionRepo := setupTestService(t)
+
+	tokenResponse := signInTestUser(t, service)
+
+	session, err := sessionRepo.FindByRefreshToken(tokenResponse.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to find session: %v", err)
+	}
+
+	if err := service.RevokeSession(session); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := sessionRepo.FindByID(session.ID); err == nil {
+		t.Error("expected session to be removed after sign out")
+	}
+
+	if _, err := service.RefreshToken(tokenResponse.RefreshToken); err == nil {
+		t.Error("expected refresh token of a revoked session to be rejected")
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	service, userRepo, sessionRepo := setupTestService(t)
+
+	first := signInTestUser(t, service)
+
+	second, err := service.CreateSession(&dto_session.TokenRequestDTO{
+		Username: "testuser",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("failed to create second session: %v", err)
+	}
+
+	other := &dto_session.TokenRequestDTO{
+		Username: "otheruser",
+		Password: "password123",
+	}
+	if err := service.RegisterUser(other); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	otherToken, err := service.CreateSession(other)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	user, _ := userRepo.FindByUsername("testuser")
+
+	if err := service.RevokeAllSessions(user.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, token := range []string{first.RefreshToken, second.RefreshToken} {
+		if _, err := sessionRepo.FindByRefreshToken(token); err == nil {
+			t.Error("expected every session of the user to be removed")
+		}
+	}
+
+	if _, err := sessionRepo.FindByRefreshToken(otherToken.RefreshToken); err != nil {
+		t.Errorf("expected sessions of other users to be kept, got %v", err)
+	}
+}
+
+func TestSignOutRefreshToken(t *testing.T) {
+	service, _, sessionRepo := setupTestService(t)
+
+	initial := signInTestUser(t, service)
+
+	refreshed, err := service.RefreshToken(initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	second, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("failed to create second session: %v", err)
+	}
+
+	// The refresh token alone signs out, whatever state the access token is in
+	if err := service.SignOutRefreshToken(refreshed.RefreshToken, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if session, _ := sessionRepo.FindByRefreshToken(refreshed.RefreshToken); session == nil || !session.Revoked {
+		t.Error("expected the refresh token's session to be revoked")
+	}
+
+	if session, _ := sessionRepo.FindByRefreshToken(second.RefreshToken); session == nil || session.Revoked {
+		t.Error("expected the user's other sign-ins to be kept")
+	}
+
+	// A rotated token can't sign out everywhere
+	if err := service.SignOutRefreshToken(initial.RefreshToken, true); !errors.Is(err, domain.ErrInvalidRefreshToken) {
+		t.Errorf("expected a rotated token to be refused, got %v", err)
+	}
+
+	for _, token := range []string{"", "some-refresh-token"} {
+		if err := service.SignOutRefreshToken(token, false); !errors.Is(err, domain.ErrInvalidRefreshToken) {
+			t.Errorf("expected %q to be refused, got %v", token, err)
+		}
+	}
+
+	if err := service.SignOutRefreshToken(second.RefreshToken, true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := sessionRepo.FindByRefreshToken(second.RefreshToken); err == nil {
+		t.Error("expected every session of the user to be removed")
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	service, _, sessionRepo := setupTestService(t)
+
+	if err := service.RegisterUser(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"}); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	laptop, err := service.CreateSession(&dto_session.TokenRequestDTO{
+		Username:  "testuser",
+		Password:  "password123",
+		IP:        "10.0.0.1",
+		UserAgent: "laptop",
+	})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	phone, err := service.CreateSession(&dto_session.TokenRequestDTO{
+		Username:  "testuser",
+		Password:  "password123",
+		IP:        "10.0.0.2",
+		UserAgent: "phone",
+	})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// Rotating the phone's token must not show up as an extra device
+	phone, err = service.RefreshToken(phone.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to refresh token: %v", err)
+	}
+
+	current, _ := sessionRepo.FindByRefreshToken(laptop.RefreshToken)
+
+	sessions, err := service.ListSessions(current)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 active sessions, got %d", len(sessions))
+	}
+
+	for _, session := range sessions {
+		switch session.UserAgent {
+		case "laptop":
+			if !session.Current {
+				t.Error("expected laptop session to be marked as current")
+			}
+			if session.IP != "10.0.0.1" {
+				t.Errorf("expected laptop IP 10.0.0.1, got %s", session.IP)
+			}
+		case "phone":
+			if session.Current {
+				t.Error("expected phone session not to be marked as current")
+			}
+			if session.CreatedAt.IsZero() {
+				t.Error("expected created-at to survive token rotation")
+			}
+		default:
+			t.Errorf("unexpected session %+v", session)
+		}
+	}
+}
+
+func TestRevokeUserSession(t *testing.T) {
+	service, userRepo, sessionRepo := setupTestService(t)
+
+	tokenResponse := signInTestUser(t, service)
+	user, _ := userRepo.FindByUsername("testuser")
+	session, _ := sessionRepo.FindByRefreshToken(tokenResponse.RefreshToken)
+
+	if err := service.RevokeUserSession("someone-else", session.ID); err == nil {
+		t.Fatal("expected error when revoking another user's session, got nil")
+	}
+
+	if _, err := sessionRepo.FindByID(session.ID); err != nil {
+		t.Fatalf("expected session to survive a foreign revoke, got %v", err)
+	}
+
+	if err := service.RevokeUserSession(user.ID, session.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := sessionRepo.FindByID(session.ID); err == nil {
+		t.Error("expected session to be removed")
+	}
+}
+
+func TestCreateSession_LockoutAfterMaxFailures(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts: 3,
+		LockoutDuration:   time.Minute,
+	}
+
+	signInTestUser(t, service)
+
+	wrong := &dto_session.TokenRequestDTO{Username: "testuser", Password: "wrongpassword"}
+
+	for i := 0; i < 2; i++ {
+		_, err := service.CreateSession(wrong)
+		if err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("attempt %d: expected 'invalid credentials' error, got %v", i+1, err)
+		}
+	}
+
+	_, err := service.CreateSession(wrong)
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("expected lockout on the third failure, got %v", err)
+	}
+
+	if lockout.RetryAfter != time.Minute {
+		t.Errorf("expected retry after 1m, got %v", lockout.RetryAfter)
+	}
+
+	// Even the right password is refused while locked
+	_, err = service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"})
+	if !errors.As(err, &lockout) {
+		t.Errorf("expected locked account to refuse the correct password, got %v", err)
+	}
+}
+
+func TestCreateSession_ExponentialBackoff(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts: 10,
+		LockoutDuration:   time.Hour,
+		BackoffBase:       time.Minute,
+	}
+
+	wrong := &dto_session.TokenRequestDTO{Username: "testuser", Password: "wrongpassword"}
+
+	for i := 0; i < backoffFreeAttempts+1; i++ {
+		if _, err := service.CreateSession(wrong); err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("attempt %d: expected 'invalid credentials' error, got %v", i+1, err)
+		}
+	}
+
+	_, err := service.CreateSession(wrong)
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("expected attempt inside the backoff window to be throttled, got %v", err)
+	}
+
+	if lockout.RetryAfter <= 0 || lockout.RetryAfter > time.Minute {
+		t.Errorf("expected retry after at most 1m, got %v", lockout.RetryAfter)
+	}
+
+	s := service.(*authService)
+	if s.backoff(backoffFreeAttempts+2) != 2*time.Minute || s.backoff(backoffFreeAttempts+3) != 4*time.Minute {
+		t.Error("expected the backoff to double with every failure")
+	}
+
+	if s.backoff(50) != time.Hour {
+		t.Errorf("expected the backoff to be capped at the lockout duration, got %v", s.backoff(50))
+	}
+}
+
+func TestCreateSession_IPLockout(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts:   10,
+		IPMaxFailedAttempts: 3,
+		LockoutDuration:     time.Minute,
+	}
+
+	for _, username := range []string{"alice", "bob", "carol"} {
+		service.CreateSession(&dto_session.TokenRequestDTO{Username: username, Password: "guess", IP: "10.0.0.9"})
+	}
+
+	_, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "dave", Password: "guess", IP: "10.0.0.9"})
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("expected IP spraying many usernames to be locked out, got %v", err)
+	}
+
+	_, err = service.CreateSession(&dto_session.TokenRequestDTO{Username: "dave", Password: "guess", IP: "10.0.0.10"})
+	if err == nil || err.Error() != "invalid credentials" {
+		t.Errorf("expected other IPs to be unaffected, got %v", err)
+	}
+}
+
+func TestCreateSession_SuccessResetsFailures(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts: 3,
+		LockoutDuration:   time.Minute,
+	}
+
+	signInTestUser(t, service)
+
+	wrong := &dto_session.TokenRequestDTO{Username: "testuser", Password: "wrongpassword"}
+	right := &dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"}
+
+	for round := 0; round < 3; round++ {
+		service.CreateSession(wrong)
+		service.CreateSession(wrong)
+
+		if _, err := service.CreateSession(right); err != nil {
+			t.Fatalf("round %d: expected successful sign-in to reset the counter, got %v", round+1, err)
+		}
+	}
+}
+
+func TestCreateSession_ParallelAttempts(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts: 3,
+		LockoutDuration:   time.Minute,
+	}
+
+	signInTestUser(t, service)
+
+	// Every attempt is counted before the password is checked, so parallel
+	// attempts get no more guesses than sequential ones.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	guesses := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "wrongpassword"})
+			if err != nil && err.Error() == "invalid credentials" {
+				mu.Lock()
+				guesses++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if guesses != 2 {
+		t.Errorf("expected 2 guesses before the lockout, got %d", guesses)
+	}
+}
+
+func TestCreateSession_SuccessKeepsIPCounter(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts:   10,
+		IPMaxFailedAttempts: 2,
+		LockoutDuration:     time.Minute,
+	}
+
+	signInTestUser(t, service)
+
+	// Signing in doesn't count against the IP
+	right := &dto_session.TokenRequestDTO{Username: "testuser", Password: "password123", IP: "10.0.0.1"}
+	for i := 0; i < 3; i++ {
+		if _, err := service.CreateSession(right); err != nil {
+			t.Fatalf("attempt %d: expected no error, got %v", i+1, err)
+		}
+	}
+
+	service.CreateSession(&dto_session.TokenRequestDTO{Username: "alice", Password: "guess", IP: "10.0.0.1"})
+	if _, err := service.CreateSession(right); err != nil {
+		t.Fatalf("expected a single failure not to lock the IP, got %v", err)
+	}
+
+	// Nor does it wipe the failures the IP made against other accounts
+	_, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "bob", Password: "guess", IP: "10.0.0.1"})
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Errorf("expected the second failure to lock the IP, got %v", err)
+	}
+}
+
+func TestCreateSession_RecordsAuthEvents(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).policy = config.Auth{
+		MaxFailedAttempts: 2,
+		LockoutDuration:   time.Minute,
+	}
+
+	signInTestUser(t, service)
+
+	wrong := &dto_session.TokenRequestDTO{Username: "testuser", Password: "wrongpassword", IP: "10.0.0.1"}
+	service.CreateSession(wrong)
+	service.CreateSession(wrong)
+	service.CreateSession(wrong)
+
+	events, err := service.(*authService).eventRepo.FindByUsername("testuser")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	counts := make(map[domain.AuthEventType]int)
+	for _, event := range events {
+		counts[event.Type]++
+	}
+
+	if counts[domain.AuthEventSignInSuccess] != 1 {
+		t.Errorf("expected 1 successful sign-in event, got %d", counts[domain.AuthEventSignInSuccess])
+	}
+
+	if counts[domain.AuthEventSignInFailure] != 2 {
+		t.Errorf("expected 2 failed sign-in events, got %d", counts[domain.AuthEventSignInFailure])
+	}
+
+	if counts[domain.AuthEventAccountLocked] != 1 {
+		t.Errorf("expected 1 lockout event, got %d", counts[domain.AuthEventAccountLocked])
+	}
+
+	if counts[domain.AuthEventSignInThrottled] != 1 {
+		t.Errorf("expected 1 throttled event, got %d", counts[domain.AuthEventSignInThrottled])
+	}
+}
+
+func enableTestMFA(t *testing.T, service domain.SessionService, userID string) (string, []string) {
+	t.Helper()
+
+	setup, err := service.SetupMFA(userID)
+	if err != nil {
+		t.Fatalf("failed to set up mfa: %v", err)
+	}
+
+	code, _ := util.TOTPCode(setup.Secret, util.TOTPStep(time.Now()))
+	verified, err := service.VerifyMFA(userID, code)
+	if err != nil {
+		t.Fatalf("failed to verify mfa: %v", err)
+	}
+
+	return setup.Secret, verified.RecoveryCodes
+}
+
+func TestMFA_SetupAndVerify(t *testing.T) {
+	service, userRepo, _ := setupTestService(t)
+
+	signInTestUser(t, service)
+	user, _ := userRepo.FindByUsername("testuser")
+
+	setup, err := service.SetupMFA(user.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if setup.Secret == "" || !strings.HasPrefix(setup.URI, "otpauth://totp/") || !strings.Contains(setup.URI, "secret="+setup.Secret) {
+		t.Errorf("expected secret and otpauth URI, got %+v", setup)
+	}
+
+	if _, err := service.VerifyMFA(user.ID, "000000"); err == nil {
+		t.Fatal("expected error for wrong code, got nil")
+	}
+
+	user, _ = userRepo.FindByID(user.ID)
+	if user.MFAEnabled {
+		t.Fatal("expected mfa to stay disabled until a code is verified")
+	}
+
+	code, _ := util.TOTPCode(setup.Secret, util.TOTPStep(time.Now()))
+	verified, err := service.VerifyMFA(user.ID, code)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(verified.RecoveryCodes) != mfaRecoveryCodeCount {
+		t.Errorf("expected %d recovery codes, got %d", mfaRecoveryCodeCount, len(verified.RecoveryCodes))
+	}
+
+	user, _ = userRepo.FindByID(user.ID)
+	if !user.MFAEnabled {
+		t.Error("expected mfa to be enabled")
+	}
+
+	for _, stored := range user.MFARecoveryCodes {
+		for _, plain := range verified.RecoveryCodes {
+			if stored == plain {
+				t.Fatal("expected recovery codes to be stored hashed")
+			}
+		}
+	}
+
+	if _, err := service.SetupMFA(user.ID); err == nil {
+		t.Error("expected setup to be refused once mfa is enabled")
+	}
+}
+
+func TestCreateSession_MFARequired(t *testing.T) {
+	service, userRepo, _ := setupTestService(t)
+
+	signInTestUser(t, service)
+	user, _ := userRepo.FindByUsername("testuser")
+	secret, _ := enableTestMFA(t, service, user.ID)
+
+	challenge, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Fatal("expected an mfa challenge")
+	}
+
+	if challenge.Token != "" || challenge.RefreshToken != "" {
+		t.Error("expected no session tokens before the second factor")
+	}
+
+	if _, err := util.ValidateJWT(challenge.MFAToken); err == nil {
+		t.Error("expected the mfa token to be refused as an access token")
+	}
+
+	// The step used during verification was spent, so use the next one
+	step := util.TOTPStep(time.Now())
+	spent, _ := util.TOTPCode(secret, step)
+	if _, err := service.CompleteMFASignIn(&dto_session.MFASignInRequestDTO{MFAToken: challenge.MFAToken, Code: spent}); err == nil {
+		t.Error("expected an already used code to be refused")
+	}
+
+	next, _ := util.TOTPCode(secret, step+1)
+	tokenResponse, err := service.CompleteMFASignIn(&dto_session.MFASignInRequestDTO{MFAToken: challenge.MFAToken, Code: next})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if tokenResponse.Token == "" || tokenResponse.RefreshToken == "" {
+		t.Error("expected a full session after the second factor")
+	}
+
+	if _, err := service.CompleteMFASignIn(&dto_session.MFASignInRequestDTO{MFAToken: "not-a-token", Code: next}); err == nil {
+		t.Error("expected an invalid mfa token to be refused")
+	}
+}
+
+func TestCreateSession_MFARecoveryCode(t *testing.T) {
+	service, userRepo, _ := setupTestService(t)
+
+	signInTestUser(t, service)
+	user, _ := userRepo.FindByUsername("testuser")
+	_, recoveryCodes := enableTestMFA(t, service, user.ID)
+
+	challenge, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	request := &dto_session.MFASignInRequestDTO{MFAToken: challenge.MFAToken, Code: strings.ToUpper(recoveryCodes[0])}
+	if _, err := service.CompleteMFASignIn(request); err != nil {
+		t.Fatalf("expected recovery code to be accepted, got %v", err)
+	}
+
+	if _, err := service.CompleteMFASignIn(request); err == nil {
+		t.Error("expected recovery code to be single-use")
+	}
+
+	user, _ = userRepo.FindByID(user.ID)
+	if len(user.MFARecoveryCodes) != mfaRecoveryCodeCount-1 {
+		t.Errorf("expected %d recovery codes left, got %d", mfaRecoveryCodeCount-1, len(user.MFARecoveryCodes))
+	}
+}
+
+func TestRegisterUser_PasswordPolicy(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).passwords = config.Password{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	cases := map[string]string{
+		"Sh0rt!":                  "password must be at least 10 characters",
+		"nouppercase1!":           "password must contain an uppercase letter",
+		"NoDigitsHere!":           "password must contain a digit",
+		"NoSymbols1234":           "password must contain a symbol",
+		strings.Repeat("A1!", 30): "password must be at most 72 bytes",
+	}
+
+	for password, expected := range cases {
+		err := service.RegisterUser(&dto_session.TokenRequestDTO{Username: "testuser", Password: password})
+		if err == nil || err.Error() != expected {
+			t.Errorf("password %q: expected %q error, got %v", password, expected, err)
+		}
+	}
+
+	if err := service.RegisterUser(&dto_session.TokenRequestDTO{Username: "testuser", Password: "Str0ng-enough"}); err != nil {
+		t.Errorf("expected compliant password to be accepted, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	service, userRepo, sessionRepo := setupTestService(t)
+
+	current := signInTestUser(t, service)
+	other, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"})
+	if err != nil {
+		t.Fatalf("failed to create second session: %v", err)
+	}
+
+	session, _ := sessionRepo.FindByRefreshToken(current.RefreshToken)
+
+	err = service.ChangePassword(session, &dto_session.PasswordChangeRequestDTO{CurrentPassword: "wrongpassword", NewPassword: "newpassword456"})
+	if err == nil || err.Error() != "current password is incorrect" {
+		t.Fatalf("expected 'current password is incorrect' error, got %v", err)
+	}
+
+	err = service.ChangePassword(session, &dto_session.PasswordChangeRequestDTO{CurrentPassword: "password123", NewPassword: "short"})
+	if err == nil {
+		t.Fatal("expected new password to be checked against the policy")
+	}
+
+	err = service.ChangePassword(session, &dto_session.PasswordChangeRequestDTO{CurrentPassword: "password123", NewPassword: "newpassword456"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user, _ := userRepo.FindByUsername("testuser")
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("newpassword456")) != nil {
+		t.Error("expected password to be updated")
+	}
+
+	if _, err := sessionRepo.FindByID(session.ID); err != nil {
+		t.Error("expected the current session to stay signed in")
+	}
+
+	if _, err := sessionRepo.FindByRefreshToken(other.RefreshToken); err == nil {
+		t.Error("expected other sessions to be revoked")
+	}
+}
+
+// failingDeleteRepository fails to delete sessions.
+type failingDeleteRepository struct {
+	domain.SessionRepository
+}
+
+func (r failingDeleteRepository) Delete(id string) error {
+	return errors.New("disk failure")
+}
+
+func TestChangePassword_RevokeFailure(t *testing.T) {
+	storage := repotest.Open(t)
+	service := NewAuthService(storage.Users, failingDeleteRepository{storage.Sessions}, storage.LoginAttempts, storage.AuthEvents, storage.PasswordResets, &testNotifier{})
+
+	current := signInTestUser(t, service)
+	if _, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"}); err != nil {
+		t.Fatalf("failed to create second session: %v", err)
+	}
+
+	session, _ := storage.Sessions.FindByRefreshToken(current.RefreshToken)
+
+	err := service.ChangePassword(session, &dto_session.PasswordChangeRequestDTO{CurrentPassword: "password123", NewPassword: "newpassword456"})
+	if err == nil || !strings.Contains(err.Error(), "failed to revoke sessions") {
+		t.Errorf("expected a failed revocation to be reported, got %v", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	service, userRepo, sessionRepo := setupTestService(t)
+	notifier := service.(*authService).notifier.(*testNotifier)
+
+	tokenResponse := signInTestUser(t, service)
+
+	if err := service.RequestPasswordReset(&dto_session.PasswordResetRequestDTO{Username: "nobody"}); err != nil {
+		t.Fatalf("expected unknown usernames not to be revealed, got %v", err)
+	}
+
+	if len(notifier.resetTokens) != 0 {
+		t.Fatal("expected no token to be sent for unknown usernames")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := service.RequestPasswordReset(&dto_session.PasswordResetRequestDTO{Username: "testuser"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	if len(notifier.resetTokens) != 2 {
+		t.Fatalf("expected 2 tokens to be sent, got %d", len(notifier.resetTokens))
+	}
+
+	stale, latest := notifier.resetTokens[0], notifier.resetTokens[1]
+
+	if err := service.ConfirmPasswordReset(&dto_session.PasswordResetConfirmDTO{Token: stale, NewPassword: "newpassword456"}); err == nil {
+		t.Error("expected a superseded token to be refused")
+	}
+
+	if err := service.ConfirmPasswordReset(&dto_session.PasswordResetConfirmDTO{Token: latest, NewPassword: "newpassword456"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user, _ := userRepo.FindByUsername("testuser")
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("newpassword456")) != nil {
+		t.Error("expected password to be updated")
+	}
+
+	if _, err := sessionRepo.FindByRefreshToken(tokenResponse.RefreshToken); err == nil {
+		t.Error("expected existing sessions to be revoked")
+	}
+
+	if err := service.ConfirmPasswordReset(&dto_session.PasswordResetConfirmDTO{Token: latest, NewPassword: "another-password"}); err == nil {
+		t.Error("expected reset token to be single-use")
+	}
+}
+
+func TestPasswordReset_Expired(t *testing.T) {
+	service, _, _ := setupTestService(t)
+	service.(*authService).passwords.ResetTokenTTL = -time.Minute
+	notifier := service.(*authService).notifier.(*testNotifier)
+
+	signInTestUser(t, service)
+
+	if err := service.RequestPasswordReset(&dto_session.PasswordResetRequestDTO{Username: "testuser"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := service.ConfirmPasswordReset(&dto_session.PasswordResetConfirmDTO{Token: notifier.resetTokens[0], NewPassword: "newpassword456"})
+	if err == nil || err.Error() != "invalid or expired reset token" {
+		t.Errorf("expected 'invalid or expired reset token' error, got %v", err)
+	}
+}
+
+func TestRegisterUser_NeverAdmin(t *testing.T) {
+	service, userRepo, _ := setupTestService(t)
+
+	if err := service.RegisterUser(&dto_session.TokenRequestDTO{Username: "admin", Password: "password123"}); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	user, _ := userRepo.FindByUsername("admin")
+	if user.Role != domain.RoleUser || user.HasPermission(domain.PermissionUsersRead) {
+		t.Errorf("expected regular user without admin permissions, got role %q", user.Role)
+	}
+}
+
+func TestDisabledUser(t *testing.T) {
+	service, userRepo, _ := setupTestService(t)
+
+	tokenResponse := signInTestUser(t, service)
+
+	user, _ := userRepo.FindByUsername("testuser")
+	disabled := *user
+	disabled.Disabled = true
+	userRepo.Update(&disabled)
+
+	_, err := service.CreateSession(&dto_session.TokenRequestDTO{Username: "testuser", Password: "password123"})
+	if err == nil || err.Error() != "account is disabled" {
+		t.Errorf("expected 'account is disabled' error, got %v", err)
 	}
 
-	if err.Error() != "not implemented" {
-		t.Errorf("expected 'not implemented' error, got %v", err)
+	if _, err := service.RefreshToken(tokenResponse.RefreshToken); err == nil {
+		t.Error("expected refresh to fail for a disabled user")
 	}
 }
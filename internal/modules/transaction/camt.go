@@ -0,0 +1,287 @@
+package transaction
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// camtAmounts reads camt.053 amounts, which are unsigned and have a decimal
+// point, into the minor unit of their currency.
+var camtAmounts = &layout{decimal: '.', places: 2, sign: domain.SignConventionType}
+
+// camtEntry is an Ntry, with what is read of it. Sts is text up to version
+// .07 of the message and a code from .08 on; the related parties' names
+// moved into a Pty element at the same time.
+type camtEntry struct {
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	Status      struct {
+		Text string `xml:",chardata"`
+		Code string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate camtDate `xml:"BookgDt"`
+	ValueDate   camtDate `xml:"ValDt"`
+	Reference   string   `xml:"AcctSvcrRef"`
+	Info        string   `xml:"AddtlNtryInf"`
+	Details     []struct {
+		Debtor        string   `xml:"RltdPties>Dbtr>Nm"`
+		DebtorParty   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+		Creditor      string   `xml:"RltdPties>Cdtr>Nm"`
+		CreditorParty string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+		Remittance    []string `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+// camtAmount is an Amt, in the currency its Ccy names.
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// camtAccount is a statement's Acct, identified by its IBAN or otherwise.
+type camtAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+}
+
+type camtBalance struct {
+	Type        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+}
+
+// parseCamt reads the Ntry entries of an ISO 20022 camt.053 statement, each
+// for the account of the Stmt it is in. An account's opening balance is its
+// first statement's opening booked (OPBD) or, for banks that give that
+// instead, previously closed booked (PRCD) balance, and its closing balance
+// its last statement's closing booked (CLBD) one.
+func parseCamt(text io.Reader) (*statement, error) {
+	decoder := xml.NewDecoder(text)
+	// The text was decoded to UTF-8 before, whatever the XML declaration
+	// says.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	parsed := &statement{}
+	found := false
+	var account string
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, camtError(err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		line, _ := decoder.InputPos()
+
+		switch start.Name.Local {
+		case "BkToCstmrStmt":
+			found = true
+
+		case "Stmt":
+			account = ""
+
+		case "Acct":
+			var acct camtAccount
+			if err := decoder.DecodeElement(&acct, &start); err != nil {
+				return nil, camtError(err)
+			}
+			account = strings.TrimSpace(acct.IBAN + acct.Other)
+
+		case "Bal":
+			var balance camtBalance
+			if err := decoder.DecodeElement(&balance, &start); err != nil {
+				return nil, camtError(err)
+			}
+
+			amount, err := balance.amount()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s balance on line %d: %v", domain.ErrInvalidStatement, balance.Type, line, err)
+			}
+
+			switch stated := parsed.balance(account); balance.Type {
+			case "OPBD", "PRCD":
+				if stated.Opening == nil {
+					stated.Opening = &amount
+				}
+			case "CLBD":
+				stated.Closing = &amount
+			}
+
+		case "Ntry":
+			var entry camtEntry
+			if err := decoder.DecodeElement(&entry, &start); err != nil {
+				return nil, camtError(err)
+			}
+			transaction, rowErrors := entry.transaction(account, line)
+			parsed.add(line, transaction, rowErrors)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: no camt.053 BkToCstmrStmt element found", domain.ErrInvalidStatement)
+	}
+
+	if len(parsed.transactions) == 0 && parsed.failedRows == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", domain.ErrInvalidStatement)
+	}
+
+	return parsed, nil
+}
+
+// transaction turns the entry into a transaction, or explains what is wrong
+// with each of its bad fields. The counterparty, the debtor of a credit or
+// the creditor of a debit, is the name, and the remittance information the
+// description.
+func (e *camtEntry) transaction(account string, line int) (domain.Transaction, []dto_transaction.RowErrorDTO) {
+	transaction := domain.Transaction{Account: account}
+	var rowErrors []dto_transaction.RowErrorDTO
+	invalid := func(field string, format string, args ...any) {
+		rowErrors = append(rowErrors, dto_transaction.RowErrorDTO{Line: line, Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	switch status := strings.TrimSpace(e.Status.Text + e.Status.Code); status {
+	case "BOOK":
+		transaction.Status = domain.TransactionStatusSuccess
+		transaction.ExternalID = strings.TrimSpace(e.Reference)
+	case "PDNG":
+		transaction.Status = domain.TransactionStatusPending
+	default:
+		invalid("status", "invalid Sts %q, expected BOOK or PDNG", status)
+	}
+
+	date := e.BookingDate
+	if date == (camtDate{}) {
+		date = e.ValueDate
+	}
+
+	if timestamp, err := date.parse(); err != nil {
+		invalid("timestamp", "invalid BookgDt %q, expected a date like 2024-01-31 or 2024-01-31T09:30:00", date.Date+date.DateTime)
+	} else {
+		transaction.Timestamp = timestamp
+	}
+
+	switch strings.TrimSpace(e.CreditDebit) {
+	case "CRDT":
+		transaction.Type = domain.TransactionTypeCredit
+	case "DBIT":
+		transaction.Type = domain.TransactionTypeDebit
+	default:
+		invalid("type", "invalid CdtDbtInd %q, expected CRDT or DBIT", e.CreditDebit)
+	}
+
+	if amount, err := e.Amount.parse(); err != nil {
+		invalid("amount", "%v", err)
+	} else {
+		transaction.Amount = amount
+	}
+
+	var remittance []string
+	for _, details := range e.Details {
+		if transaction.Name == "" && transaction.Type == domain.TransactionTypeCredit {
+			transaction.Name = strings.TrimSpace(details.Debtor + details.DebtorParty)
+		}
+		if transaction.Name == "" && transaction.Type == domain.TransactionTypeDebit {
+			transaction.Name = strings.TrimSpace(details.Creditor + details.CreditorParty)
+		}
+		for _, text := range details.Remittance {
+			if text = strings.TrimSpace(text); text != "" {
+				remittance = append(remittance, text)
+			}
+		}
+	}
+
+	info := strings.TrimSpace(e.Info)
+	transaction.Description = strings.Join(remittance, " ")
+	for _, fallback := range []string{info, transaction.Description} {
+		if transaction.Name == "" {
+			transaction.Name = fallback
+		}
+	}
+	for _, fallback := range []string{info, transaction.Name} {
+		if transaction.Description == "" {
+			transaction.Description = fallback
+		}
+	}
+
+	if transaction.Name == "" {
+		invalid("name", "no counterparty name, remittance information or AddtlNtryInf")
+	}
+
+	return transaction, rowErrors
+}
+
+// camtError reports a malformed document as an invalid statement, and
+// passes a failure to read it on as it is.
+func camtError(err error) error {
+	var syntaxErr *xml.SyntaxError
+	var unmarshalErr xml.UnmarshalError
+	if errors.As(err, &syntaxErr) || errors.As(err, &unmarshalErr) {
+		return fmt.Errorf("%w: malformed camt.053: %v", domain.ErrInvalidStatement, err)
+	}
+
+	return err
+}
+
+// parse reads a date, in UTC, or a date and time, in UTC unless it has a
+// zone.
+func (d camtDate) parse() (time.Time, error) {
+	if d.Date != "" {
+		return time.Parse(time.DateOnly, strings.TrimSpace(d.Date))
+	}
+
+	value := strings.TrimSpace(d.DateTime)
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+
+	return time.Parse("2006-01-02T15:04:05", value)
+}
+
+// parse reads the amount into the minor unit of its currency.
+func (a camtAmount) parse() (int64, error) {
+	amounts := camtAmounts.inCurrency(a.Currency)
+	amount, _, err := amounts.parseAmount(strings.TrimSpace(a.Value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid Amt %q, expected %s", a.Value, amounts.amountHint())
+	}
+
+	return amount, nil
+}
+
+func (b *camtBalance) amount() (int64, error) {
+	amount, err := b.Amount.parse()
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.TrimSpace(b.CreditDebit) {
+	case "CRDT":
+		return amount, nil
+	case "DBIT":
+		return -amount, nil
+	}
+
+	return 0, fmt.Errorf("invalid CdtDbtInd %q, expected CRDT or DBIT", b.CreditDebit)
+}
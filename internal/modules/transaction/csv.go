@@ -0,0 +1,123 @@
+package transaction
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// csvFields are the fields of a statement row, in the order of the fixed
+// columns.
+var csvFields = []string{"timestamp", "name", "type", "amount", "status", "description"}
+
+// parseCSV reads a statement laid out as format describes. Bad rows are
+// collected rather than ending the parse, so the error is only for a file
+// that can't be read, doesn't match the layout or has no rows at all.
+func parseCSV(fileContent io.Reader, format *layout) (*statement, error) {
+	buffered := bufio.NewReader(fileContent)
+	for skipped := 0; skipped < format.skipRows; skipped++ {
+		if _, err := buffered.ReadString('\n'); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	var source io.Reader = buffered
+	if format.quote != '"' {
+		source = quoteSwapper{reader: buffered, quote: format.quote}
+	}
+
+	reader := csv.NewReader(source)
+	reader.Comma = format.delimiter
+	reader.TrimLeadingSpace = true
+	// Row lengths are checked below, to report them like any other bad row.
+	reader.FieldsPerRecord = -1
+
+	parsed := &statement{}
+	first := true
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			parsed.fail(dto_transaction.RowErrorDTO{Line: parseErr.Line + format.skipRows, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if format.quote != '"' {
+			for index := range record {
+				record[index] = swapQuote(record[index], format.quote)
+			}
+		}
+
+		line, _ := reader.FieldPos(0)
+		line += format.skipRows
+
+		if first {
+			first = false
+			if len(format.headers) > 0 || format.isHeader(record) {
+				if err := format.useHeader(record); err != nil {
+					return nil, fmt.Errorf("%w: %v", domain.ErrInvalidStatement, err)
+				}
+				continue
+			}
+		}
+
+		transaction, rowErrors := format.parseRecord(record, line)
+		parsed.add(line, transaction, rowErrors)
+	}
+
+	if len(parsed.transactions) == 0 && parsed.failedRows == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", domain.ErrInvalidStatement)
+	}
+
+	return parsed, nil
+}
+
+// quoteSwapper lets encoding/csv, which only knows '"' as a quote, read a
+// file quoted with another character, by swapping the two. swapQuote swaps
+// them back in the fields read.
+type quoteSwapper struct {
+	reader io.Reader
+	quote  byte
+}
+
+func (s quoteSwapper) Read(buffer []byte) (int, error) {
+	n, err := s.reader.Read(buffer)
+	for index, char := range buffer[:n] {
+		switch char {
+		case s.quote:
+			buffer[index] = '"'
+		case '"':
+			buffer[index] = s.quote
+		}
+	}
+
+	return n, err
+}
+
+func swapQuote(field string, quote byte) string {
+	return strings.Map(func(char rune) rune {
+		switch char {
+		case rune(quote):
+			return '"'
+		case '"':
+			return rune(quote)
+		}
+		return char
+	}, field)
+}
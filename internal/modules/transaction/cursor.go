@@ -0,0 +1,81 @@
+package transaction
+
+import (
+	"fmt"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// pageCursor is the signed payload behind next_cursor and prev_cursor. It
+// records the sort order it was issued for and the anchor row's values for
+// every sortable field, so it stays valid however the rows change. The
+// timestamp is in RFC 3339, as Unix nanoseconds only span the years 1678 to
+// 2262.
+type pageCursor struct {
+	SortBy    string `json:"s"`
+	ID        string `json:"id"`
+	Timestamp string `json:"tm"`
+	Name      string `json:"n"`
+	Type      string `json:"ty"`
+	Amount    int64  `json:"a"`
+	Status    string `json:"st"`
+	Backward  bool   `json:"b,omitempty"`
+}
+
+func (s *transactionService) encodeCursor(keys []dto_transaction.SortKey, anchor domain.Transaction, backward bool) (string, error) {
+	return s.cursors.Sign(pageCursor{
+		SortBy:    dto_transaction.FormatSortKeys(keys),
+		ID:        anchor.ID,
+		Timestamp: anchor.Timestamp.Format(time.RFC3339Nano),
+		Name:      anchor.Name,
+		Type:      string(anchor.Type),
+		Amount:    anchor.Amount,
+		Status:    string(anchor.Status),
+		Backward:  backward,
+	})
+}
+
+// decodeCursor verifies token and returns the page it points at, along with
+// the sorting to use. A request that leaves sorting out inherits the
+// cursor's; one that sets it must ask for the same order.
+func (s *transactionService) decodeCursor(token string, sorting dto_transaction.SortingDTO) (*domain.TransactionCursor, dto_transaction.SortingDTO, error) {
+	var payload pageCursor
+	if err := s.cursors.Verify(token, &payload); err != nil {
+		return nil, sorting, fmt.Errorf("%w: %v", domain.ErrInvalidCursor, err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, payload.Timestamp)
+	if err != nil {
+		return nil, sorting, fmt.Errorf("%w: malformed cursor", domain.ErrInvalidCursor)
+	}
+
+	cursorSorting := dto_transaction.SortingDTO{SortBy: payload.SortBy}
+	if _, err := cursorSorting.Keys(); err != nil {
+		return nil, sorting, fmt.Errorf("%w: %v", domain.ErrInvalidCursor, err)
+	}
+
+	if sorting.Sort != "" || sorting.SortBy != "" {
+		keys, err := sorting.Keys()
+		if err != nil {
+			return nil, sorting, err
+		}
+
+		if requested := dto_transaction.FormatSortKeys(keys); requested != payload.SortBy {
+			return nil, sorting, fmt.Errorf("%w: it was issued for sortBy=%s, not %s", domain.ErrInvalidCursor, payload.SortBy, requested)
+		}
+	}
+
+	return &domain.TransactionCursor{
+		Anchor: domain.Transaction{
+			ID:        payload.ID,
+			Timestamp: timestamp,
+			Name:      payload.Name,
+			Type:      domain.TransactionType(payload.Type),
+			Amount:    payload.Amount,
+			Status:    domain.TransactionStatus(payload.Status),
+		},
+		Backward: payload.Backward,
+	}, cursorSorting, nil
+}
@@ -0,0 +1,207 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// sniffSize is how much of a file without a BOM is looked at to guess its
+// encoding.
+const sniffSize = 64 << 10
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// windows1252 holds the characters Windows-1252 has in place of the C1
+// controls of ISO-8859-1. The five bytes it leaves undefined stay controls.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeText returns the statement as UTF-8 without a BOM. Without an
+// encoding, a BOM decides, then whether the start of the file looks like
+// UTF-16 or is valid UTF-8; anything else is taken as Windows-1252, which
+// covers ISO-8859-1's printable characters.
+func decodeText(fileContent io.Reader, encoding string) (io.Reader, error) {
+	buffered := bufio.NewReaderSize(fileContent, sniffSize)
+
+	head, err := buffered.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	encoding = strings.ToLower(encoding)
+	if encoding == "" {
+		encoding = detectEncoding(head)
+	}
+
+	// A BOM is only skipped when it matches the encoding.
+	switch {
+	case encoding == dto_transaction.EncodingUTF8 && bytes.HasPrefix(head, bomUTF8):
+		buffered.Discard(len(bomUTF8))
+	case encoding == dto_transaction.EncodingUTF16 && bytes.HasPrefix(head, bomUTF16BE):
+		encoding = dto_transaction.EncodingUTF16BE
+		buffered.Discard(len(bomUTF16BE))
+	case encoding == dto_transaction.EncodingUTF16 || encoding == dto_transaction.EncodingUTF16LE && bytes.HasPrefix(head, bomUTF16LE):
+		encoding = dto_transaction.EncodingUTF16LE
+		if bytes.HasPrefix(head, bomUTF16LE) {
+			buffered.Discard(len(bomUTF16LE))
+		}
+	case encoding == dto_transaction.EncodingUTF16BE && bytes.HasPrefix(head, bomUTF16BE):
+		buffered.Discard(len(bomUTF16BE))
+	}
+
+	switch encoding {
+	case dto_transaction.EncodingUTF16LE:
+		return &decoder{source: buffered, decode: utf16Decoder(binary.LittleEndian)}, nil
+	case dto_transaction.EncodingUTF16BE:
+		return &decoder{source: buffered, decode: utf16Decoder(binary.BigEndian)}, nil
+	case dto_transaction.EncodingWindows1252:
+		return &decoder{source: buffered, decode: decodeWindows1252}, nil
+	case dto_transaction.EncodingISO88591:
+		return &decoder{source: buffered, decode: decodeISO88591}, nil
+	}
+
+	return buffered, nil
+}
+
+// detectEncoding guesses the encoding of a file from its first bytes.
+func detectEncoding(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return dto_transaction.EncodingUTF8
+	case bytes.HasPrefix(head, bomUTF16LE), bytes.HasPrefix(head, bomUTF16BE):
+		return dto_transaction.EncodingUTF16
+	}
+
+	// Text in UTF-16 without a BOM gives itself away by the zero bytes of
+	// its ASCII characters, which a CSV file has no other use for.
+	var even, odd int
+	for index, char := range head {
+		if char == 0 && index%2 == 0 {
+			even++
+		} else if char == 0 {
+			odd++
+		}
+	}
+
+	switch {
+	case odd > len(head)/4:
+		return dto_transaction.EncodingUTF16LE
+	case even > len(head)/4:
+		return dto_transaction.EncodingUTF16BE
+	}
+
+	// The sample may end partway through a character.
+	for cut := 0; cut < utf8.UTFMax && cut <= len(head); cut++ {
+		if utf8.Valid(head[:len(head)-cut]) {
+			return dto_transaction.EncodingUTF8
+		}
+	}
+
+	return dto_transaction.EncodingWindows1252
+}
+
+// decoder converts text to UTF-8 as it is read. decode converts as much of
+// the input as forms whole characters, and reports how much that was.
+type decoder struct {
+	source  io.Reader
+	decode  func(input []byte) (output []byte, used int)
+	pending []byte
+	output  []byte
+	err     error
+}
+
+func (d *decoder) Read(buffer []byte) (int, error) {
+	for len(d.output) == 0 {
+		if d.err != nil {
+			// Input that ends partway through a character
+			if len(d.pending) > 0 {
+				d.output, d.pending = utf8.AppendRune(nil, utf8.RuneError), nil
+				continue
+			}
+			return 0, d.err
+		}
+
+		chunk := make([]byte, 4096)
+		n, err := d.source.Read(chunk)
+		d.pending = append(d.pending, chunk[:n]...)
+		d.err = err
+
+		output, used := d.decode(d.pending)
+		d.output, d.pending = output, d.pending[used:]
+	}
+
+	n := copy(buffer, d.output)
+	d.output = d.output[n:]
+	return n, nil
+}
+
+func decodeISO88591(input []byte) ([]byte, int) {
+	output := make([]byte, 0, len(input)*2)
+	for _, char := range input {
+		output = utf8.AppendRune(output, rune(char))
+	}
+
+	return output, len(input)
+}
+
+func decodeWindows1252(input []byte) ([]byte, int) {
+	output := make([]byte, 0, len(input)*2)
+	for _, char := range input {
+		if char >= 0x80 && char < 0xA0 {
+			output = utf8.AppendRune(output, windows1252[char-0x80])
+		} else {
+			output = utf8.AppendRune(output, rune(char))
+		}
+	}
+
+	return output, len(input)
+}
+
+// utf16Decoder decodes UTF-16 in the byte order. An unpaired surrogate
+// becomes U+FFFD.
+func utf16Decoder(order binary.ByteOrder) func([]byte) ([]byte, int) {
+	return func(input []byte) ([]byte, int) {
+		output := make([]byte, 0, len(input))
+
+		used := 0
+		for used+2 <= len(input) {
+			unit := rune(order.Uint16(input[used:]))
+
+			if !utf16.IsSurrogate(unit) {
+				output = utf8.AppendRune(output, unit)
+				used += 2
+				continue
+			}
+
+			// Wait for the second half of a pair.
+			if used+4 > len(input) {
+				break
+			}
+
+			char := utf16.DecodeRune(unit, rune(order.Uint16(input[used+2:])))
+			if char == utf8.RuneError {
+				output = utf8.AppendRune(output, utf8.RuneError)
+				used += 2
+				continue
+			}
+
+			output = utf8.AppendRune(output, char)
+			used += 4
+		}
+
+		return output, used
+	}
+}
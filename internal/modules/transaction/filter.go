@@ -0,0 +1,119 @@
+package transaction
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+const dateLayout = "2006-01-02"
+
+// parseFilter validates the GET /transactions query and turns it into a
+// filter over userID's transactions. Every error wraps
+// domain.ErrInvalidFilter.
+func parseFilter(query dto_transaction.TransactionFilterDTO, userID string) (domain.TransactionFilter, error) {
+	filter := domain.TransactionFilter{
+		UserID:           userID,
+		Name:             strings.TrimSpace(query.Name),
+		DescriptionTerms: strings.Fields(query.Q),
+	}
+
+	var err error
+
+	if filter.From, err = parseFilterTime(query.From, false); err != nil {
+		return filter, fmt.Errorf("%w: from: %v", domain.ErrInvalidFilter, err)
+	}
+
+	if filter.To, err = parseFilterTime(query.To, true); err != nil {
+		return filter, fmt.Errorf("%w: to: %v", domain.ErrInvalidFilter, err)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return filter, fmt.Errorf("%w: from is after to", domain.ErrInvalidFilter)
+	}
+
+	for _, value := range splitList(query.Type) {
+		transactionType := domain.TransactionType(value)
+		if !transactionType.IsValid() {
+			return filter, fmt.Errorf("%w: invalid type: %s", domain.ErrInvalidFilter, value)
+		}
+		filter.Types = append(filter.Types, transactionType)
+	}
+
+	for _, value := range splitList(query.Status) {
+		status := domain.TransactionStatus(value)
+		if !status.IsValid() {
+			return filter, fmt.Errorf("%w: invalid status: %s", domain.ErrInvalidFilter, value)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	if filter.MinAmount, err = parseFilterAmount(query.MinAmount); err != nil {
+		return filter, fmt.Errorf("%w: minAmount: %v", domain.ErrInvalidFilter, err)
+	}
+
+	if filter.MaxAmount, err = parseFilterAmount(query.MaxAmount); err != nil {
+		return filter, fmt.Errorf("%w: maxAmount: %v", domain.ErrInvalidFilter, err)
+	}
+
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, fmt.Errorf("%w: minAmount is above maxAmount", domain.ErrInvalidFilter)
+	}
+
+	return filter, nil
+}
+
+// parseFilterTime accepts an RFC 3339 time or a date. A date used as the
+// end of a range covers that whole day.
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if date, err := time.Parse(dateLayout, value); err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return date, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", value)
+	}
+
+	return parsed, nil
+}
+
+func parseFilterAmount(value string) (*int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || amount < 0 {
+		return nil, fmt.Errorf("expected a non-negative integer, got %q", value)
+	}
+
+	return &amount, nil
+}
+
+// splitList splits a comma separated list into distinct, upper-cased values.
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if item != "" && !slices.Contains(values, item) {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
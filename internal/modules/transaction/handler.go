@@ -1,7 +1,7 @@
 package transaction
 
 import (
-	"strings"
+	"errors"
 
 	"firstpersoncode/go-uploader/domain"
 	"firstpersoncode/go-uploader/dto"
@@ -24,8 +24,16 @@ func (api *transactionHandler) UploadStatement(ctx *fiber.Ctx) error {
 		return ctx.Status(400).JSON(dto.CreateErrorResponse("No file uploaded"))
 	}
 
-	if !strings.HasSuffix(file.Filename, ".csv") {
-		return ctx.Status(400).JSON(dto.CreateErrorResponse("Only CSV files are allowed"))
+	var upload dto_transaction.UploadRequestDTO
+	if err := ctx.QueryParser(&upload); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	upload.Filename = file.Filename
+	upload.IdempotencyKey = ctx.Get("Idempotency-Key")
+
+	if err := upload.Validate(); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
 	}
 
 	fileContent, err := file.Open()
@@ -36,9 +44,10 @@ func (api *transactionHandler) UploadStatement(ctx *fiber.Ctx) error {
 
 	session := ctx.Locals("session").(*domain.Session)
 
-	response, err := api.service.ParseAndStoreCSV(fileContent, session.UserID)
+	response, err := api.service.ParseAndStoreStatement(fileContent, upload, session.UserID)
 	if err != nil {
-		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+		// Rejected rows are listed in the response.
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponseWithData(err.Error(), response))
 	}
 
 	return ctx.JSON(dto.CreateSuccessResponse("Statement uploaded successfully", response))
@@ -67,12 +76,205 @@ func (api *transactionHandler) GetIssues(ctx *fiber.Ctx) error {
 		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
 	}
 
+	if err := sorting.Validate(); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
 	session := ctx.Locals("session").(*domain.Session)
 
 	response, err := api.service.GetIssues(pagination, sorting, session.UserID)
 	if err != nil {
-		return ctx.Status(500).JSON(dto.CreateErrorResponse(err.Error()))
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
 	}
 
 	return ctx.JSON(dto.CreateSuccessResponse("Issues retrieved successfully", response))
 }
+
+func (api *transactionHandler) SearchTransactions(ctx *fiber.Ctx) error {
+	var filter dto_transaction.TransactionFilterDTO
+	var pagination dto_transaction.PaginationDTO
+	var sorting dto_transaction.SortingDTO
+
+	if err := ctx.QueryParser(&filter); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	if err := ctx.QueryParser(&pagination); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	if err := ctx.QueryParser(&sorting); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	if err := sorting.Validate(); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.SearchTransactions(filter, pagination, sorting, session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Transactions retrieved successfully", response))
+}
+
+func (api *transactionHandler) GetTransaction(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.GetTransaction(ctx.Params("id"), session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Transaction retrieved successfully", response))
+}
+
+func (api *transactionHandler) UpdateTransaction(ctx *fiber.Ctx) error {
+	var request dto_transaction.UpdateTransactionRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.UpdateTransaction(ctx.Params("id"), request, session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Transaction updated successfully", response))
+}
+
+func (api *transactionHandler) DeleteTransaction(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := api.service.DeleteTransaction(ctx.Params("id"), session.UserID); err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Transaction deleted successfully", map[string]interface{}{}))
+}
+
+func (api *transactionHandler) ListUploads(ctx *fiber.Ctx) error {
+	var pagination dto_transaction.PaginationDTO
+	if err := ctx.QueryParser(&pagination); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid query parameters"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.ListUploads(pagination, session.UserID)
+	if err != nil {
+		return ctx.Status(500).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Uploads retrieved successfully", response))
+}
+
+func (api *transactionHandler) GetUpload(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.GetUpload(ctx.Params("id"), session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Upload retrieved successfully", response))
+}
+
+func (api *transactionHandler) DeleteUpload(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.DeleteUpload(ctx.Params("id"), session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Upload rolled back successfully", response))
+}
+
+func (api *transactionHandler) ListImportProfiles(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.ListImportProfiles(session.UserID)
+	if err != nil {
+		return ctx.Status(500).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Import profiles retrieved successfully", response))
+}
+
+func (api *transactionHandler) GetImportProfile(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.GetImportProfile(ctx.Params("id"), session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Import profile retrieved successfully", response))
+}
+
+func (api *transactionHandler) CreateImportProfile(ctx *fiber.Ctx) error {
+	var request dto_transaction.ImportProfileRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.CreateImportProfile(request, session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.Status(201).JSON(dto.CreateSuccessResponse("Import profile created successfully", response))
+}
+
+func (api *transactionHandler) UpdateImportProfile(ctx *fiber.Ctx) error {
+	var request dto_transaction.ImportProfileRequestDTO
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(400).JSON(dto.CreateErrorResponse("Invalid request body"))
+	}
+
+	session := ctx.Locals("session").(*domain.Session)
+
+	response, err := api.service.UpdateImportProfile(ctx.Params("id"), request, session.UserID)
+	if err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Import profile updated successfully", response))
+}
+
+func (api *transactionHandler) DeleteImportProfile(ctx *fiber.Ctx) error {
+	session := ctx.Locals("session").(*domain.Session)
+
+	if err := api.service.DeleteImportProfile(ctx.Params("id"), session.UserID); err != nil {
+		return ctx.Status(transactionErrorStatus(err)).JSON(dto.CreateErrorResponse(err.Error()))
+	}
+
+	return ctx.JSON(dto.CreateSuccessResponse("Import profile deleted successfully", map[string]interface{}{}))
+}
+
+// transactionErrorStatus reports what is wrong with the request or the
+// uploaded file as 400, a missing transaction, upload or import profile as
+// 404, and a conflict with what is stored as 409. Anything else is a failure
+// to carry out a valid request.
+func transactionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidFilter), errors.Is(err, domain.ErrInvalidCursor), errors.Is(err, domain.ErrInvalidRows),
+		errors.Is(err, domain.ErrInvalidStatement), errors.Is(err, domain.ErrInvalidTransaction), errors.Is(err, domain.ErrInvalidImportProfile):
+		return 400
+	case errors.Is(err, domain.ErrTransactionNotFound), errors.Is(err, domain.ErrUploadNotFound), errors.Is(err, domain.ErrImportProfileNotFound):
+		return 404
+	case errors.Is(err, domain.ErrImportProfileNameTaken), errors.Is(err, domain.ErrDuplicateUpload), errors.Is(err, domain.ErrIdempotencyKeyConflict),
+		errors.Is(err, domain.ErrUploadNotCompleted):
+		return 409
+	}
+
+	return 500
+}
@@ -0,0 +1,348 @@
+package transaction
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// maxDecimalPlaces keeps scaled amounts well within an int64.
+const maxDecimalPlaces = 6
+
+// layout is where a statement's fields are in its rows, from an import
+// profile or the fixed six columns.
+type layout struct {
+	delimiter rune
+	quote     byte
+	skipRows  int
+	// columns holds the 0-based column of each field read from the file,
+	// including, once the header row is read, those in headers.
+	columns map[string]int
+	// headers holds the header name of each field mapped by name.
+	headers  map[string]string
+	defaults map[string]string
+	// byName lets a header row naming every field reorder the fixed columns.
+	byName bool
+	// width is how many fields a row must have, when known. Without it a row
+	// only has to reach the last mapped column.
+	width int
+	// dateLayout is a Go layout read in location. Without one, timestamps
+	// are Unix seconds or RFC 3339.
+	dateLayout string
+	location   *time.Location
+	// decimal and thousands separate amounts' digits; thousands is 0 when
+	// amounts have no thousands separator. places is the number of decimal
+	// places amounts are stored with.
+	decimal   rune
+	thousands rune
+	places    int
+	sign      string
+}
+
+// defaultLayout reads the six fields in csvFields order.
+func defaultLayout() *layout {
+	format := &layout{
+		delimiter: ',',
+		quote:     '"',
+		columns:   make(map[string]int),
+		byName:    true,
+		width:     len(csvFields),
+		location:  time.UTC,
+		decimal:   '.',
+		sign:      domain.SignConventionType,
+	}
+	for index, field := range csvFields {
+		format.columns[field] = index
+	}
+
+	return format
+}
+
+// newLayout checks an import profile and returns the layout it describes.
+// Without columns, the profile keeps the fixed six.
+func newLayout(profile *domain.ImportProfile) (*layout, error) {
+	format := defaultLayout()
+
+	if profile.SkipRows < 0 {
+		return nil, fmt.Errorf("skip_rows must not be negative")
+	}
+	format.skipRows = profile.SkipRows
+
+	if profile.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(profile.Delimiter)
+		if size != len(profile.Delimiter) || delimiter == utf8.RuneError || strings.ContainsRune("\"\r\n", delimiter) {
+			return nil, fmt.Errorf("invalid delimiter %q, expected a single character other than a quote or line break", profile.Delimiter)
+		}
+		format.delimiter = delimiter
+	}
+
+	if profile.Quote != "" {
+		if len(profile.Quote) != 1 || profile.Quote[0] >= utf8.RuneSelf || profile.Quote[0] == '\r' || profile.Quote[0] == '\n' {
+			return nil, fmt.Errorf("invalid quote %q, expected a single ASCII character other than a line break", profile.Quote)
+		}
+		format.quote = profile.Quote[0]
+	}
+
+	if format.delimiter == rune(format.quote) {
+		return nil, fmt.Errorf("delimiter and quote must differ")
+	}
+
+	if len(profile.Columns) > 0 {
+		format.columns = make(map[string]int)
+		format.headers = make(map[string]string)
+		format.byName = false
+		format.width = 0
+	}
+
+	for field, source := range profile.Columns {
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf("unknown field %q in columns, expected one of %s", field, strings.Join(csvFields, ", "))
+		}
+
+		source = strings.TrimSpace(source)
+		if number, err := strconv.Atoi(source); err == nil {
+			if number < 1 {
+				return nil, fmt.Errorf("invalid column %d for %s, columns are numbered from 1", number, field)
+			}
+			format.columns[field] = number - 1
+		} else if source != "" {
+			format.headers[field] = source
+		} else {
+			return nil, fmt.Errorf("column for %s is empty", field)
+		}
+	}
+
+	if err := format.setValueFormats(profile); err != nil {
+		return nil, err
+	}
+
+	format.defaults = make(map[string]string, len(profile.Defaults))
+	for field, value := range profile.Defaults {
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf("unknown field %q in defaults, expected one of %s", field, strings.Join(csvFields, ", "))
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("default for %s is empty", field)
+		}
+		format.defaults[field] = value
+	}
+
+	format.sign = profile.SignConvention
+	switch format.sign {
+	case "":
+		format.sign = domain.SignConventionNegativeDebit
+		if format.reads("type") {
+			format.sign = domain.SignConventionType
+		}
+	case domain.SignConventionType, domain.SignConventionNegativeDebit, domain.SignConventionNegativeCredit:
+	default:
+		return nil, fmt.Errorf("invalid sign_convention %q, expected %s, %s or %s", profile.SignConvention,
+			domain.SignConventionType, domain.SignConventionNegativeDebit, domain.SignConventionNegativeCredit)
+	}
+
+	for field, value := range format.defaults {
+		if err := format.setField(&domain.Transaction{}, field, value); err != nil {
+			return nil, fmt.Errorf("invalid default for %s: %v", field, err)
+		}
+	}
+
+	for _, field := range csvFields {
+		// With a signed amount, the sign is the type.
+		if field == "type" && format.sign != domain.SignConventionType {
+			if format.reads(field) {
+				return nil, fmt.Errorf("type can't have a column or a default with sign_convention %s", format.sign)
+			}
+			continue
+		}
+
+		if !format.reads(field) {
+			return nil, fmt.Errorf("%s needs a column or a default", field)
+		}
+	}
+
+	return format, nil
+}
+
+// setValueFormats checks the profile's date and number formats and sets
+// them on the layout.
+func (l *layout) setValueFormats(profile *domain.ImportProfile) error {
+	if profile.DateLayout != "" {
+		parsed, err := time.Parse(profile.DateLayout, referenceTime.Format(profile.DateLayout))
+		if err != nil || parsed.Year() != referenceTime.Year() || parsed.Month() != referenceTime.Month() || parsed.Day() != referenceTime.Day() {
+			return fmt.Errorf("invalid date_layout %q, expected a Go reference layout with the year, month and day, such as 2006-01-02", profile.DateLayout)
+		}
+		l.dateLayout = profile.DateLayout
+	}
+
+	if profile.TimeZone != "" {
+		location, err := time.LoadLocation(profile.TimeZone)
+		if err != nil || strings.EqualFold(profile.TimeZone, "Local") {
+			return fmt.Errorf("invalid time_zone %q, expected an IANA name such as Europe/Berlin", profile.TimeZone)
+		}
+		l.location = location
+	}
+
+	separator := func(name string, value string) (rune, error) {
+		char, size := utf8.DecodeRuneInString(value)
+		if size != len(value) || char == utf8.RuneError || unicode.IsDigit(char) || strings.ContainsRune("+-()", char) {
+			return 0, fmt.Errorf("invalid %s %q, expected a single character other than a digit, sign or parenthesis", name, value)
+		}
+		return char, nil
+	}
+
+	var err error
+	if profile.DecimalSeparator != "" {
+		if l.decimal, err = separator("decimal_separator", profile.DecimalSeparator); err != nil {
+			return err
+		}
+	}
+
+	if profile.ThousandsSeparator != "" {
+		if l.thousands, err = separator("thousands_separator", profile.ThousandsSeparator); err != nil {
+			return err
+		}
+	}
+
+	if l.decimal == l.thousands {
+		return fmt.Errorf("decimal_separator and thousands_separator must differ")
+	}
+
+	if profile.DecimalPlaces < 0 || profile.DecimalPlaces > maxDecimalPlaces {
+		return fmt.Errorf("decimal_places must be between 0 and %d", maxDecimalPlaces)
+	}
+	l.places = profile.DecimalPlaces
+
+	return nil
+}
+
+// reads tells whether the layout has a column or a default for the field.
+func (l *layout) reads(field string) bool {
+	_, numbered := l.columns[field]
+	_, named := l.headers[field]
+	_, defaulted := l.defaults[field]
+	return numbered || named || defaulted
+}
+
+// isHeader tells whether the first row is a header rather than data: it
+// isn't a valid row, and it either names a field or has no digits at all,
+// where a row's timestamp and amount have some.
+func (l *layout) isHeader(record []string) bool {
+	if _, rowErrors := l.parseRecord(record, 0); len(rowErrors) == 0 {
+		return false
+	}
+
+	for _, cell := range record {
+		if slices.Contains(csvFields, strings.ToLower(strings.TrimSpace(cell))) {
+			return true
+		}
+	}
+
+	return !strings.ContainsAny(strings.Join(record, ""), "0123456789")
+}
+
+// useHeader finds the columns mapped by name in the header row, and checks
+// that it reaches every mapped column. Rows must then have as many fields as
+// the header.
+func (l *layout) useHeader(header []string) error {
+	positions := make(map[string]int, len(header))
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, exists := positions[name]; !exists {
+			positions[name] = index
+		}
+	}
+
+	if l.byName && !slices.ContainsFunc(csvFields, func(field string) bool {
+		_, exists := positions[field]
+		return !exists
+	}) {
+		for _, field := range csvFields {
+			l.columns[field] = positions[field]
+		}
+	}
+
+	for field, name := range l.headers {
+		index, exists := positions[strings.ToLower(name)]
+		if !exists {
+			return fmt.Errorf("column %q not found in the header row", name)
+		}
+		l.columns[field] = index
+	}
+
+	for _, field := range csvFields {
+		if index, mapped := l.columns[field]; mapped && index >= len(header) {
+			return fmt.Errorf("the header row has %d columns, %s is expected in column %d", len(header), field, index+1)
+		}
+	}
+
+	l.width = len(header)
+	return nil
+}
+
+// parseRecord turns one row into a transaction, or explains what is wrong
+// with each of its bad fields.
+func (l *layout) parseRecord(record []string, line int) (domain.Transaction, []dto_transaction.RowErrorDTO) {
+	if err := l.checkWidth(len(record)); err != nil {
+		return domain.Transaction{}, []dto_transaction.RowErrorDTO{{Line: line, Reason: err.Error()}}
+	}
+
+	var transaction domain.Transaction
+	var rowErrors []dto_transaction.RowErrorDTO
+
+	for _, field := range csvFields {
+		// The amount's sign sets the type instead.
+		if field == "type" && l.sign != domain.SignConventionType {
+			continue
+		}
+
+		value := l.defaults[field]
+		column := 0
+
+		if index, mapped := l.columns[field]; mapped {
+			column = index + 1
+			if cell := strings.TrimSpace(record[index]); cell != "" {
+				value = cell
+			}
+		}
+
+		var err error
+		if value == "" {
+			err = fmt.Errorf("%s is required", field)
+		} else {
+			err = l.setField(&transaction, field, value)
+		}
+
+		if err != nil {
+			rowErrors = append(rowErrors, dto_transaction.RowErrorDTO{Line: line, Column: column, Field: field, Reason: err.Error()})
+		}
+	}
+
+	return transaction, rowErrors
+}
+
+// checkWidth explains why a row with count fields doesn't fit the layout.
+func (l *layout) checkWidth(count int) error {
+	if l.width > 0 && count != l.width {
+		return fmt.Errorf("expected %d fields, got %d", l.width, count)
+	}
+
+	needed := 0
+	for _, index := range l.columns {
+		needed = max(needed, index+1)
+	}
+
+	if count < needed {
+		return fmt.Errorf("expected at least %d fields, got %d", needed, count)
+	}
+
+	return nil
+}
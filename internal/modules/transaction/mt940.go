@@ -0,0 +1,291 @@
+package transaction
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// mt940Amounts reads MT940 amounts, which are unsigned and have a decimal
+// comma, into the minor unit of the currency of the account's balance.
+var mt940Amounts = &layout{decimal: ',', places: 2, sign: domain.SignConventionType}
+
+var (
+	// mt940Tag starts a field, as in ":61:" or ":60F:".
+	mt940Tag = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+	// mt940Entry is a :61: statement line: value date, entry date, debit or
+	// credit mark, funds code, amount, transaction type, and the account
+	// owner's and the bank's references.
+	mt940Entry = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?([\d,]+)([A-Z][A-Z0-9]{3})(.*?)(?://(.*))?$`)
+	// mt940Balance is a balance: debit or credit mark, date, currency and
+	// amount.
+	mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})([\d,]+)$`)
+	// mt940Code starts a subfield of a structured :86:, as in "/REMI/".
+	mt940Code = regexp.MustCompile(`/([A-Z]{2,4})/`)
+)
+
+// mt940Transaction is a :61: statement line waiting for its :86:.
+type mt940Transaction struct {
+	transaction domain.Transaction
+	errors      []dto_transaction.RowErrorDTO
+	line        int
+	// details is the :61: line's supplementary details, the fallback for
+	// the name and description.
+	details string
+}
+
+// parseMT940 reads the :61: statement lines of SWIFT MT940 statements, and
+// of MT942 interim reports, whose entries are pending. A file may hold
+// several messages, each for the account in its :25:; an account's opening
+// balance is its first message's and its closing balance its last one's.
+func parseMT940(text io.Reader) (*statement, error) {
+	scanner := bufio.NewScanner(text)
+	parsed := &statement{}
+
+	var tag string
+	var value []string
+	var tagLine int
+	var entry *mt940Transaction
+	var failure error
+	var account string
+	// currency is the account's, from its opening balance or an MT942's
+	// floor limit, which come before the entries.
+	var currency string
+	pending := false
+
+	flush := func() {
+		if entry != nil {
+			transaction, rowErrors := entry.finish()
+			parsed.add(entry.line, transaction, rowErrors)
+			entry = nil
+		}
+	}
+
+	closeField := func() {
+		if tag != "86" {
+			flush()
+		}
+
+		switch tag {
+		case "20":
+			pending, account, currency = false, "", ""
+		case "25":
+			account = strings.TrimSpace(value[0])
+		// Only an MT942 has a floor limit, which starts with the currency,
+		// and a date and time
+		case "34F", "13D":
+			pending = true
+			if tag == "34F" && len(value[0]) >= 3 {
+				currency = value[0][:3]
+			}
+		case "60F", "60M":
+			balance, balanceCurrency, err := parseMT940Balance(value[0])
+			if err != nil {
+				failure = cmp.Or(failure, fmt.Errorf("%w: opening balance on line %d: %v", domain.ErrInvalidStatement, tagLine, err))
+				break
+			}
+
+			currency = balanceCurrency
+			if stated := parsed.balance(account); stated.Opening == nil {
+				stated.Opening = &balance
+			}
+		case "62F", "62M":
+			balance, _, err := parseMT940Balance(value[0])
+			if err != nil {
+				failure = cmp.Or(failure, fmt.Errorf("%w: closing balance on line %d: %v", domain.ErrInvalidStatement, tagLine, err))
+			} else {
+				parsed.balance(account).Closing = &balance
+			}
+		case "61":
+			entry = parseMT940Entry(value, tagLine, account, currency, pending)
+		case "86":
+			if entry != nil {
+				entry.describe(value)
+				flush()
+			}
+		}
+
+		tag, value = "", nil
+	}
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		switch match := mt940Tag.FindStringSubmatch(text); {
+		// The header blocks before a message's fields, and the "-}" after
+		case strings.HasPrefix(text, "{"), text == "-", strings.HasPrefix(text, "-}"):
+			closeField()
+		case match != nil:
+			closeField()
+			tag, value, tagLine = match[1], []string{text[len(match[0]):]}, line
+		case tag != "":
+			value = append(value, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	closeField()
+	flush()
+
+	if failure != nil {
+		return nil, failure
+	}
+
+	if len(parsed.transactions) == 0 && parsed.failedRows == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", domain.ErrInvalidStatement)
+	}
+
+	return parsed, nil
+}
+
+// parseMT940Entry reads a :61: statement line, such as
+// 2403010301D12,50NTRFNONREF//B24030100001, with an amount in currency. The
+// bank's reference, when there is one, is a booked transaction's external
+// ID.
+func parseMT940Entry(value []string, line int, account string, currency string, pending bool) *mt940Transaction {
+	entry := &mt940Transaction{line: line}
+	entry.transaction.Account = account
+	entry.transaction.Status = domain.TransactionStatusSuccess
+	if pending {
+		entry.transaction.Status = domain.TransactionStatusPending
+	}
+
+	if len(value) > 1 {
+		entry.details = strings.TrimSpace(strings.Join(value[1:], " "))
+	}
+
+	match := mt940Entry.FindStringSubmatch(strings.TrimSpace(value[0]))
+	if match == nil {
+		entry.errors = append(entry.errors, dto_transaction.RowErrorDTO{Line: line, Reason: fmt.Sprintf("invalid :61: statement line %q", value[0])})
+		return entry
+	}
+
+	if timestamp, err := time.Parse("060102", match[1]); err != nil {
+		entry.invalid("timestamp", "invalid value date %q, expected YYMMDD", match[1])
+	} else {
+		entry.transaction.Timestamp = timestamp
+	}
+
+	// A reversal goes the other way.
+	switch match[3] {
+	case "C", "RD":
+		entry.transaction.Type = domain.TransactionTypeCredit
+	default:
+		entry.transaction.Type = domain.TransactionTypeDebit
+	}
+
+	amounts := mt940Amounts.inCurrency(currency)
+	if amount, _, err := amounts.parseAmount(strings.TrimSuffix(match[5], ",")); err != nil {
+		entry.invalid("amount", "invalid amount %q, expected %s", match[5], amounts.amountHint())
+	} else {
+		entry.transaction.Amount = amount
+	}
+
+	if !pending {
+		entry.transaction.ExternalID = strings.TrimSpace(match[8])
+	}
+
+	return entry
+}
+
+func (e *mt940Transaction) invalid(field string, format string, args ...any) {
+	e.errors = append(e.errors, dto_transaction.RowErrorDTO{Line: e.line, Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+// describe sets the name and description from an :86: line. German banks
+// structure it in ?NN subfields and others in /CODE/ ones; the rest write
+// it freely, with the name first.
+func (e *mt940Transaction) describe(value []string) {
+	joined := strings.Join(value, "")
+
+	switch {
+	case strings.Contains(joined, "?") && isDigits(strings.SplitN(joined, "?", 2)[0]):
+		subfields := make(map[string]string)
+		var remittance strings.Builder
+		for _, part := range strings.Split(joined, "?")[1:] {
+			if len(part) < 2 {
+				continue
+			}
+			key, text := part[:2], part[2:]
+			subfields[key] += text
+			// ?20 to ?29 and ?60 to ?63 hold the remittance information
+			if key[0] == '2' || key >= "60" && key <= "63" {
+				remittance.WriteString(text)
+			}
+		}
+
+		e.transaction.Name = strings.TrimSpace(subfields["32"] + subfields["33"])
+		e.transaction.Description = strings.TrimSpace(remittance.String())
+		if e.transaction.Description == "" {
+			e.transaction.Description = strings.TrimSpace(subfields["00"])
+		}
+
+	case strings.HasPrefix(joined, "/") && mt940Code.MatchString(joined):
+		codes := mt940Code.FindAllStringSubmatchIndex(joined, -1)
+		for index, code := range codes {
+			end := len(joined)
+			if index+1 < len(codes) {
+				end = codes[index+1][0]
+			}
+
+			text := strings.TrimSpace(strings.Trim(joined[code[1]:end], "/"))
+			switch joined[code[2]:code[3]] {
+			case "NAME":
+				e.transaction.Name = text
+			case "REMI":
+				e.transaction.Description = text
+			}
+		}
+
+	default:
+		e.transaction.Name = strings.TrimSpace(value[0])
+		e.transaction.Description = strings.TrimSpace(strings.Join(value, " "))
+	}
+}
+
+// finish fills in a missing name or description from what there is.
+func (e *mt940Transaction) finish() (domain.Transaction, []dto_transaction.RowErrorDTO) {
+	for _, fallback := range []string{e.transaction.Description, e.details} {
+		if e.transaction.Name == "" {
+			e.transaction.Name = fallback
+		}
+	}
+
+	if e.transaction.Description == "" {
+		e.transaction.Description = e.transaction.Name
+	}
+
+	if e.transaction.Name == "" && len(e.errors) == 0 {
+		e.invalid("name", "no name in the :86: line or the :61: supplementary details")
+	}
+
+	return e.transaction, e.errors
+}
+
+// parseMT940Balance reads a balance such as C240301EUR1234,56 into the
+// minor unit of its currency, which it also returns.
+func parseMT940Balance(value string) (int64, string, error) {
+	match := mt940Balance.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, "", fmt.Errorf("expected a balance like C240301EUR1234,56")
+	}
+
+	amount, _, err := mt940Amounts.inCurrency(match[3]).parseAmount(strings.TrimSuffix(match[4], ","))
+	if err != nil {
+		return 0, "", fmt.Errorf("expected a balance like C240301EUR1234,56")
+	}
+
+	if match[1] == "D" {
+		amount = -amount
+	}
+
+	return amount, match[3], nil
+}
@@ -0,0 +1,233 @@
+package transaction
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// ofxAmounts reads OFX amounts, which are signed and have a decimal point,
+// into cents.
+var ofxAmounts = &layout{decimal: '.', places: 2, sign: domain.SignConventionNegativeDebit}
+
+// ofxTypes maps the TRNTYPE values that always go one way to a type, which
+// the sign of the amount must agree with. The others, such as XFER or OTHER,
+// go by the sign of the amount.
+var ofxTypes = map[string]domain.TransactionType{
+	"CREDIT":      domain.TransactionTypeCredit,
+	"DEP":         domain.TransactionTypeCredit,
+	"DIRECTDEP":   domain.TransactionTypeCredit,
+	"DIV":         domain.TransactionTypeCredit,
+	"INT":         domain.TransactionTypeCredit,
+	"ATM":         domain.TransactionTypeDebit,
+	"CASH":        domain.TransactionTypeDebit,
+	"CHECK":       domain.TransactionTypeDebit,
+	"DEBIT":       domain.TransactionTypeDebit,
+	"DIRECTDEBIT": domain.TransactionTypeDebit,
+	"FEE":         domain.TransactionTypeDebit,
+	"PAYMENT":     domain.TransactionTypeDebit,
+	"POS":         domain.TransactionTypeDebit,
+	"REPEATPMT":   domain.TransactionTypeDebit,
+	"SRVCHG":      domain.TransactionTypeDebit,
+}
+
+var ofxSignedTypes = []string{"HOLD", "OTHER", "XFER"}
+
+// parseOFX reads the STMTTRN records of an OFX statement, from bank and
+// credit card statements alike. OFX 1.x is SGML, where elements holding a
+// value needn't be closed, and 2.x is XML; reading each value up to the
+// next tag covers both. Each record is for the account of the last ACCTID
+// before it, as a file may hold statements for several.
+func parseOFX(text io.Reader) (*statement, error) {
+	reader := bufio.NewReader(text)
+	parsed := &statement{}
+
+	line := 1
+	found := false
+	// record holds the values of the STMTTRN being read, by element.
+	var record map[string]string
+	var recordLine int
+	var element string
+	var account string
+
+	for {
+		content, err := reader.ReadString('<')
+		line += strings.Count(content, "\n")
+
+		switch value := strings.TrimSpace(strings.TrimSuffix(content, "<")); {
+		case value == "":
+		case record != nil && element != "":
+			if _, exists := record[element]; !exists {
+				record[element] = html.UnescapeString(value)
+			}
+		// A transfer's STMTTRN has an ACCTID of its own, for the other account
+		case record == nil && element == "ACCTID":
+			account = html.UnescapeString(value)
+		}
+		element = ""
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tag, err := reader.ReadString('>')
+		line += strings.Count(tag, "\n")
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: unterminated OFX tag on line %d", domain.ErrInvalidStatement, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tag = strings.TrimSpace(strings.TrimSuffix(tag, ">"))
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.ToUpper(strings.TrimPrefix(tag, "/"))
+
+		switch {
+		// The XML declaration, processing instructions and comments
+		case strings.HasPrefix(tag, "?"), strings.HasPrefix(tag, "!"):
+		case name == "OFX":
+			found = true
+		case name == "STMTTRN" && closing && record != nil:
+			transaction, rowErrors := ofxTransaction(record, account, recordLine)
+			parsed.add(recordLine, transaction, rowErrors)
+			record = nil
+		case name == "STMTTRN" && !closing:
+			if record != nil {
+				parsed.fail(dto_transaction.RowErrorDTO{Line: recordLine, Reason: "STMTTRN is not closed"})
+			}
+			record = make(map[string]string)
+			recordLine = line
+		case !closing:
+			element = name
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: no OFX element found", domain.ErrInvalidStatement)
+	}
+
+	if record != nil {
+		parsed.fail(dto_transaction.RowErrorDTO{Line: recordLine, Reason: "STMTTRN is not closed"})
+	}
+
+	if len(parsed.transactions) == 0 && parsed.failedRows == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", domain.ErrInvalidStatement)
+	}
+
+	return parsed, nil
+}
+
+// ofxTransaction turns the values of a STMTTRN into a transaction, or
+// explains what is wrong with each of its bad fields.
+func ofxTransaction(record map[string]string, account string, line int) (domain.Transaction, []dto_transaction.RowErrorDTO) {
+	transaction := domain.Transaction{
+		Status:     domain.TransactionStatusSuccess,
+		ExternalID: record["FITID"],
+		Account:    account,
+	}
+
+	var rowErrors []dto_transaction.RowErrorDTO
+	invalid := func(field string, format string, args ...any) {
+		rowErrors = append(rowErrors, dto_transaction.RowErrorDTO{Line: line, Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	// Transactions that haven't posted yet only have the user's date.
+	posted, exists := record["DTPOSTED"]
+	if !exists {
+		posted, exists = record["DTUSER"]
+	}
+
+	if timestamp, err := parseOFXTime(posted); !exists {
+		invalid("timestamp", "DTPOSTED is required")
+	} else if err != nil {
+		invalid("timestamp", "invalid DTPOSTED %q, expected a date like 20240131 or 20240131093000[-5:EST]", posted)
+	} else {
+		transaction.Timestamp = timestamp
+	}
+
+	// The payee's name, in 2.x possibly within a PAYEE, and the memo stand
+	// in for one another.
+	transaction.Name = record["NAME"]
+	transaction.Description = record["MEMO"]
+	if transaction.Name == "" {
+		transaction.Name = transaction.Description
+	}
+	if transaction.Description == "" {
+		transaction.Description = transaction.Name
+	}
+	if transaction.Name == "" {
+		invalid("name", "NAME or MEMO is required")
+	}
+
+	// Some banks write the decimal point as a comma.
+	amount, negative, amountErr := ofxAmounts.parseAmount(strings.Replace(record["TRNAMT"], ",", ".", 1))
+	if record["TRNAMT"] == "" {
+		invalid("amount", "TRNAMT is required")
+	} else if amountErr != nil {
+		invalid("amount", "invalid TRNAMT %q, expected an amount like -12.34", record["TRNAMT"])
+	}
+	transaction.Amount = amount
+
+	// When the sign and the TRNTYPE disagree there is no telling which of
+	// the two is wrong.
+	kind := strings.ToUpper(record["TRNTYPE"])
+	if transactionType, exists := ofxTypes[kind]; exists {
+		transaction.Type = transactionType
+		if amountErr == nil && amount != 0 && negative != (transactionType == domain.TransactionTypeDebit) {
+			invalid("amount", "TRNAMT %q contradicts TRNTYPE %q", record["TRNAMT"], record["TRNTYPE"])
+		}
+	} else if !slices.Contains(ofxSignedTypes, kind) {
+		invalid("type", "invalid TRNTYPE %q", record["TRNTYPE"])
+	} else if negative {
+		transaction.Type = domain.TransactionTypeDebit
+	} else {
+		transaction.Type = domain.TransactionTypeCredit
+	}
+
+	return transaction, rowErrors
+}
+
+// parseOFXTime reads an OFX date such as 20240131, 20240131093000 or
+// 20240131093000.000[-5:EST]. Without a zone it is in UTC.
+func parseOFXTime(value string) (time.Time, error) {
+	stamp, zone, hasZone := strings.Cut(value, "[")
+
+	location := time.UTC
+	if hasZone {
+		offset, _, _ := strings.Cut(strings.TrimSuffix(zone, "]"), ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil || math.Abs(hours) > 14 {
+			return time.Time{}, fmt.Errorf("invalid time zone")
+		}
+		location = time.FixedZone("", int(hours*3600))
+	}
+
+	// Fractions of a second are dropped.
+	stamp, _, _ = strings.Cut(stamp, ".")
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	dateLayout, exists := layouts[len(stamp)]
+	if !exists || !isDigits(stamp) {
+		return time.Time{}, fmt.Errorf("invalid date")
+	}
+
+	timestamp, err := time.ParseInLocation(dateLayout, stamp, location)
+	if err != nil || timestamp.Unix() <= 0 {
+		return time.Time{}, fmt.Errorf("invalid date")
+	}
+
+	return timestamp, nil
+}
@@ -0,0 +1,177 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+func (s *transactionService) ListImportProfiles(userID string) ([]dto_transaction.ImportProfileDTO, error) {
+	profiles, err := s.profiles.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto_transaction.ImportProfileDTO, 0, len(profiles))
+	for _, profile := range profiles {
+		response = append(response, toImportProfileDTO(profile))
+	}
+
+	return response, nil
+}
+
+func (s *transactionService) GetImportProfile(id string, userID string) (*dto_transaction.ImportProfileDTO, error) {
+	profile, err := s.findOwnedProfile(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toImportProfileDTO(profile)
+	return &response, nil
+}
+
+func (s *transactionService) CreateImportProfile(request dto_transaction.ImportProfileRequestDTO, userID string) (*dto_transaction.ImportProfileDTO, error) {
+	now := time.Now()
+	profile := &domain.ImportProfile{UserID: userID, CreatedAt: now}
+
+	if err := applyImportProfile(profile, request, now); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.profiles.Save(profile); err != nil {
+		return nil, err
+	}
+
+	response := toImportProfileDTO(profile)
+	return &response, nil
+}
+
+func (s *transactionService) UpdateImportProfile(id string, request dto_transaction.ImportProfileRequestDTO, userID string) (*dto_transaction.ImportProfileDTO, error) {
+	existing, err := s.findOwnedProfile(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := *existing
+	if err := applyImportProfile(&profile, request, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.profiles.Update(&profile); err != nil {
+		return nil, err
+	}
+
+	response := toImportProfileDTO(&profile)
+	return &response, nil
+}
+
+func (s *transactionService) DeleteImportProfile(id string, userID string) error {
+	if _, err := s.findOwnedProfile(id, userID); err != nil {
+		return err
+	}
+
+	return s.profiles.Delete(id)
+}
+
+// uploadLayout returns the layout of an upload made with the named profile,
+// found by ID or else by name.
+func (s *transactionService) uploadLayout(reference string, userID string) (*layout, error) {
+	if reference == "" {
+		return defaultLayout(), nil
+	}
+
+	profile, err := s.findOwnedProfile(reference, userID)
+	if errors.Is(err, domain.ErrImportProfileNotFound) {
+		profile, err = s.findProfileByName(reference, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newLayout(profile)
+}
+
+func (s *transactionService) findOwnedProfile(id string, userID string) (*domain.ImportProfile, error) {
+	profile, err := s.profiles.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.UserID != userID {
+		return nil, domain.ErrImportProfileNotFound
+	}
+
+	return profile, nil
+}
+
+func (s *transactionService) findProfileByName(name string, userID string) (*domain.ImportProfile, error) {
+	profiles, err := s.profiles.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profile := range profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, nil
+		}
+	}
+
+	return nil, domain.ErrImportProfileNotFound
+}
+
+// applyImportProfile sets the profile from the request, once the layout it
+// describes checks out.
+func applyImportProfile(profile *domain.ImportProfile, request dto_transaction.ImportProfileRequestDTO, now time.Time) error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", domain.ErrInvalidImportProfile)
+	}
+
+	if len(name) > 100 {
+		return fmt.Errorf("%w: name must be at most 100 characters", domain.ErrInvalidImportProfile)
+	}
+
+	profile.Name = name
+	profile.Delimiter = request.Delimiter
+	profile.Quote = request.Quote
+	profile.SkipRows = request.SkipRows
+	profile.Columns = request.Columns
+	profile.Defaults = request.Defaults
+	profile.DateLayout = request.DateLayout
+	profile.TimeZone = request.TimeZone
+	profile.DecimalSeparator = request.DecimalSeparator
+	profile.ThousandsSeparator = request.ThousandsSeparator
+	profile.DecimalPlaces = request.DecimalPlaces
+	profile.SignConvention = request.SignConvention
+	profile.UpdatedAt = now
+
+	if _, err := newLayout(profile); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidImportProfile, err)
+	}
+
+	return nil
+}
+
+func toImportProfileDTO(profile *domain.ImportProfile) dto_transaction.ImportProfileDTO {
+	return dto_transaction.ImportProfileDTO{
+		ID:                 profile.ID,
+		Name:               profile.Name,
+		Delimiter:          profile.Delimiter,
+		Quote:              profile.Quote,
+		SkipRows:           profile.SkipRows,
+		Columns:            profile.Columns,
+		Defaults:           profile.Defaults,
+		DateLayout:         profile.DateLayout,
+		TimeZone:           profile.TimeZone,
+		DecimalSeparator:   profile.DecimalSeparator,
+		ThousandsSeparator: profile.ThousandsSeparator,
+		DecimalPlaces:      profile.DecimalPlaces,
+		SignConvention:     profile.SignConvention,
+		CreatedAt:          profile.CreatedAt,
+		UpdatedAt:          profile.UpdatedAt,
+	}
+}
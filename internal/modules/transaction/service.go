@@ -1,73 +1,129 @@
 package transaction
 
 import (
-	"encoding/csv"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"firstpersoncode/go-uploader/domain"
 	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"firstpersoncode/go-uploader/internal/util"
 )
 
 type transactionService struct {
-	repo domain.TransactionRepository
+	repo     domain.TransactionRepository
+	uploads  domain.UploadBatchRepository
+	profiles domain.ImportProfileRepository
+	// cursors signs the next_cursor and prev_cursor of searches.
+	cursors *util.CursorSigner
+	// uploadLocks holds a *sync.Mutex per user ID, serialising each user's
+	// uploads and rollbacks so two uploads of the same rows can't both find
+	// them new before either is stored.
+	uploadLocks sync.Map
 }
 
-func NewTransactionService(repo domain.TransactionRepository) domain.TransactionService {
-	return &transactionService{repo: repo}
+func NewTransactionService(repo domain.TransactionRepository, uploads domain.UploadBatchRepository, profiles domain.ImportProfileRepository, cursors *util.CursorSigner) domain.TransactionService {
+	return &transactionService{repo: repo, uploads: uploads, profiles: profiles, cursors: cursors}
 }
 
-func (s *transactionService) ParseAndStoreCSV(fileContent io.Reader, userID string) (*dto_transaction.UploadResponseDTO, error) {
-	reader := csv.NewReader(fileContent)
-	reader.TrimLeadingSpace = true
+func (s *transactionService) ParseAndStoreStatement(fileContent io.Reader, upload dto_transaction.UploadRequestDTO, userID string) (*dto_transaction.UploadResponseDTO, error) {
+	format, err := s.uploadLayout(upload.Profile, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	var transactions []domain.Transaction
+	checksum := sha256.New()
+	// The checksum is over the file as uploaded, before it is decoded.
+	var parsed *statement
+	text, parseErr := decodeText(io.TeeReader(fileContent, checksum), upload.Encoding)
+	if parseErr == nil {
+		parsed, parseErr = parseStatement(text, format, upload.Profile != "", userID)
+	}
 
-	for lineNum := 0; ; lineNum++ {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
+	// The checksum covers the whole file, whatever parsing stopped at.
+	if _, err := io.Copy(checksum, fileContent); err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+	batch := &domain.UploadBatch{
+		UserID:         userID,
+		Filename:       upload.Filename,
+		Checksum:       hex.EncodeToString(checksum.Sum(nil)),
+		UploadedAt:     time.Now(),
+		Status:         domain.UploadStatusProcessing,
+		IdempotencyKey: upload.IdempotencyKey,
+	}
+
+	if batch.IdempotencyKey != "" {
+		if response, err := s.replay(batch); response != nil || err != nil {
+			return response, err
 		}
+	}
+
+	if parseErr != nil {
+		return nil, s.fail(batch, parseErr)
+	}
+
+	batch.FailedRows = parsed.failedRows
+	batch.Balances = parsed.balances
+	if err := parsed.check(upload.OnError); err != nil {
+		err = s.fail(batch, err)
 
-		timestamp, _ := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
-		amount, _ := strconv.ParseInt(strings.TrimSpace(record[3]), 10, 64)
-
-		transaction := domain.Transaction{
-			Timestamp:   time.Unix(timestamp, 0),
-			Name:        strings.TrimSpace(record[1]),
-			Type:        domain.TransactionType(strings.ToUpper(strings.TrimSpace(record[2]))),
-			Amount:      amount,
-			Status:      domain.TransactionStatus(strings.ToUpper(strings.TrimSpace(record[4]))),
-			Description: strings.TrimSpace(record[5]),
-			UserID:      userID,
+		// None of the valid rows were stored either, but they still count.
+		response := toUploadResponse(batch, parsed.errors)
+		response.TotalRows += len(parsed.transactions)
+		return response, err
+	}
+
+	transactions := parsed.transactions
+
+	defer s.lockUploads(userID)()
+
+	if _, err := s.uploads.Save(batch); err != nil {
+		// A concurrent request with the same key got there first.
+		if errors.Is(err, domain.ErrIdempotencyKeyConflict) {
+			return s.replay(batch)
 		}
+		return nil, err
+	}
+
+	for index := range transactions {
+		transactions[index].BatchID = batch.ID
+	}
 
-		transactions = append(transactions, transaction)
+	stored, err := s.dropDuplicates(batch, transactions, upload.OnDuplicate)
+	if err != nil {
+		return nil, s.fail(batch, err)
 	}
 
-	if len(transactions) == 0 {
-		return nil, fmt.Errorf("no transactions found")
+	if len(stored) > 0 {
+		if err := s.repo.SaveAll(stored); err != nil {
+			return nil, s.fail(batch, err)
+		}
 	}
 
-	if err := s.repo.SaveAll(transactions); err != nil {
+	batch.RowCount = len(stored)
+	batch.SkippedRows = len(transactions) - len(stored)
+	batch.Status = domain.UploadStatusCompleted
+
+	if _, err := s.uploads.Update(batch); err != nil {
 		return nil, err
 	}
 
-	return &dto_transaction.UploadResponseDTO{
-		TotalRows:    len(transactions),
-		UploadStatus: "success",
-	}, nil
+	return toUploadResponse(batch, parsed.errors), nil
 }
 
 func (s *transactionService) CalculateBalance(userID string) (*dto_transaction.BalanceResponseDTO, error) {
-	transactions := s.repo.GetAllByUserID(userID)
+	transactions, err := s.repo.GetAllByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	var credits int64 = 0
 	var debits int64 = 0
 	var balance int64 = 0
@@ -92,25 +148,190 @@ func (s *transactionService) CalculateBalance(userID string) (*dto_transaction.B
 }
 
 func (s *transactionService) GetIssues(pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, userID string) (*dto_transaction.IssuesResponseDTO, error) {
-	issues, total, err := s.repo.GetAllIssues(userID, pagination, sorting)
+	return s.search(domain.IssuesFilter(userID), pagination, sorting)
+}
+
+func (s *transactionService) SearchTransactions(filter dto_transaction.TransactionFilterDTO, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, userID string) (*dto_transaction.TransactionListResponseDTO, error) {
+	parsed, err := parseFilter(filter, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.search(parsed, pagination, sorting)
+}
+
+// search pages through the transactions matching filter, by page number or
+// by cursor, and returns the cursors of the neighbouring pages.
+func (s *transactionService) search(filter domain.TransactionFilter, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO) (*dto_transaction.TransactionListResponseDTO, error) {
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+
+	if pagination.Limit < 1 {
+		pagination.Limit = 10
+	}
+
+	var cursor *domain.TransactionCursor
+	if pagination.Cursor != "" {
+		var err error
+		cursor, sorting, err = s.decodeCursor(pagination.Cursor, sorting)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := sorting.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keyset pages ask for one extra row to learn whether there is another
+	// page in the direction of travel.
+	query := pagination
+	if cursor != nil {
+		query.Limit++
+	}
+
+	page, total, err := s.repo.Search(filter, query, sorting, cursor)
 	if err != nil {
 		return nil, err
 	}
 
-	var transactions []dto_transaction.TransactionDTO = make([]dto_transaction.TransactionDTO, 0, len(issues))
-	for _, tx := range issues {
-		transactions = append(transactions, dto_transaction.TransactionDTO{
-			Timestamp:   tx.Timestamp.Format(time.RFC3339),
-			Name:        tx.Name,
-			Type:        string(tx.Type),
-			Amount:      tx.Amount,
-			Status:      string(tx.Status),
-			Description: tx.Description,
-		})
+	var hasNext, hasPrev bool
+	switch {
+	case cursor == nil:
+		start := (pagination.Page - 1) * pagination.Limit
+		hasNext = start+len(page) < total
+		hasPrev = start > 0
+	case cursor.Backward:
+		hasNext = true
+		hasPrev = len(page) > pagination.Limit
+		if hasPrev {
+			page = page[1:]
+		}
+	default:
+		hasPrev = true
+		hasNext = len(page) > pagination.Limit
+		if hasNext {
+			page = page[:pagination.Limit]
+		}
 	}
 
-	return &dto_transaction.IssuesResponseDTO{
-		Transactions: transactions,
+	response := &dto_transaction.TransactionListResponseDTO{
+		Transactions: make([]dto_transaction.TransactionDTO, 0, len(page)),
 		Total:        total,
-	}, nil
+	}
+
+	for _, tx := range page {
+		response.Transactions = append(response.Transactions, toTransactionDTO(tx))
+	}
+
+	if len(page) == 0 {
+		return response, nil
+	}
+
+	if hasNext {
+		if response.NextCursor, err = s.encodeCursor(keys, page[len(page)-1], false); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasPrev {
+		if response.PrevCursor, err = s.encodeCursor(keys, page[0], true); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func (s *transactionService) GetTransaction(id string, userID string) (*dto_transaction.TransactionDTO, error) {
+	transaction, err := s.findOwned(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toTransactionDTO(*transaction)
+	return &response, nil
+}
+
+func (s *transactionService) UpdateTransaction(id string, request dto_transaction.UpdateTransactionRequestDTO, userID string) (*dto_transaction.TransactionDTO, error) {
+	transaction, err := s.findOwned(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Timestamp != nil {
+		timestamp, err := time.Parse(time.RFC3339, strings.TrimSpace(*request.Timestamp))
+		if err != nil {
+			return nil, fmt.Errorf("%w: timestamp must be RFC 3339", domain.ErrInvalidTransaction)
+		}
+		transaction.Timestamp = timestamp
+	}
+
+	if request.Name != nil {
+		transaction.Name = strings.TrimSpace(*request.Name)
+	}
+
+	if request.Type != nil {
+		transaction.Type = domain.TransactionType(strings.ToUpper(strings.TrimSpace(*request.Type)))
+	}
+
+	if request.Amount != nil {
+		transaction.Amount = *request.Amount
+	}
+
+	if request.Status != nil {
+		transaction.Status = domain.TransactionStatus(strings.ToUpper(strings.TrimSpace(*request.Status)))
+	}
+
+	if request.Description != nil {
+		transaction.Description = strings.TrimSpace(*request.Description)
+	}
+
+	updated, err := s.repo.Update(transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toTransactionDTO(*updated)
+	return &response, nil
+}
+
+func (s *transactionService) DeleteTransaction(id string, userID string) error {
+	if _, err := s.findOwned(id, userID); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(id)
+}
+
+// findOwned looks up one of the user's transactions. Someone else's is
+// reported as not found, so IDs can't be probed across accounts.
+func (s *transactionService) findOwned(id string, userID string) (*domain.Transaction, error) {
+	transaction, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction.UserID != userID {
+		return nil, domain.ErrTransactionNotFound
+	}
+
+	return transaction, nil
+}
+
+func toTransactionDTO(tx domain.Transaction) dto_transaction.TransactionDTO {
+	return dto_transaction.TransactionDTO{
+		ID:          tx.ID,
+		Timestamp:   tx.Timestamp.Format(time.RFC3339),
+		Name:        tx.Name,
+		Type:        string(tx.Type),
+		Amount:      tx.Amount,
+		Status:      string(tx.Status),
+		Description: tx.Description,
+		UploadID:    tx.BatchID,
+		ExternalID:  tx.ExternalID,
+		Account:     tx.Account,
+	}
 }
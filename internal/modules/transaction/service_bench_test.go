@@ -0,0 +1,92 @@
+package transaction
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"firstpersoncode/go-uploader/internal/repositories/repotest"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const (
+	benchUserID     = "bench-user"
+	benchUserRows   = 100
+	benchOtherUsers = 100
+)
+
+// benchTotals are the total row counts the benchmarks run at. The measured
+// user always owns benchUserRows of them, so the timings should stay flat.
+var benchTotals = []int{1_000, 10_000, 100_000}
+
+func setupBenchService(b *testing.B, totalRows int) domain.TransactionService {
+	b.Helper()
+
+	storage := repotest.Open(b)
+	repo := storage.Transactions
+	statuses := []domain.TransactionStatus{domain.TransactionStatusSuccess, domain.TransactionStatusFailed, domain.TransactionStatusPending}
+
+	generate := func(userID string, count int) []domain.Transaction {
+		transactions := make([]domain.Transaction, 0, count)
+		for i := 0; i < count; i++ {
+			transactions = append(transactions, domain.Transaction{
+				Timestamp:   time.Unix(int64(1_600_000_000+i), 0),
+				Name:        fmt.Sprintf("MERCHANT %d", i%17),
+				Type:        domain.TransactionTypeDebit,
+				Amount:      int64(1000 + i),
+				Status:      statuses[i%len(statuses)],
+				Description: "benchmark",
+				UserID:      userID,
+			})
+		}
+		return transactions
+	}
+
+	if err := repo.SaveAll(generate(benchUserID, benchUserRows)); err != nil {
+		b.Fatalf("failed to seed transactions: %v", err)
+	}
+
+	otherRows := (totalRows - benchUserRows) / benchOtherUsers
+	for user := 0; user < benchOtherUsers; user++ {
+		if err := repo.SaveAll(generate(fmt.Sprintf("other-%d", user), otherRows)); err != nil {
+			b.Fatalf("failed to seed transactions: %v", err)
+		}
+	}
+
+	return NewTransactionService(repo, storage.Uploads, storage.Profiles, util.NewCursorSigner("secret"))
+}
+
+func BenchmarkCalculateBalance(b *testing.B) {
+	for _, total := range benchTotals {
+		b.Run(fmt.Sprintf("rows=%d", total), func(b *testing.B) {
+			service := setupBenchService(b, total)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := service.CalculateBalance(benchUserID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetIssues(b *testing.B) {
+	pagination := dto_transaction.PaginationDTO{Page: 2, Limit: 10}
+	sorting := dto_transaction.SortingDTO{Sort: dto_transaction.SortDesc, SortBy: "timestamp"}
+
+	for _, total := range benchTotals {
+		b.Run(fmt.Sprintf("rows=%d", total), func(b *testing.B) {
+			service := setupBenchService(b, total)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := service.GetIssues(pagination, sorting, benchUserID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,28 +1,43 @@
 package transaction
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
+	"unicode/utf16"
 
 	"firstpersoncode/go-uploader/domain"
 	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
-	"firstpersoncode/go-uploader/internal/repositories"
+	"firstpersoncode/go-uploader/internal/repositories/repotest"
+	"firstpersoncode/go-uploader/internal/util"
 )
 
-func setupTestService() (domain.TransactionRepository, domain.TransactionService, string) {
-	repo := repositories.NewTransactionRepository()
-	service := NewTransactionService(repo)
+var testUpload = dto_transaction.UploadRequestDTO{Filename: "statement.csv"}
+
+func setupTestService(t *testing.T) (domain.TransactionRepository, domain.TransactionService, string) {
+	t.Helper()
+
+	storage := repotest.Open(t)
+	repo := storage.Transactions
+	service := NewTransactionService(repo, storage.Uploads, storage.Profiles, util.NewCursorSigner("secret"))
 	userID := "tester"
 	return repo, service, userID
 }
 
-func TestParseAndStoreCSV_Success(t *testing.T) {
-	repo, service, userID := setupTestService()
+func TestParseAndStoreStatement_Success(t *testing.T) {
+	repo, service, userID := setupTestService(t)
 
 	csvData := `1624507883, JOHN DOE, DEBIT, 250000, SUCCESS, restaurant
 1624608050, E-COMMERCE A, DEBIT, 150000, FAILED, clothes`
 
-	response, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
@@ -32,66 +47,1020 @@ func TestParseAndStoreCSV_Success(t *testing.T) {
 		t.Errorf("Expected 2 transactions, got %d", response.TotalRows)
 	}
 
-	transactions := repo.GetAll()
+	transactions, _ := repo.GetAll()
 	if len(transactions) != 2 {
 		t.Errorf("Expected 2 transactions in repo, got %d", len(transactions))
 	}
 }
 
-func TestParseAndStoreCSV_InvalidFormat(t *testing.T) {
-	repo, service, userID := setupTestService()
+func TestParseAndStoreStatement_InvalidFormat(t *testing.T) {
+	repo, service, userID := setupTestService(t)
 	defer repo.Clear()
 
 	// CSV with only 5 fields instead of 6
 	csvData := `1624507883, JOHN DOE, DEBIT, 250000, SUCCESS`
 
-	// This should panic because the service doesn't validate field count
-	// We're testing that it fails (either panic or error)
-	defer func() {
-		if r := recover(); r == nil {
-			t.Fatal("Expected panic for invalid format with 5 fields")
-		}
-	}()
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+
+	if !errors.Is(err, domain.ErrInvalidRows) {
+		t.Fatalf("Expected an invalid rows error, got %v", err)
+	}
 
-	service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	expected := []dto_transaction.RowErrorDTO{{Line: 1, Reason: "expected 6 fields, got 5"}}
+	if !reflect.DeepEqual(response.Errors, expected) || response.FailedRows != 1 || response.UploadStatus != "failed" {
+		t.Errorf("Expected the short row to be reported, got %+v", response)
+	}
 }
 
-func TestParseAndStoreCSV_InvalidType(t *testing.T) {
-	repo, service, userID := setupTestService()
+func TestParseAndStoreStatement_InvalidType(t *testing.T) {
+	repo, service, userID := setupTestService(t)
 	defer repo.Clear()
 
 	csvData := `1624507883, JOHN DOE, INVALID, 250000, SUCCESS, restaurant`
 
-	response, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 
 	if err == nil {
 		t.Fatal("Expected error for invalid type")
 	}
 
-	if response != nil {
-		t.Error("Expected nil response on error")
+	expected := []dto_transaction.RowErrorDTO{{Line: 1, Column: 3, Field: "type", Reason: `invalid type "INVALID", expected DEBIT or CREDIT`}}
+	if response == nil || !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Expected the invalid type to be reported, got %+v", response)
+	}
+
+	if stored, _ := repo.GetAll(); len(stored) != 0 {
+		t.Errorf("Expected nothing to be stored, got %d transactions", len(stored))
+	}
+}
+
+func TestParseAndStoreStatement_OnError(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	csvData := `1624507883, TX1, CREDIT, 500000, SUCCESS, salary
+1624608050, TX2, DEBIT, 12abc, PENDING
+1624608050, TX3, debit, 100, success, lowercase is fine
+yesterday, TX4, DEBIT, -5, SUCCESS,
+1624708050, "TX5, DEBIT, 1, SUCCESS, unterminated quote`
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if !errors.Is(err, domain.ErrInvalidRows) {
+		t.Fatalf("Expected rejecting to be the default, got %v", err)
+	}
+
+	if stored, _ := repo.GetAll(); len(stored) != 0 || response.TotalRows != 5 || response.FailedRows != 3 {
+		t.Errorf("Expected nothing stored and 3 of 5 rows failed, got %d stored and %+v", len(stored), response)
+	}
+
+	skip := dto_transaction.UploadRequestDTO{OnError: dto_transaction.OnErrorSkip}
+	response, err = service.ParseAndStoreStatement(strings.NewReader(csvData), skip, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if stored, _ := repo.GetAll(); len(stored) != 2 || stored[1].Type != domain.TransactionTypeDebit || stored[1].Status != domain.TransactionStatusSuccess {
+		t.Errorf("Expected the 2 valid rows to be stored, got %+v", stored)
+	}
+
+	lines := make([]int, 0)
+	fields := make([]string, 0)
+	for _, rowError := range response.Errors {
+		lines = append(lines, rowError.Line)
+		fields = append(fields, rowError.Field)
+	}
+
+	// Line 4 has three bad fields, line 5 a quote that runs to the end
+	if !slices.Equal(lines, []int{2, 4, 4, 4, 5}) || !slices.Equal(fields, []string{"", "timestamp", "amount", "description", ""}) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	if response.TotalRows != 5 || response.FailedRows != 3 || response.UploadStatus != "success" {
+		t.Errorf("Expected 3 of 5 rows to be skipped as invalid, got %+v", response)
+	}
+
+	upload, _ := service.GetUpload(response.UploadID, userID)
+	if upload.RowCount != 2 || upload.FailedRows != 3 {
+		t.Errorf("Expected the upload to record its failed rows, got %+v", upload)
+	}
+
+	// Without a single valid row there is nothing to import
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(`1,x`), skip, userID); !errors.Is(err, domain.ErrInvalidRows) {
+		t.Errorf("Expected a file without valid rows to fail, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_EmptyFile(t *testing.T) {
+	_, service, userID := setupTestService(t)
+
+	_, err := service.ParseAndStoreStatement(strings.NewReader(""), testUpload, userID)
+
+	if err == nil {
+		t.Fatal("Expected error for empty file")
+	}
+}
+
+func TestParseAndStoreStatement_Header(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	// A header naming every field may put the columns in any order
+	csvData := `Name,Amount,Type,Timestamp,Description,Status
+JOHN DOE,250000,DEBIT,1624507883,restaurant,SUCCESS`
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 1 || stored[0].Name != "JOHN DOE" || stored[0].Amount != 250000 || stored[0].Timestamp.Unix() != 1624507883 {
+		t.Errorf("Expected the row to be read by its header, got %+v", stored)
+	}
+
+	// Any other header is left out, and rows keep the fixed columns
+	csvData = `When,Who,What,How Much,State,Why
+1624608050, E-COMMERCE A, DEBIT, 150000, FAILED, clothes`
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if err != nil || response.TotalRows != 1 || response.FailedRows != 0 {
+		t.Errorf("Expected the header not to count as a row, got %+v, %v", response, err)
+	}
+
+	// A header narrower than the fixed columns can't be read
+	csvData = `Date,Amount,Memo
+1700000000,5,x`
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID); err == nil {
+		t.Error("Expected an error for a header without the fixed columns")
+	}
+}
+
+func TestImportProfiles(t *testing.T) {
+	_, service, userID := setupTestService(t)
+
+	request := dto_transaction.ImportProfileRequestDTO{
+		Name:     "Bank",
+		Columns:  map[string]string{"timestamp": "Date", "name": "Payee", "type": "Type", "amount": "Amount", "description": "Memo"},
+		Defaults: map[string]string{"status": "SUCCESS"},
+	}
+
+	created, err := service.CreateImportProfile(request, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := service.CreateImportProfile(request, userID); !errors.Is(err, domain.ErrImportProfileNameTaken) {
+		t.Errorf("Expected a second profile named Bank to be refused, got %v", err)
+	}
+
+	invalid := []struct {
+		label  string
+		change func(*dto_transaction.ImportProfileRequestDTO)
+	}{
+		{"no name", func(r *dto_transaction.ImportProfileRequestDTO) { r.Name = " " }},
+		{"unknown field", func(r *dto_transaction.ImportProfileRequestDTO) { r.Columns = map[string]string{"balance": "1"} }},
+		{"unmapped field", func(r *dto_transaction.ImportProfileRequestDTO) { r.Defaults = nil }},
+		{"column zero", func(r *dto_transaction.ImportProfileRequestDTO) { r.Columns = map[string]string{"status": "0"} }},
+		{"bad default", func(r *dto_transaction.ImportProfileRequestDTO) { r.Defaults = map[string]string{"status": "DONE"} }},
+		{"long delimiter", func(r *dto_transaction.ImportProfileRequestDTO) { r.Delimiter = ";;" }},
+		{"same delimiter and quote", func(r *dto_transaction.ImportProfileRequestDTO) { r.Delimiter, r.Quote = "'", "'" }},
+		{"negative skip", func(r *dto_transaction.ImportProfileRequestDTO) { r.SkipRows = -1 }},
+	}
+
+	for _, tc := range invalid {
+		changed := request
+		changed.Name = "Other"
+		tc.change(&changed)
+
+		if _, err := service.CreateImportProfile(changed, userID); err == nil {
+			t.Errorf("%s: expected the profile to be refused", tc.label)
+		}
+	}
+
+	request.Delimiter = ";"
+	updated, err := service.UpdateImportProfile(created.ID, request, userID)
+	if err != nil || updated.Delimiter != ";" || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected the profile to be replaced, got %+v, %v", updated, err)
+	}
+
+	if _, err := service.GetImportProfile(created.ID, "someone-else"); !errors.Is(err, domain.ErrImportProfileNotFound) {
+		t.Errorf("Expected another user's profile to be hidden, got %v", err)
+	}
+
+	if err := service.DeleteImportProfile(created.ID, "someone-else"); !errors.Is(err, domain.ErrImportProfileNotFound) {
+		t.Errorf("Expected another user not to delete the profile, got %v", err)
+	}
+
+	if profiles, _ := service.ListImportProfiles(userID); len(profiles) != 1 || profiles[0].ID != created.ID {
+		t.Errorf("Expected the one profile to be listed, got %+v", profiles)
+	}
+
+	if err := service.DeleteImportProfile(created.ID, userID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := service.GetImportProfile(created.ID, userID); !errors.Is(err, domain.ErrImportProfileNotFound) {
+		t.Errorf("Expected the profile to be gone, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_Profile(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	profile, err := service.CreateImportProfile(dto_transaction.ImportProfileRequestDTO{
+		Name:      "Bank",
+		Delimiter: ";",
+		Quote:     "'",
+		SkipRows:  2,
+		Columns:   map[string]string{"timestamp": "Date", "name": "Payee", "type": "Type", "amount": "Amount", "status": "State", "description": "Memo"},
+		Defaults:  map[string]string{"status": "SUCCESS", "description": "imported"},
+	}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	csvData := `Account statement
+Account 12345; "savings"
+Memo;Amount;Payee;Date;Type;State
+'Rent; May';1500;'Landlord "Bob"';1624507883;DEBIT;
+;25;'It''s a café';1624608050;CREDIT;PENDING
+lunch;abc;Diner;1624608051;DEBIT;`
+
+	upload := dto_transaction.UploadRequestDTO{Profile: profile.ID, OnError: dto_transaction.OnErrorSkip}
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{{Line: 6, Column: 2, Field: "amount", Reason: `invalid amount "abc", expected a non-negative integer`}}
+	if response.TotalRows != 3 || !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Expected the bad amount to be reported at its line and column, got %+v", response)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(stored))
+	}
+
+	if stored[0].Name != `Landlord "Bob"` || stored[0].Description != "Rent; May" || stored[0].Status != domain.TransactionStatusSuccess {
+		t.Errorf("Expected the quoted fields and the status default, got %+v", stored[0])
+	}
+
+	if stored[1].Name != "It's a café" || stored[1].Description != "imported" || stored[1].Status != domain.TransactionStatusPending {
+		t.Errorf("Expected the doubled quote and the description default, got %+v", stored[1])
+	}
+
+	// Profiles can be picked by name too, and columns by number
+	service.CreateImportProfile(dto_transaction.ImportProfileRequestDTO{
+		Name:     "Numbered",
+		Columns:  map[string]string{"timestamp": "3", "name": "1", "amount": "2"},
+		Defaults: map[string]string{"type": "DEBIT", "status": "FAILED", "description": "card"},
+	}, userID)
+
+	upload = dto_transaction.UploadRequestDTO{Profile: "numbered"}
+	if response, err := service.ParseAndStoreStatement(strings.NewReader("Shop,42,1624700000,extra\n"), upload, userID); err != nil || response.TotalRows != 1 {
+		t.Errorf("Expected the numbered profile to read the row, got %+v, %v", response, err)
+	}
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader("Shop,42\n"), upload, userID); !errors.Is(err, domain.ErrInvalidRows) {
+		t.Errorf("Expected a row short of the last column to fail, got %v", err)
+	}
+
+	// A header missing a mapped column fails the whole file
+	upload = dto_transaction.UploadRequestDTO{Profile: profile.ID}
+	if _, err := service.ParseAndStoreStatement(strings.NewReader("a\nb\nMemo;Amount\n"), upload, userID); err == nil || !strings.Contains(err.Error(), "not found in the header row") {
+		t.Errorf("Expected the missing column to be reported, got %v", err)
+	}
+
+	upload = dto_transaction.UploadRequestDTO{Profile: "missing"}
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(csvData), upload, userID); !errors.Is(err, domain.ErrImportProfileNotFound) {
+		t.Errorf("Expected an unknown profile to be refused, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_ValueFormats(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	create := func(name string, request dto_transaction.ImportProfileRequestDTO) string {
+		t.Helper()
+
+		request.Name = name
+		request.Columns = map[string]string{"timestamp": "Date", "name": "Payee", "amount": "Amount"}
+		request.Defaults = map[string]string{"status": "SUCCESS", "description": "imported"}
+
+		profile, err := service.CreateImportProfile(request, userID)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return profile.ID
+	}
+
+	european := create("European", dto_transaction.ImportProfileRequestDTO{
+		Delimiter:          ";",
+		DateLayout:         "02/01/2006 15:04",
+		TimeZone:           "Europe/Berlin",
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		DecimalPlaces:      2,
+	})
+
+	csvData := `Date;Payee;Amount
+01/03/2024 14:22;Salary;1.234,56
+02/03/2024 09:00;Grocer;(12,00)
+03/03/2024 18:30;Cafe;-45,10
+04/03/2024 08:00;Broken;12,345
+2024-03-05;Broken;1,00
+06/03/2024 08:00;Broken;45.10
+07/03/2024 08:00;Broken;1.2.3`
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), dto_transaction.UploadRequestDTO{Profile: european, OnError: dto_transaction.OnErrorSkip}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{
+		{Line: 5, Column: 3, Field: "amount", Reason: `invalid amount "12,345", expected an amount like 1.234,00 or -1.234,00`},
+		{Line: 6, Column: 1, Field: "timestamp", Reason: `invalid timestamp "2024-03-05", expected a date like 02/01/2006 15:04`},
+		{Line: 7, Column: 3, Field: "amount", Reason: `invalid amount "45.10", expected an amount like 1.234,00 or -1.234,00`},
+		{Line: 8, Column: 3, Field: "amount", Reason: `invalid amount "1.2.3", expected an amount like 1.234,00 or -1.234,00`},
+	}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(stored))
+	}
+
+	// Berlin is an hour ahead of UTC in March
+	if stored[0].Timestamp.Unix() != time.Date(2024, 3, 1, 13, 22, 0, 0, time.UTC).Unix() {
+		t.Errorf("Expected the time to be read in Berlin time, got %v", stored[0].Timestamp.UTC())
+	}
+
+	amounts := []int64{stored[0].Amount, stored[1].Amount, stored[2].Amount}
+	types := []domain.TransactionType{stored[0].Type, stored[1].Type, stored[2].Type}
+	if !slices.Equal(amounts, []int64{123456, 1200, 4510}) ||
+		!slices.Equal(types, []domain.TransactionType{domain.TransactionTypeCredit, domain.TransactionTypeDebit, domain.TransactionTypeDebit}) {
+		t.Errorf("Expected positive amounts as credits and negative ones as debits, got %v %v", amounts, types)
+	}
+	repo.Clear()
+
+	// A card statement lists purchases as positive amounts
+	card := create("Card", dto_transaction.ImportProfileRequestDTO{SignConvention: domain.SignConventionNegativeCredit})
+
+	csvData = `Date,Payee,Amount
+2024-03-01T10:00:00+02:00,Shop,25
+2024-03-02,Refund,-5`
+
+	response, err = service.ParseAndStoreStatement(strings.NewReader(csvData), dto_transaction.UploadRequestDTO{Profile: card, OnError: dto_transaction.OnErrorSkip}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ = repo.GetAll()
+	if len(stored) != 1 || stored[0].Type != domain.TransactionTypeDebit || stored[0].Timestamp.Unix() != time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("Expected the RFC 3339 purchase as a debit, got %+v", stored)
+	}
+
+	if len(response.Errors) != 1 || response.Errors[0].Reason != `invalid timestamp "2024-03-02", expected Unix seconds or RFC 3339` {
+		t.Errorf("Expected a date without a layout to be refused, got %+v", response.Errors)
+	}
+
+	invalid := []dto_transaction.ImportProfileRequestDTO{
+		{DateLayout: "15:04"},
+		{TimeZone: "Mars/Olympus"},
+		{DecimalSeparator: ",", ThousandsSeparator: ","},
+		{DecimalSeparator: "-"},
+		{DecimalPlaces: 7},
+		{SignConvention: "backwards"},
+	}
+
+	for _, request := range invalid {
+		request.Name = "Invalid"
+		request.Columns = map[string]string{"timestamp": "1", "name": "2", "amount": "3"}
+		request.Defaults = map[string]string{"status": "SUCCESS", "description": "imported"}
+
+		if _, err := service.CreateImportProfile(request, userID); err == nil {
+			t.Errorf("Expected %+v to be refused", request)
+		}
+	}
+
+	// With a type column the amount must not be signed
+	request := dto_transaction.ImportProfileRequestDTO{
+		Name:           "Typed",
+		Columns:        map[string]string{"timestamp": "1", "name": "2", "type": "3", "amount": "4"},
+		Defaults:       map[string]string{"status": "SUCCESS", "description": "imported"},
+		SignConvention: domain.SignConventionNegativeDebit,
+	}
+	if _, err := service.CreateImportProfile(request, userID); err == nil {
+		t.Error("Expected a type column to be refused with a signed amount")
+	}
+}
+
+func TestParseAndStoreStatement_Encoding(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	utf16LE := func(text string) string {
+		encoded := []byte{0xFF, 0xFE}
+		for _, unit := range utf16.Encode([]rune(text)) {
+			encoded = binary.LittleEndian.AppendUint16(encoded, unit)
+		}
+		return string(encoded)
+	}
+
+	row := "1624507883,%s,CREDIT,100,SUCCESS,imported\n"
+
+	tests := []struct {
+		name     string
+		content  string
+		encoding string
+		expected string
+	}{
+		{"UTF-8 with a BOM before the header", "\xEF\xBB\xBFtimestamp,name,type,amount,status,description\n" + fmt.Sprintf(row, "Café"), "", "Café"},
+		{"Windows-1252 detected", fmt.Sprintf(row, "Caf\xE9 \x80"), "", "Café €"},
+		{"ISO-8859-1 given", fmt.Sprintf(row, "M\xFCller"), "ISO-8859-1", "Müller"},
+		{"UTF-16 with a BOM", utf16LE(fmt.Sprintf(row, "Zoë 🙂")), "", "Zoë 🙂"},
+		{"UTF-16 given", utf16LE(fmt.Sprintf(row, "Łódź")), "utf-16", "Łódź"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.Clear()
+
+			upload := dto_transaction.UploadRequestDTO{Filename: tt.name, Encoding: tt.encoding}
+			if _, err := service.ParseAndStoreStatement(strings.NewReader(tt.content), upload, userID); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			stored, _ := repo.GetAll()
+			if len(stored) != 1 || stored[0].Name != tt.expected {
+				t.Errorf("Expected the name %q, got %+v", tt.expected, stored)
+			}
+		})
+	}
+
+	// Bytes that aren't UTF-8 in a file said to be
+	csvData := fmt.Sprintf(row, "Alice") + fmt.Sprintf(row, "Caf\xE9")
+	upload := dto_transaction.UploadRequestDTO{Filename: "invalid.csv", Encoding: dto_transaction.EncodingUTF8, OnError: dto_transaction.OnErrorSkip}
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(csvData), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{{Line: 2, Field: "name", Reason: "field contains invalid text"}}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	// An unpaired surrogate, which decodes to U+FFFD
+	csvData = strings.Replace(utf16LE(fmt.Sprintf(row, "Alice")+fmt.Sprintf(row, "Zo?")), "?\x00", "\x00\xD8", 1)
+	upload = dto_transaction.UploadRequestDTO{Filename: "surrogate.csv", OnError: dto_transaction.OnErrorSkip}
+
+	response, err = service.ParseAndStoreStatement(strings.NewReader(csvData), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	// Other formats are held to the same
+	ofx := "<OFX>\n<STMTTRN>\n<TRNTYPE>DEBIT\n<DTPOSTED>20240301\n<TRNAMT>-12.50\n<FITID>1\n<NAME>Caf\xE9\n<MEMO>Coffee\n</STMTTRN>\n" +
+		"<STMTTRN>\n<TRNTYPE>DEBIT\n<DTPOSTED>20240302\n<TRNAMT>-1.00\n<FITID>2\n<NAME>Shop\n</STMTTRN>\n</OFX>\n"
+	upload = dto_transaction.UploadRequestDTO{Filename: "invalid.ofx", Encoding: dto_transaction.EncodingUTF8, OnError: dto_transaction.OnErrorSkip}
+
+	response, err = service.ParseAndStoreStatement(strings.NewReader(ofx), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+}
+
+func TestParseAndStoreStatement_OFX(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	sgml := `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+ENCODING:USASCII
+CHARSET:1252
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>121000248
+<ACCTID>12345
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240301
+<DTEND>20240331
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240301120000.000[-5:EST]
+<TRNAMT>-12.50
+<FITID>2024030101
+<NAME>Corner Shop
+<MEMO>Groceries &amp; milk
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20240302
+<TRNAMT>250.00
+<FITID>2024030201
+<MEMO>Transfer from savings
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>BARTER
+<DTPOSTED>2024-03-03
+<TRNAMT>1.00
+<FITID>2024030301
+<NAME>Broken
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(sgml), dto_transaction.UploadRequestDTO{Filename: "march.qfx", OnError: dto_transaction.OnErrorSkip}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{
+		{Line: 33, Field: "timestamp", Reason: `invalid DTPOSTED "2024-03-03", expected a date like 20240131 or 20240131093000[-5:EST]`},
+		{Line: 33, Field: "type", Reason: `invalid TRNTYPE "BARTER"`},
+	}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(stored))
+	}
+
+	shop := domain.Transaction{
+		ID: stored[0].ID, Timestamp: time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC), Name: "Corner Shop", Type: domain.TransactionTypeDebit,
+		Amount: 1250, Status: domain.TransactionStatusSuccess, Description: "Groceries & milk", UserID: userID,
+		BatchID: response.UploadID, ExternalID: "2024030101", Account: "12345",
+	}
+	stored[0].Timestamp = stored[0].Timestamp.UTC()
+	if !reflect.DeepEqual(stored[0], shop) {
+		t.Errorf("Expected %+v, got %+v", shop, stored[0])
+	}
+
+	if stored[1].Type != domain.TransactionTypeCredit || stored[1].Name != "Transfer from savings" || stored[1].Amount != 25000 {
+		t.Errorf("Expected a positive transfer to be a credit named by its memo, got %+v", stored[1])
+	}
+
+	// The bank's IDs recognise the same transactions in a 2.x export, even
+	// with the memo changed
+	xml := `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+    <CCACCTFROM><ACCTID>12345</ACCTID></CCACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN>
+        <TRNTYPE>DEBIT</TRNTYPE>
+        <DTPOSTED>20240301120000.000[-5:EST]</DTPOSTED>
+        <TRNAMT>-12.50</TRNAMT>
+        <FITID>2024030101</FITID>
+        <PAYEE><NAME>Corner Shop</NAME></PAYEE>
+        <MEMO>Groceries</MEMO>
+      </STMTTRN>
+      <STMTTRN>
+        <TRNTYPE>INT</TRNTYPE>
+        <DTPOSTED>20240331</DTPOSTED>
+        <TRNAMT>0.42</TRNAMT>
+        <FITID>2024033101</FITID>
+        <NAME>Interest</NAME>
+      </STMTTRN>
+    </BANKTRANLIST>
+  </CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>`
+
+	response, err = service.ParseAndStoreStatement(strings.NewReader(xml), dto_transaction.UploadRequestDTO{Filename: "march.ofx"}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.TotalRows != 2 || response.SkippedRows != 1 {
+		t.Errorf("Expected 1 of 2 rows to be skipped, got %+v", response)
+	}
+
+	stored, _ = repo.GetAll()
+	if len(stored) != 3 || stored[2].Name != "Interest" || stored[2].Description != "Interest" || stored[2].Amount != 42 {
+		t.Errorf("Expected the interest to be added, got %+v", stored)
+	}
+
+	// A bank's IDs are only unique within an account, and a transfer's own
+	// ACCTID is the other account's
+	other := `<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<BANKACCTFROM><ACCTID>67890</ACCTID></BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20240301
+<TRNAMT>-12.50
+<FITID>2024030101
+<NAME>To checking
+<BANKACCTTO><ACCTID>12345</ACCTID></BANKACCTTO>
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+
+	response, err = service.ParseAndStoreStatement(strings.NewReader(other), dto_transaction.UploadRequestDTO{Filename: "savings.ofx"}, userID)
+	if err != nil || response.SkippedRows != 0 {
+		t.Errorf("Expected the same FITID in another account to be stored, got %+v, %v", response, err)
+	}
+
+	stored, _ = repo.GetAll()
+	if len(stored) != 4 || stored[3].Account != "67890" {
+		t.Errorf("Expected the transfer to be stored for its statement's account, got %+v", stored)
+	}
+
+	if _, err := service.CreateImportProfile(dto_transaction.ImportProfileRequestDTO{Name: "Bank", Delimiter: ";"}, userID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = service.ParseAndStoreStatement(strings.NewReader(xml), dto_transaction.UploadRequestDTO{Profile: "Bank"}, userID)
+	if !errors.Is(err, domain.ErrInvalidStatement) || err.Error() != "invalid statement: import profiles only apply to CSV files" {
+		t.Errorf("Expected an import profile to be refused for OFX, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_OFXSignContradictsType(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	ofx := `<OFX>
+<STMTTRN>
+<TRNTYPE>POS
+<DTPOSTED>20240301
+<TRNAMT>12.50
+<FITID>1
+<NAME>Corner Shop
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240302
+<TRNAMT>+3.00
+<FITID>2
+<NAME>Bakery
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240303
+<TRNAMT>-250.00
+<FITID>3
+<NAME>Salary
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240304
+<TRNAMT>5.00
+<FITID>4
+<NAME>Refund
+</STMTTRN>
+</OFX>`
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(ofx), dto_transaction.UploadRequestDTO{Filename: "march.ofx", OnError: dto_transaction.OnErrorSkip}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{
+		{Line: 2, Field: "amount", Reason: `TRNAMT "12.50" contradicts TRNTYPE "POS"`},
+		{Line: 9, Field: "amount", Reason: `TRNAMT "+3.00" contradicts TRNTYPE "DEBIT"`},
+		{Line: 16, Field: "amount", Reason: `TRNAMT "-250.00" contradicts TRNTYPE "CREDIT"`},
+	}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 1 || stored[0].Name != "Refund" || stored[0].Type != domain.TransactionTypeCredit {
+		t.Errorf("Expected only the credit with a positive amount to be stored, got %+v", stored)
+	}
+}
+
+func TestParseAndStoreStatement_MT940(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	mt940 := `{1:F01BANKDEFFXXXX0000000000}{2:I940BANKDEFFXXXXN}{4:
+:20:STMT240301
+:25:DE89370400440532013000
+:28C:00001/001
+:60F:C240229EUR1000,00
+:61:2403010301D12,50NTRFNONREF//B24030100001
+:86:166?00SEPA-UEBERWEISUNG?20Invoice 42 for?21 office supplies?32Paper & Co?33 GmbH
+:61:240302C250,NTRFREF123//B24030200001
+Salary March
+:86:/TRTP/SEPA CREDIT TRANSFER/NAME/Acme Corp/REMI/Salary March/EREF/NOTPROVIDED
+:61:240303D5,00NMSCNONREF
+:86:Card fee
+March
+:61:2403XXD1,00NMSC
+:62F:C240303EUR1232,50
+-}`
+
+	upload := dto_transaction.UploadRequestDTO{Filename: "march.sta", OnError: dto_transaction.OnErrorSkip}
+	response, err := service.ParseAndStoreStatement(strings.NewReader(mt940), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{{Line: 14, Reason: `invalid :61: statement line "2403XXD1,00NMSC"`}}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	opening, closing := int64(100000), int64(123250)
+	balances := []dto_transaction.StatementBalanceDTO{{Account: "DE89370400440532013000", OpeningBalance: &opening, ClosingBalance: &closing, Booked: 23250}}
+	if !reflect.DeepEqual(response.Balances, balances) {
+		t.Errorf("Expected the stated balances to add up, got %+v", response.Balances)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(stored))
+	}
+
+	type summary struct {
+		Name, Description, ExternalID string
+		Type                          domain.TransactionType
+		Amount                        int64
+		Status                        domain.TransactionStatus
+	}
+	var got []summary
+	for _, tx := range stored {
+		got = append(got, summary{tx.Name, tx.Description, tx.ExternalID, tx.Type, tx.Amount, tx.Status})
+	}
+
+	want := []summary{
+		{"Paper & Co GmbH", "Invoice 42 for office supplies", "B24030100001", domain.TransactionTypeDebit, 1250, domain.TransactionStatusSuccess},
+		{"Acme Corp", "Salary March", "B24030200001", domain.TransactionTypeCredit, 25000, domain.TransactionStatusSuccess},
+		{"Card fee", "Card fee March", "", domain.TransactionTypeDebit, 500, domain.TransactionStatusSuccess},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if !stored[0].Timestamp.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the value date, got %v", stored[0].Timestamp)
+	}
+
+	if stored[0].Account != "DE89370400440532013000" {
+		t.Errorf("Expected the :25: account, got %q", stored[0].Account)
+	}
+
+	// The bank's references are only unique within an account
+	savings := `:20:STMT240301
+:25:DE02120300000000202051
+:60F:C240229EUR100,00
+:61:2403010301D12,50NTRFNONREF//B24030100001
+:86:Transfer
+:62F:C240301EUR90,00`
+
+	reject := dto_transaction.UploadRequestDTO{OnDuplicate: dto_transaction.OnDuplicateReject}
+	response, err = service.ParseAndStoreStatement(strings.NewReader(savings), reject, userID)
+	if err != nil {
+		t.Fatalf("Expected the same reference in another account not to be a duplicate, got %v", err)
+	}
+
+	// 100,00 less 12,50 isn't 90,00
+	if len(response.Balances) != 1 || response.Balances[0].Account != "DE02120300000000202051" || response.Balances[0].Booked != -1250 || !response.Balances[0].Mismatch {
+		t.Errorf("Expected the balances not to add up, got %+v", response.Balances)
+	}
+	// An MT942 interim report lists pending entries, without balances
+	mt942 := `:20:INTRADAY
+:25:DE89370400440532013000
+:28C:00002/001
+:34F:EUR0,
+:13D:2403041200+0100
+:61:240304D20,00NTRFNONREF//B24030400001
+:86:Pending card payment`
+
+	response, err = service.ParseAndStoreStatement(strings.NewReader(mt942), dto_transaction.UploadRequestDTO{}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Balances != nil {
+		t.Errorf("Expected no balances, got %+v", response.Balances)
+	}
+
+	stored, _ = repo.GetAll()
+	if len(stored) != 5 || stored[4].Status != domain.TransactionStatusPending || stored[4].ExternalID != "" {
+		t.Errorf("Expected a pending entry without an external ID, got %+v", stored)
+	}
+}
+
+func TestParseAndStoreStatement_InvalidStatement(t *testing.T) {
+	_, service, userID := setupTestService(t)
+
+	for name, text := range map[string]string{
+		"empty CSV":         "timestamp,name,type,amount,status,description\n",
+		"OFX without OFX":   "OFXHEADER:100\n<BANKTRANLIST></BANKTRANLIST>",
+		"malformed camt":    "<Document><BkToCstmrStmt><Stmt></BkToCstmrStmt>",
+		"bad MT940 balance": ":20:STMT\n:60F:X\n:61:2403010301D12,50NTRF\n:86:Fee",
+	} {
+		if _, err := service.ParseAndStoreStatement(strings.NewReader(text), testUpload, userID); !errors.Is(err, domain.ErrInvalidStatement) {
+			t.Errorf("%s: expected an invalid statement, got %v", name, err)
+		}
+	}
+
+	// A file that can't be read isn't the uploader's fault
+	failure := errors.New("disk failure")
+	if _, err := service.ParseAndStoreStatement(iotest.ErrReader(failure), testUpload, userID); !errors.Is(err, failure) || errors.Is(err, domain.ErrInvalidStatement) {
+		t.Errorf("Expected the read failure, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_Camt(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	camt := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>STMT-1</MsgId></GrpHdr>
+    <Stmt>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+      <Bal><Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp><Amt Ccy="EUR">50.00</Amt><CdtDbtInd>DBIT</CdtDbtInd></Bal>
+      <Bal><Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp><Amt Ccy="EUR">950.00</Amt><CdtDbtInd>CRDT</CdtDbtInd></Bal>
+      <Ntry>
+        <Amt Ccy="EUR">1000.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-03-01</Dt></BookgDt>
+        <AcctSvcrRef>REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Nm>Acme Corp</Nm></Dbtr></RltdPties>
+          <RmtInf><Ustrd>Salary</Ustrd><Ustrd>March</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">30.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>PDNG</Cd></Sts>
+        <BookgDt><DtTm>2024-03-02T10:00:00+01:00</DtTm></BookgDt>
+        <AcctSvcrRef>REF-2</AcctSvcrRef>
+        <NtryDtls><TxDtls><RltdPties><Cdtr><Pty><Nm>Grocer</Nm></Pty></Cdtr></RltdPties></TxDtls></NtryDtls>
+        <AddtlNtryInf>Card payment</AddtlNtryInf>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">1.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>INFO</Sts>
+        <BookgDt><Dt>2024-03-03</Dt></BookgDt>
+        <AddtlNtryInf>Fee</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+	upload := dto_transaction.UploadRequestDTO{Filename: "march.xml", OnError: dto_transaction.OnErrorSkip}
+	response, err := service.ParseAndStoreStatement(strings.NewReader(camt), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{{Line: 29, Field: "status", Reason: `invalid Sts "INFO", expected BOOK or PDNG`}}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	// Only the booked entry counts towards the balance
+	opening, closing := int64(-5000), int64(95000)
+	balances := []dto_transaction.StatementBalanceDTO{{Account: "DE89370400440532013000", OpeningBalance: &opening, ClosingBalance: &closing, Booked: 100000}}
+	if !reflect.DeepEqual(response.Balances, balances) {
+		t.Errorf("Expected the stated balances to add up, got %+v", response.Balances)
+	}
+
+	stored, _ := repo.GetAll()
+	if len(stored) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(stored))
+	}
+
+	booked, pending := stored[0], stored[1]
+	if booked.Name != "Acme Corp" || booked.Description != "Salary March" || booked.Type != domain.TransactionTypeCredit ||
+		booked.Amount != 100000 || booked.Status != domain.TransactionStatusSuccess || booked.ExternalID != "REF-1" ||
+		booked.Account != "DE89370400440532013000" {
+		t.Errorf("Unexpected booked entry %+v", booked)
+	}
+
+	if pending.Name != "Grocer" || pending.Description != "Card payment" || pending.Type != domain.TransactionTypeDebit ||
+		pending.Status != domain.TransactionStatusPending || pending.ExternalID != "" || pending.Timestamp.Unix() != time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("Unexpected pending entry %+v", pending)
+	}
+
+	upload.OnDuplicate = dto_transaction.OnDuplicateReject
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(camt), upload, userID); !errors.Is(err, domain.ErrDuplicateUpload) {
+		t.Errorf("Expected the statement to be a duplicate, got %v", err)
+	}
+
+	// The same references in another account's statement are new
+	other := strings.Replace(camt, "<IBAN>DE89370400440532013000</IBAN>", "<Othr><Id>0532013001</Id></Othr>", 1)
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(other), upload, userID); err != nil {
+		t.Errorf("Expected another account's entries not to be duplicates, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_CurrencyMinorUnits(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	// Yen have no decimal places
+	mt940 := `:20:STMT240301
+:25:JP12345
+:28C:00001/001
+:60F:C240229JPY100000,
+:61:2403010301D1500,NTRFNONREF//J1
+:86:Ramen
+:61:2403020302D12,50NTRFNONREF//J2
+:86:Broken
+:62F:C240302JPY98500,
+-`
+
+	upload := dto_transaction.UploadRequestDTO{Filename: "march.sta", OnError: dto_transaction.OnErrorSkip}
+	response, err := service.ParseAndStoreStatement(strings.NewReader(mt940), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []dto_transaction.RowErrorDTO{{Line: 7, Field: "amount", Reason: `invalid amount "12,50", expected a non-negative integer`}}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
+
+	opening, closing := int64(100000), int64(98500)
+	balances := []dto_transaction.StatementBalanceDTO{{Account: "JP12345", OpeningBalance: &opening, ClosingBalance: &closing, Booked: -1500}}
+	if !reflect.DeepEqual(response.Balances, balances) {
+		t.Errorf("Expected the balances in yen, got %+v", response.Balances)
+	}
+
+	// Kuwaiti dinars have three
+	camt := `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Acct><Id><IBAN>KW81CBKU0000000000001234560101</IBAN></Id></Acct>
+      <Bal><Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp><Amt Ccy="KWD">10.000</Amt><CdtDbtInd>CRDT</CdtDbtInd></Bal>
+      <Bal><Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp><Amt Ccy="KWD">8.750</Amt><CdtDbtInd>CRDT</CdtDbtInd></Bal>
+      <Ntry>
+        <Amt Ccy="KWD">1.250</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-03-01</Dt></BookgDt>
+        <AcctSvcrRef>K1</AcctSvcrRef>
+        <AddtlNtryInf>Bakery</AddtlNtryInf>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="JPY">5.5</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-03-02</Dt></BookgDt>
+        <AddtlNtryInf>Broken</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+	upload = dto_transaction.UploadRequestDTO{Filename: "march.xml", OnError: dto_transaction.OnErrorSkip}
+	response, err = service.ParseAndStoreStatement(strings.NewReader(camt), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
 	}
-}
 
-func TestParseAndStoreCSV_EmptyFile(t *testing.T) {
-	_, service, userID := setupTestService()
+	expected = []dto_transaction.RowErrorDTO{{Line: 15, Field: "amount", Reason: `invalid Amt "5.5", expected a non-negative integer`}}
+	if !reflect.DeepEqual(response.Errors, expected) {
+		t.Errorf("Unexpected row errors: %+v", response.Errors)
+	}
 
-	_, err := service.ParseAndStoreCSV(strings.NewReader(""), userID)
+	opening, closing = int64(10000), int64(8750)
+	balances = []dto_transaction.StatementBalanceDTO{{Account: "KW81CBKU0000000000001234560101", OpeningBalance: &opening, ClosingBalance: &closing, Booked: -1250}}
+	if !reflect.DeepEqual(response.Balances, balances) {
+		t.Errorf("Expected the balances in fils, got %+v", response.Balances)
+	}
 
-	if err == nil {
-		t.Fatal("Expected error for empty file")
+	stored, _ := repo.GetAll()
+	if len(stored) != 2 || stored[0].Amount != 1500 || stored[1].Amount != 1250 {
+		t.Errorf("Expected amounts in each currency's minor unit, got %+v", stored)
 	}
 }
 
 func TestCalculateBalance(t *testing.T) {
-	_, service, userID := setupTestService()
+	_, service, userID := setupTestService(t)
 
 	csvData := `1624507883, JOHN DOE, CREDIT, 500000, SUCCESS, salary
 1624608050, E-COMMERCE A, DEBIT, 150000, SUCCESS, clothes
 1624708050, SHOP B, DEBIT, 100000, FAILED, test
 1624808050, STORE C, CREDIT, 200000, SUCCESS, refund`
 
-	_, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -116,13 +1085,13 @@ func TestCalculateBalance(t *testing.T) {
 }
 
 func TestCalculateBalance_OnlySuccess(t *testing.T) {
-	_, service, userID := setupTestService()
+	_, service, userID := setupTestService(t)
 
 	csvData := `1624507883, JOHN DOE, CREDIT, 1000000, SUCCESS, salary
 1624608050, E-COMMERCE A, DEBIT, 200000, FAILED, clothes
 1624708050, SHOP B, DEBIT, 300000, PENDING, test`
 
-	_, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -147,14 +1116,14 @@ func TestCalculateBalance_OnlySuccess(t *testing.T) {
 }
 
 func TestGetIssues(t *testing.T) {
-	_, service, userID := setupTestService()
+	_, service, userID := setupTestService(t)
 
 	csvData := `1624507883, JOHN DOE, DEBIT, 250000, SUCCESS, restaurant
 1624608050, E-COMMERCE A, DEBIT, 150000, FAILED, clothes
 1624708050, SHOP B, CREDIT, 500000, PENDING, refund
 1624808050, STORE C, DEBIT, 100000, SUCCESS, food`
 
-	_, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -186,14 +1155,14 @@ func TestGetIssues(t *testing.T) {
 }
 
 func TestGetIssues_Pagination(t *testing.T) {
-	_, service, userID := setupTestService()
+	_, service, userID := setupTestService(t)
 
 	csvData := `1624507883, TX1, DEBIT, 100000, FAILED, test1
 1624608050, TX2, DEBIT, 200000, PENDING, test2
 1624708050, TX3, DEBIT, 300000, FAILED, test3
 1624808050, TX4, DEBIT, 400000, PENDING, test4`
 
-	_, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -228,13 +1197,13 @@ func TestGetIssues_Pagination(t *testing.T) {
 }
 
 func TestGetIssues_Sorting(t *testing.T) {
-	_, service, userID := setupTestService()
+	_, service, userID := setupTestService(t)
 
 	csvData := `1624708050, C-TX, DEBIT, 300000, FAILED, test
 1624508050, A-TX, DEBIT, 100000, PENDING, test
 1624608050, B-TX, DEBIT, 200000, FAILED, test`
 
-	_, err := service.ParseAndStoreCSV(strings.NewReader(csvData), userID)
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -256,3 +1225,493 @@ func TestGetIssues_Sorting(t *testing.T) {
 		t.Errorf("Expected last transaction amount 100000 (DESC), got %d", response.Transactions[2].Amount)
 	}
 }
+
+func TestGetIssues_Cursor(t *testing.T) {
+	_, service, userID := setupTestService(t)
+
+	csvData := `1624507883, TX1, DEBIT, 100000, FAILED, test1
+1624608050, TX2, DEBIT, 200000, PENDING, test2
+1624708050, TX3, DEBIT, 300000, FAILED, test3
+1624808050, TX4, DEBIT, 400000, PENDING, test4
+1624908050, TX5, DEBIT, 500000, FAILED, test5`
+
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	names := func(response *dto_transaction.IssuesResponseDTO) string {
+		var result []string
+		for _, tx := range response.Transactions {
+			result = append(result, tx.Name)
+		}
+		return strings.Join(result, ",")
+	}
+
+	sorting := dto_transaction.SortingDTO{SortBy: "-amount"}
+
+	first, err := service.GetIssues(dto_transaction.PaginationDTO{Page: 1, Limit: 2}, sorting, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if names(first) != "TX5,TX4" || first.PrevCursor != "" || first.NextCursor == "" {
+		t.Fatalf("Expected TX5,TX4 with only a next cursor, got %s (%q, %q)", names(first), first.PrevCursor, first.NextCursor)
+	}
+
+	// The cursor carries the sort order, so it can be followed on its own
+	second, err := service.GetIssues(dto_transaction.PaginationDTO{Limit: 2, Cursor: first.NextCursor}, dto_transaction.SortingDTO{}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if names(second) != "TX3,TX2" || second.PrevCursor == "" || second.NextCursor == "" || second.Total != 5 {
+		t.Fatalf("Expected TX3,TX2 of 5 with both cursors, got %s of %d", names(second), second.Total)
+	}
+
+	last, err := service.GetIssues(dto_transaction.PaginationDTO{Limit: 2, Cursor: second.NextCursor}, sorting, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if names(last) != "TX1" || last.NextCursor != "" {
+		t.Fatalf("Expected TX1 with no next cursor, got %s (%q)", names(last), last.NextCursor)
+	}
+
+	back, err := service.GetIssues(dto_transaction.PaginationDTO{Limit: 2, Cursor: last.PrevCursor}, sorting, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if names(back) != "TX3,TX2" {
+		t.Errorf("Expected prev_cursor to return TX3,TX2, got %s", names(back))
+	}
+
+	back, err = service.GetIssues(dto_transaction.PaginationDTO{Limit: 2, Cursor: back.PrevCursor}, sorting, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if names(back) != "TX5,TX4" || back.PrevCursor != "" {
+		t.Errorf("Expected to be back on the first page without a prev cursor, got %s (%q)", names(back), back.PrevCursor)
+	}
+
+	_, err = service.GetIssues(dto_transaction.PaginationDTO{Limit: 2, Cursor: first.NextCursor}, dto_transaction.SortingDTO{SortBy: "amount"}, userID)
+	if !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Errorf("Expected a cursor used with another sort order to be rejected, got %v", err)
+	}
+
+	_, err = service.GetIssues(dto_transaction.PaginationDTO{Limit: 2, Cursor: first.NextCursor + "x"}, sorting, userID)
+	if !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Errorf("Expected a tampered cursor to be rejected, got %v", err)
+	}
+}
+
+func TestCursor_Timestamps(t *testing.T) {
+	service := &transactionService{cursors: util.NewCursorSigner("secret")}
+	keys, _ := dto_transaction.SortingDTO{}.Keys()
+
+	// Unix nanoseconds only reach from 1678 to 2262
+	for _, timestamp := range []time.Time{
+		time.Date(1600, 1, 2, 3, 4, 5, 6, time.UTC),
+		time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.FixedZone("", 3600)),
+		time.Date(2300, 12, 31, 23, 59, 59, 999999999, time.UTC),
+	} {
+		token, err := service.encodeCursor(keys, domain.Transaction{ID: "tx", Timestamp: timestamp}, false)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		cursor, _, err := service.decodeCursor(token, dto_transaction.SortingDTO{})
+		if err != nil || !cursor.Anchor.Timestamp.Equal(timestamp) {
+			t.Errorf("Expected %v back from the cursor, got %+v, %v", timestamp, cursor, err)
+		}
+	}
+
+	// Another instance's cursors aren't accepted
+	other := &transactionService{cursors: util.NewCursorSigner("other")}
+	token, _ := other.encodeCursor(keys, domain.Transaction{ID: "tx", Timestamp: time.Now()}, false)
+	if _, _, err := service.decodeCursor(token, dto_transaction.SortingDTO{}); !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Errorf("Expected a cursor signed with another secret to be rejected, got %v", err)
+	}
+}
+
+func TestGetIssues_PastLastPage(t *testing.T) {
+	_, service, userID := setupTestService(t)
+
+	csvData := `1624507883, TX1, DEBIT, 100000, FAILED, test1
+1624608050, TX2, DEBIT, 200000, PENDING, test2`
+
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	response, err := service.GetIssues(dto_transaction.PaginationDTO{Page: 5, Limit: 2}, dto_transaction.SortingDTO{}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(response.Transactions) != 0 || response.Total != 2 {
+		t.Errorf("Expected no transactions but a total of 2, got %d of %d", len(response.Transactions), response.Total)
+	}
+}
+
+func TestUpdateTransaction(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	csvData := `1624507883, TX1, DEBIT, 100000, PENDING, tpyo`
+
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := repo.GetAllByUserID(userID)
+	id := stored[0].ID
+
+	response, err := service.UpdateTransaction(id, dto_transaction.UpdateTransactionRequestDTO{Description: ptr("typo"), Status: ptr("success")}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.ID != id || response.Description != "typo" || response.Status != "SUCCESS" || response.Name != "TX1" {
+		t.Errorf("Expected only description and status to change, got %+v", response)
+	}
+
+	balance, _ := service.CalculateBalance(userID)
+	if balance.Debits != 100000 {
+		t.Errorf("Expected the resolved transaction to count towards the balance, got %d", balance.Debits)
+	}
+
+	for _, request := range []dto_transaction.UpdateTransactionRequestDTO{
+		{Status: ptr("RESOLVED")},
+		{Name: ptr("  ")},
+		{Amount: ptr(int64(-5))},
+		{Timestamp: ptr("yesterday")},
+	} {
+		if _, err := service.UpdateTransaction(id, request, userID); !errors.Is(err, domain.ErrInvalidTransaction) {
+			t.Errorf("Expected %+v to be rejected as invalid, got %v", request, err)
+		}
+	}
+
+	if transaction, _ := service.GetTransaction(id, userID); transaction.Name != "TX1" || transaction.Amount != 100000 {
+		t.Errorf("Expected rejected updates to change nothing, got %+v", transaction)
+	}
+}
+
+func TestTransaction_OtherUser(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	csvData := `1624507883, TX1, DEBIT, 100000, PENDING, test`
+
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := repo.GetAllByUserID(userID)
+	id := stored[0].ID
+
+	if _, err := service.GetTransaction(id, "intruder"); !errors.Is(err, domain.ErrTransactionNotFound) {
+		t.Errorf("Expected another user's transaction to be not found, got %v", err)
+	}
+
+	if _, err := service.UpdateTransaction(id, dto_transaction.UpdateTransactionRequestDTO{Name: ptr("mine")}, "intruder"); !errors.Is(err, domain.ErrTransactionNotFound) {
+		t.Errorf("Expected updating another user's transaction to fail, got %v", err)
+	}
+
+	if err := service.DeleteTransaction(id, "intruder"); !errors.Is(err, domain.ErrTransactionNotFound) {
+		t.Errorf("Expected deleting another user's transaction to fail, got %v", err)
+	}
+
+	if err := service.DeleteTransaction(id, userID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := service.GetTransaction(id, userID); !errors.Is(err, domain.ErrTransactionNotFound) {
+		t.Errorf("Expected the deleted transaction to be gone, got %v", err)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func TestSearchTransactions(t *testing.T) {
+	_, service, userID := setupTestService(t)
+
+	csvData := `1609459200, Grocery Store, DEBIT, 5000, SUCCESS, Weekly groceries
+1609545600, Salary Deposit, CREDIT, 50000, SUCCESS, Monthly salary
+1609632000, Failed Payment, DEBIT, 2000, FAILED, Insufficient funds
+1609718400, Grocery Store, DEBIT, 7000, PENDING, Groceries and snacks`
+
+	_, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A date-only "to" covers the whole of 2021-01-03
+	filter := dto_transaction.TransactionFilterDTO{From: "2021-01-02", To: "2021-01-03", Type: "debit,credit"}
+	response, err := service.SearchTransactions(filter, dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Total != 2 || response.Transactions[0].Name != "Salary Deposit" || response.Transactions[1].Name != "Failed Payment" {
+		t.Errorf("Expected the two transactions of 2 and 3 January, got %+v", response.Transactions)
+	}
+
+	filter = dto_transaction.TransactionFilterDTO{Q: "groceries", Status: "pending, success", MinAmount: "6000"}
+	response, err = service.SearchTransactions(filter, dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Total != 1 || response.Transactions[0].Amount != 7000 {
+		t.Errorf("Expected only the 7000 grocery run, got %+v", response.Transactions)
+	}
+
+	for _, invalid := range []dto_transaction.TransactionFilterDTO{
+		{From: "yesterday"},
+		{From: "2021-01-03", To: "2021-01-02"},
+		{Type: "REFUND"},
+		{Status: "SUCCESS,DONE"},
+		{MinAmount: "-1"},
+		{MaxAmount: "lots"},
+		{MinAmount: "10", MaxAmount: "5"},
+	} {
+		if _, err := service.SearchTransactions(invalid, dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, userID); !errors.Is(err, domain.ErrInvalidFilter) {
+			t.Errorf("Expected %+v to be rejected as an invalid filter, got %v", invalid, err)
+		}
+	}
+}
+
+func TestUploads(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	first, err := service.ParseAndStoreStatement(strings.NewReader(`1624507883, TX1, CREDIT, 500000, SUCCESS, salary
+1624608050, TX2, DEBIT, 100000, PENDING, test`), dto_transaction.UploadRequestDTO{Filename: "june.csv"}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second, err := service.ParseAndStoreStatement(strings.NewReader(`1624708050, TX3, DEBIT, 200000, SUCCESS, rent`), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(`1624808050, TX4, REFUND, 1, SUCCESS, bad`), testUpload, userID); err == nil {
+		t.Fatal("Expected error for invalid type")
+	}
+
+	upload, err := service.GetUpload(first.UploadID, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if upload.Filename != "june.csv" || upload.RowCount != 2 || upload.Status != "COMPLETED" || len(upload.Checksum) != 64 {
+		t.Errorf("Unexpected upload: %+v", upload)
+	}
+
+	list, err := service.ListUploads(dto_transaction.PaginationDTO{}, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if list.Total != 3 || list.Uploads[0].Status != "FAILED" || list.Uploads[0].Checksum == "" || list.Uploads[1].ID != second.UploadID {
+		t.Errorf("Expected the failed upload first, then the others newest first, got %+v", list.Uploads)
+	}
+
+	stored, _ := repo.GetAllByUserID(userID)
+	if len(stored) != 3 || stored[0].BatchID != first.UploadID || stored[2].BatchID != second.UploadID {
+		t.Errorf("Expected every transaction to be stamped with its upload, got %+v", stored)
+	}
+
+	if _, err := service.GetUpload(first.UploadID, "intruder"); !errors.Is(err, domain.ErrUploadNotFound) {
+		t.Errorf("Expected another user's upload to be not found, got %v", err)
+	}
+
+	if _, err := service.DeleteUpload(first.UploadID, "intruder"); !errors.Is(err, domain.ErrUploadNotFound) {
+		t.Errorf("Expected deleting another user's upload to fail, got %v", err)
+	}
+
+	if _, err := service.DeleteUpload(list.Uploads[0].ID, userID); !errors.Is(err, domain.ErrUploadNotCompleted) {
+		t.Errorf("Expected a failed upload not to be rolled back, got %v", err)
+	}
+
+	deleted, err := service.DeleteUpload(first.UploadID, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if deleted.DeletedRows != 2 || deleted.Upload.Status != "ROLLED_BACK" {
+		t.Errorf("Expected 2 rows rolled back, got %+v", deleted)
+	}
+
+	balance, _ := service.CalculateBalance(userID)
+	if balance.Balance != -200000 {
+		t.Errorf("Expected only the second upload to count, got balance %d", balance.Balance)
+	}
+
+	if _, err := service.DeleteUpload(first.UploadID, userID); !errors.Is(err, domain.ErrUploadNotCompleted) {
+		t.Errorf("Expected rolling back twice to fail, got %v", err)
+	}
+}
+
+func TestParseAndStoreStatement_Duplicates(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	january := `1624507883, TX1, CREDIT, 500000, SUCCESS, salary
+1624608050, TX2, DEBIT, 100000, SUCCESS, coffee
+1624608050, TX2, DEBIT, 100000, SUCCESS, coffee`
+
+	first, err := service.ParseAndStoreStatement(strings.NewReader(january), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The same file again is skipped as a whole
+	again, err := service.ParseAndStoreStatement(strings.NewReader(january), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if again.TotalRows != 3 || again.SkippedRows != 3 || again.DuplicateOf != first.UploadID {
+		t.Errorf("Expected every row to be skipped as a duplicate of the first upload, got %+v", again)
+	}
+
+	reject := dto_transaction.UploadRequestDTO{OnDuplicate: dto_transaction.OnDuplicateReject}
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(january), reject, userID); !errors.Is(err, domain.ErrDuplicateUpload) {
+		t.Errorf("Expected a re-upload to be rejected, got %v", err)
+	}
+
+	// An overlapping statement repeats one coffee and the salary
+	overlapping := `1624507883, TX1, CREDIT, 500000, SUCCESS, salary
+1624608050, TX2, DEBIT, 100000, SUCCESS, coffee
+1624608050, TX2, DEBIT, 100000, SUCCESS, coffee
+1624608050, TX2, DEBIT, 100000, SUCCESS, coffee
+1624708050, TX3, DEBIT, 200000, SUCCESS, rent`
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(overlapping), reject, userID); !errors.Is(err, domain.ErrDuplicateUpload) {
+		t.Errorf("Expected duplicate rows to be rejected, got %v", err)
+	}
+
+	response, err := service.ParseAndStoreStatement(strings.NewReader(overlapping), testUpload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.TotalRows != 5 || response.SkippedRows != 3 || response.DuplicateOf != "" {
+		t.Errorf("Expected the 3 rows uploaded before to be skipped, got %+v", response)
+	}
+
+	stored, _ := repo.GetAllByUserID(userID)
+	if len(stored) != 5 {
+		t.Errorf("Expected the third coffee and the rent to be added, got %d transactions", len(stored))
+	}
+
+	force := dto_transaction.UploadRequestDTO{OnDuplicate: dto_transaction.OnDuplicateForce}
+	forced, err := service.ParseAndStoreStatement(strings.NewReader(january), force, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if forced.SkippedRows != 0 || forced.DuplicateOf != first.UploadID {
+		t.Errorf("Expected a forced re-upload to store every row, got %+v", forced)
+	}
+
+	// Once every stored copy is rolled back, the file is new again
+	for _, id := range []string{first.UploadID, response.UploadID, forced.UploadID} {
+		if _, err := service.DeleteUpload(id, userID); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	restored, err := service.ParseAndStoreStatement(strings.NewReader(january), reject, userID)
+	if err != nil || restored.DuplicateOf != "" {
+		t.Errorf("Expected a rolled back file to be uploaded again, got %+v, %v", restored, err)
+	}
+}
+
+// slowRepository widens the window between counting the stored copies of
+// rows and storing them.
+type slowRepository struct {
+	domain.TransactionRepository
+}
+
+func (r slowRepository) CountFingerprints(userID string, transactions []domain.Transaction) (map[string]int, error) {
+	counts, err := r.TransactionRepository.CountFingerprints(userID, transactions)
+	time.Sleep(10 * time.Millisecond)
+	return counts, err
+}
+
+func TestParseAndStoreStatement_ConcurrentDuplicates(t *testing.T) {
+	storage := repotest.Open(t)
+	repo := storage.Transactions
+	service := NewTransactionService(slowRepository{repo}, storage.Uploads, storage.Profiles, util.NewCursorSigner("secret"))
+	userID := "tester"
+
+	csvData := `1624507883, TX1, CREDIT, 500000, SUCCESS, salary
+1624608050, TX2, DEBIT, 100000, SUCCESS, coffee`
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.ParseAndStoreStatement(strings.NewReader(csvData), testUpload, userID); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stored, _ := repo.GetAllByUserID(userID); len(stored) != 2 {
+		t.Errorf("Expected the file to be stored once, got %d transactions", len(stored))
+	}
+}
+
+func TestParseAndStoreStatement_IdempotencyKey(t *testing.T) {
+	repo, service, userID := setupTestService(t)
+
+	csvData := `1624507883, TX1, CREDIT, 500000, SUCCESS, salary`
+	upload := dto_transaction.UploadRequestDTO{IdempotencyKey: "key-1", OnDuplicate: dto_transaction.OnDuplicateForce}
+
+	first, err := service.ParseAndStoreStatement(strings.NewReader(csvData), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	retried, err := service.ParseAndStoreStatement(strings.NewReader(csvData), upload, userID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(retried, first) {
+		t.Errorf("Expected the retry to get the first response, got %+v and %+v", first, retried)
+	}
+
+	if stored, _ := repo.GetAllByUserID(userID); len(stored) != 1 {
+		t.Errorf("Expected the retry to store nothing, even when forced, got %d transactions", len(stored))
+	}
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(`1624608050, TX2, DEBIT, 1, SUCCESS, other`), upload, userID); !errors.Is(err, domain.ErrIdempotencyKeyConflict) {
+		t.Errorf("Expected the key to be refused for a different file, got %v", err)
+	}
+
+	// Keys are per user
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(csvData), upload, "someone-else"); err != nil {
+		t.Errorf("Expected another user to use the same key, got %v", err)
+	}
+
+	// A failed upload doesn't keep its key
+	failing := dto_transaction.UploadRequestDTO{IdempotencyKey: "key-2"}
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(`1624708050, TX3, REFUND, 1, SUCCESS, bad`), failing, userID); err == nil {
+		t.Fatal("Expected error for invalid type")
+	}
+
+	if _, err := service.ParseAndStoreStatement(strings.NewReader(`1624708050, TX3, DEBIT, 1, SUCCESS, fixed`), failing, userID); err != nil {
+		t.Errorf("Expected the fixed file to be accepted with the same key, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+// Statement file formats, told apart by their content.
+const (
+	formatCSV   = "csv"
+	formatOFX   = "ofx"
+	formatMT940 = "mt940"
+	formatCamt  = "camt.053"
+)
+
+// formatSniffSize is how much of a statement is looked at to tell its
+// format.
+const formatSniffSize = 1024
+
+// maxRowErrors caps the row errors reported for one upload. Every bad row is
+// still counted.
+const maxRowErrors = 100
+
+// statement is what parsing an uploaded file produced: its valid rows, and
+// why the others were left out.
+type statement struct {
+	transactions []domain.Transaction
+	failedRows   int
+	errors       []dto_transaction.RowErrorDTO
+	// balances are the balances the statement states, by account, for
+	// formats that have them.
+	balances []domain.StatementBalance
+}
+
+// add keeps a parsed row, or counts it as bad if it has errors. Text that
+// isn't UTF-8, or that was decoded to U+FFFD because it couldn't be, makes
+// the row bad too, whatever the format.
+func (s *statement) add(line int, transaction domain.Transaction, rowErrors []dto_transaction.RowErrorDTO) {
+	for _, text := range []struct{ field, value string }{
+		{"name", transaction.Name},
+		{"description", transaction.Description},
+		{"account", transaction.Account},
+		{"external_id", transaction.ExternalID},
+	} {
+		if !utf8.ValidString(text.value) || strings.ContainsRune(text.value, utf8.RuneError) {
+			rowErrors = append(rowErrors, dto_transaction.RowErrorDTO{Line: line, Field: text.field, Reason: "field contains invalid text"})
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		s.fail(rowErrors...)
+		return
+	}
+
+	s.transactions = append(s.transactions, transaction)
+}
+
+// balance returns the balances stated for the account, which are added the
+// first time it is asked for. The pointer is only good until the next call.
+func (s *statement) balance(account string) *domain.StatementBalance {
+	for index := range s.balances {
+		if s.balances[index].Account == account {
+			return &s.balances[index]
+		}
+	}
+
+	s.balances = append(s.balances, domain.StatementBalance{Account: account})
+	return &s.balances[len(s.balances)-1]
+}
+
+// sumBooked adds up each account's booked entries, for the balances stated
+// for it to be checked against. Pending ones haven't moved the balance yet.
+func (s *statement) sumBooked() {
+	for index := range s.balances {
+		balance := &s.balances[index]
+
+		for _, transaction := range s.transactions {
+			if transaction.Account != balance.Account || transaction.Status != domain.TransactionStatusSuccess {
+				continue
+			}
+
+			if transaction.Type == domain.TransactionTypeCredit {
+				balance.Booked += transaction.Amount
+			} else {
+				balance.Booked -= transaction.Amount
+			}
+		}
+	}
+}
+
+// fail counts a bad row and keeps its errors, up to maxRowErrors in all.
+func (s *statement) fail(rowErrors ...dto_transaction.RowErrorDTO) {
+	s.failedRows++
+
+	room := max(maxRowErrors-len(s.errors), 0)
+	s.errors = append(s.errors, rowErrors[:min(room, len(rowErrors))]...)
+}
+
+// check applies the onError policy: with reject, any bad row fails the
+// upload, with skip only a file without a single good row does.
+func (s *statement) check(policy string) error {
+	if s.failedRows == 0 {
+		return nil
+	}
+
+	total := s.failedRows + len(s.transactions)
+
+	if policy == dto_transaction.OnErrorSkip && len(s.transactions) > 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d of %d rows are invalid", domain.ErrInvalidRows, s.failedRows, total)
+}
+
+// parseStatement reads a statement in whichever format its content is in,
+// stamped with the user it belongs to. The layout, when it comes from an
+// import profile, only applies to CSV.
+func parseStatement(text io.Reader, format *layout, profiled bool, userID string) (*statement, error) {
+	buffered := bufio.NewReader(text)
+
+	var parsed *statement
+	var err error
+
+	kind := sniffFormat(buffered)
+	if kind != formatCSV && profiled {
+		return nil, fmt.Errorf("%w: import profiles only apply to CSV files", domain.ErrInvalidStatement)
+	}
+
+	switch kind {
+	case formatOFX:
+		parsed, err = parseOFX(buffered)
+	case formatMT940:
+		parsed, err = parseMT940(buffered)
+	case formatCamt:
+		parsed, err = parseCamt(buffered)
+	default:
+		parsed, err = parseCSV(buffered, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for index := range parsed.transactions {
+		parsed.transactions[index].UserID = userID
+	}
+	parsed.sumBooked()
+
+	return parsed, nil
+}
+
+// sniffFormat tells a statement's format from its first bytes. Anything
+// that isn't recognisably another format is read as CSV.
+func sniffFormat(text *bufio.Reader) string {
+	head, _ := text.Peek(formatSniffSize)
+	head = bytes.ToUpper(bytes.TrimSpace(head))
+
+	xml := bytes.HasPrefix(head, []byte("<"))
+
+	switch {
+	// OFX 1.x starts with its SGML header, and 2.x is XML
+	case bytes.HasPrefix(head, []byte("OFXHEADER:")),
+		xml && (bytes.Contains(head, []byte("<?OFX")) || bytes.Contains(head, []byte("<OFX>"))):
+		return formatOFX
+	case xml && (bytes.Contains(head, []byte("CAMT.053")) || bytes.Contains(head, []byte("<BKTOCSTMRSTMT>"))):
+		return formatCamt
+	// MT940 starts with the SWIFT header blocks or the first field, or with
+	// a bank's own header line before it
+	case bytes.HasPrefix(head, []byte("{1:")), bytes.HasPrefix(head, []byte(":20:")),
+		bytes.Contains(head, []byte("\n:20:")) && bytes.Contains(head, []byte("\n:25:")):
+		return formatMT940
+	}
+
+	return formatCSV
+}
@@ -0,0 +1,237 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+)
+
+func (s *transactionService) ListUploads(pagination dto_transaction.PaginationDTO, userID string) (*dto_transaction.UploadListResponseDTO, error) {
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+
+	if pagination.Limit < 1 {
+		pagination.Limit = 10
+	}
+
+	batches, total, err := s.uploads.FindByUserID(userID, pagination.Page, pagination.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto_transaction.UploadListResponseDTO{
+		Uploads: make([]dto_transaction.UploadDTO, 0, len(batches)),
+		Total:   total,
+	}
+
+	for _, batch := range batches {
+		response.Uploads = append(response.Uploads, toUploadDTO(batch))
+	}
+
+	return response, nil
+}
+
+func (s *transactionService) GetUpload(id string, userID string) (*dto_transaction.UploadDTO, error) {
+	batch, err := s.findOwnedUpload(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toUploadDTO(batch)
+	return &response, nil
+}
+
+func (s *transactionService) DeleteUpload(id string, userID string) (*dto_transaction.DeleteUploadResponseDTO, error) {
+	defer s.lockUploads(userID)()
+
+	batch, err := s.findOwnedUpload(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failed uploads stored nothing, and rolled back ones have nothing left.
+	if batch.Status != domain.UploadStatusCompleted {
+		return nil, fmt.Errorf("%w: upload is %s", domain.ErrUploadNotCompleted, strings.ReplaceAll(strings.ToLower(string(batch.Status)), "_", " "))
+	}
+
+	deleted, err := s.uploads.RollBack(batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	batch.Status = domain.UploadStatusRolledBack
+
+	return &dto_transaction.DeleteUploadResponseDTO{
+		Upload:      toUploadDTO(batch),
+		DeletedRows: deleted,
+	}, nil
+}
+
+// replay answers a retried upload with the outcome of the first request that
+// used its idempotency key, or returns nothing if no upload has used it yet.
+func (s *transactionService) replay(batch *domain.UploadBatch) (*dto_transaction.UploadResponseDTO, error) {
+	previous, err := s.uploads.FindByIdempotencyKey(batch.UserID, batch.IdempotencyKey)
+	if errors.Is(err, domain.ErrUploadNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if previous.Checksum != batch.Checksum {
+		return nil, fmt.Errorf("%w: it was used to upload a different file", domain.ErrIdempotencyKeyConflict)
+	}
+
+	if previous.Status == domain.UploadStatusProcessing {
+		return nil, fmt.Errorf("%w: the upload that used it is still being processed", domain.ErrIdempotencyKeyConflict)
+	}
+
+	return toUploadResponse(previous, nil), nil
+}
+
+// dropDuplicates applies the onDuplicate policy to the rows that were
+// uploaded before and returns the rows to store. A re-upload of a file that
+// is still stored is a duplicate as a whole. Otherwise each row is matched
+// by its fingerprint, once per matching stored row, so a statement with two
+// identical rows overlapping one that had only one of them keeps the other.
+func (s *transactionService) dropDuplicates(batch *domain.UploadBatch, transactions []domain.Transaction, policy string) ([]domain.Transaction, error) {
+	earlier, err := s.uploads.FindByChecksum(batch.UserID, batch.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uploads that were skipped entirely, rolled back or failed don't hold
+	// the file's rows any more. The newest one that does is the original.
+	for _, previous := range earlier {
+		if previous.ID != batch.ID && previous.Status == domain.UploadStatusCompleted && previous.RowCount > 0 {
+			batch.DuplicateOf = previous.ID
+			break
+		}
+	}
+
+	switch {
+	case policy == dto_transaction.OnDuplicateForce:
+		return transactions, nil
+	case batch.DuplicateOf != "" && policy == dto_transaction.OnDuplicateReject:
+		return nil, fmt.Errorf("%w: the file was already uploaded as %s", domain.ErrDuplicateUpload, batch.DuplicateOf)
+	case batch.DuplicateOf != "":
+		return nil, nil
+	}
+
+	counts, err := s.repo.CountFingerprints(batch.UserID, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]domain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if fingerprint := tx.Fingerprint(); counts[fingerprint] > 0 {
+			counts[fingerprint]--
+			continue
+		}
+		stored = append(stored, tx)
+	}
+
+	if duplicates := len(transactions) - len(stored); duplicates > 0 && policy == dto_transaction.OnDuplicateReject {
+		return nil, fmt.Errorf("%w: %d of %d rows were already uploaded", domain.ErrDuplicateUpload, duplicates, len(transactions))
+	}
+
+	return stored, nil
+}
+
+// lockUploads holds the user's upload lock until the returned func is
+// called.
+func (s *transactionService) lockUploads(userID string) func() {
+	lock, _ := s.uploadLocks.LoadOrStore(userID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// fail records the upload as failed and returns err. A failed upload doesn't
+// keep its idempotency key, so the request can be retried once fixed.
+func (s *transactionService) fail(batch *domain.UploadBatch, err error) error {
+	batch.Status = domain.UploadStatusFailed
+	batch.IdempotencyKey = ""
+	batch.DuplicateOf = ""
+
+	var recordErr error
+	if batch.ID == "" {
+		_, recordErr = s.uploads.Save(batch)
+	} else {
+		_, recordErr = s.uploads.Update(batch)
+	}
+
+	if recordErr != nil {
+		log.Printf("Failed to record failed upload: %v", recordErr)
+	}
+
+	return err
+}
+
+// findOwnedUpload is findOwned for upload batches.
+func (s *transactionService) findOwnedUpload(id string, userID string) (*domain.UploadBatch, error) {
+	batch, err := s.uploads.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if batch.UserID != userID {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	return batch, nil
+}
+
+func toUploadResponse(batch *domain.UploadBatch, rowErrors []dto_transaction.RowErrorDTO) *dto_transaction.UploadResponseDTO {
+	status := "success"
+	if batch.Status == domain.UploadStatusFailed {
+		status = "failed"
+	}
+
+	return &dto_transaction.UploadResponseDTO{
+		UploadID:     batch.ID,
+		TotalRows:    batch.RowCount + batch.SkippedRows + batch.FailedRows,
+		SkippedRows:  batch.SkippedRows,
+		FailedRows:   batch.FailedRows,
+		DuplicateOf:  batch.DuplicateOf,
+		Errors:       rowErrors,
+		UploadStatus: status,
+		Balances:     toStatementBalanceDTOs(batch.Balances),
+	}
+}
+
+func toUploadDTO(batch *domain.UploadBatch) dto_transaction.UploadDTO {
+	return dto_transaction.UploadDTO{
+		ID:          batch.ID,
+		Filename:    batch.Filename,
+		Checksum:    batch.Checksum,
+		RowCount:    batch.RowCount,
+		SkippedRows: batch.SkippedRows,
+		FailedRows:  batch.FailedRows,
+		DuplicateOf: batch.DuplicateOf,
+		UploadedAt:  batch.UploadedAt.Format(time.RFC3339),
+		Status:      string(batch.Status),
+		Balances:    toStatementBalanceDTOs(batch.Balances),
+	}
+}
+
+func toStatementBalanceDTOs(balances []domain.StatementBalance) []dto_transaction.StatementBalanceDTO {
+	var dtos []dto_transaction.StatementBalanceDTO
+	for _, balance := range balances {
+		dtos = append(dtos, dto_transaction.StatementBalanceDTO{
+			Account:        balance.Account,
+			OpeningBalance: balance.Opening,
+			ClosingBalance: balance.Closing,
+			Booked:         balance.Booked,
+			Mismatch:       balance.Mismatched(),
+		})
+	}
+
+	return dtos
+}
@@ -0,0 +1,195 @@
+package transaction
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+)
+
+// referenceTime is the time Go layouts are written in.
+var referenceTime = time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+// setField parses value into the transaction field it belongs to.
+func (l *layout) setField(transaction *domain.Transaction, field string, value string) error {
+	switch field {
+	case "timestamp":
+		timestamp, err := l.parseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		transaction.Timestamp = timestamp
+
+	case "name":
+		transaction.Name = value
+
+	case "type":
+		transactionType := domain.TransactionType(strings.ToUpper(value))
+		if !transactionType.IsValid() {
+			return fmt.Errorf("invalid type %q, expected DEBIT or CREDIT", value)
+		}
+		transaction.Type = transactionType
+
+	case "amount":
+		amount, negative, err := l.parseAmount(value)
+		if err != nil || negative && l.sign == domain.SignConventionType {
+			return fmt.Errorf("invalid amount %q, expected %s", value, l.amountHint())
+		}
+		transaction.Amount = amount
+
+		switch {
+		case l.sign == domain.SignConventionType:
+		case negative == (l.sign == domain.SignConventionNegativeDebit):
+			transaction.Type = domain.TransactionTypeDebit
+		default:
+			transaction.Type = domain.TransactionTypeCredit
+		}
+
+	case "status":
+		status := domain.TransactionStatus(strings.ToUpper(value))
+		if !status.IsValid() {
+			return fmt.Errorf("invalid status %q, expected SUCCESS, FAILED or PENDING", value)
+		}
+		transaction.Status = status
+
+	case "description":
+		transaction.Description = value
+	}
+
+	return nil
+}
+
+// parseTimestamp reads a timestamp in the layout's date layout and time
+// zone, or as Unix seconds or RFC 3339 without one.
+func (l *layout) parseTimestamp(value string) (time.Time, error) {
+	var timestamp time.Time
+	var err error
+
+	if l.dateLayout != "" {
+		timestamp, err = time.ParseInLocation(l.dateLayout, value, l.location)
+	} else if seconds, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil {
+		timestamp = time.Unix(seconds, 0)
+	} else {
+		timestamp, err = time.Parse(time.RFC3339, value)
+	}
+
+	if err != nil || timestamp.Unix() <= 0 {
+		expected := "Unix seconds or RFC 3339"
+		if l.dateLayout != "" {
+			expected = "a date like " + referenceTime.Format(l.dateLayout)
+		}
+		return time.Time{}, fmt.Errorf("invalid timestamp %q, expected %s", value, expected)
+	}
+
+	return timestamp, nil
+}
+
+// parseAmount reads an amount in the layout's number format, as an integer
+// with the layout's decimal places. A negative amount has a minus sign
+// before or after it, or is in parentheses. Thousands separators, where
+// there are any, must group the digits in threes.
+func (l *layout) parseAmount(value string) (int64, bool, error) {
+	negative := false
+	switch {
+	case len(value) > 1 && strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")"):
+		negative, value = true, value[1:len(value)-1]
+	case strings.HasPrefix(value, "-"):
+		negative, value = true, value[1:]
+	case strings.HasSuffix(value, "-"):
+		negative, value = true, value[:len(value)-1]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+
+	whole, fraction, hasFraction := strings.Cut(strings.TrimSpace(value), string(l.decimal))
+	if l.thousands != 0 && strings.ContainsRune(whole, l.thousands) {
+		groups := strings.Split(whole, string(l.thousands))
+		if len(groups[0]) < 1 || len(groups[0]) > 3 {
+			return 0, false, fmt.Errorf("misplaced thousands separator")
+		}
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return 0, false, fmt.Errorf("misplaced thousands separator")
+			}
+		}
+		whole = strings.Join(groups, "")
+	}
+
+	if !isDigits(whole) || hasFraction && !isDigits(fraction) {
+		return 0, false, fmt.Errorf("not a number")
+	}
+
+	// Digits past the decimal places are only allowed as trailing zeros.
+	if len(fraction) > l.places {
+		if strings.Trim(fraction[l.places:], "0") != "" {
+			return 0, false, fmt.Errorf("more than %d decimal places", l.places)
+		}
+		fraction = fraction[:l.places]
+	}
+
+	amount, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", l.places-len(fraction)), 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return amount, negative && amount != 0, nil
+}
+
+// currencyPlaces holds the ISO 4217 currencies whose minor unit isn't a
+// hundredth, by their number of decimal places.
+var currencyPlaces = map[string]int{
+	"BHD": 3, "BIF": 0, "CLF": 4, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0,
+	"RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "UYW": 4, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
+
+// inCurrency returns a copy of the layout for amounts in the ISO 4217
+// currency, stored in its minor unit. An unknown or missing currency has
+// two decimal places, as most do.
+func (l *layout) inCurrency(currency string) *layout {
+	copied := *l
+	copied.places = 2
+	if places, exists := currencyPlaces[strings.ToUpper(strings.TrimSpace(currency))]; exists {
+		copied.places = places
+	}
+
+	return &copied
+}
+
+// amountHint describes the amounts the layout accepts, for error messages.
+func (l *layout) amountHint() string {
+	if l.places == 0 && l.thousands == 0 && l.sign == domain.SignConventionType {
+		return "a non-negative integer"
+	}
+
+	example := "1234"
+	if l.thousands != 0 {
+		example = "1" + string(l.thousands) + "234"
+	}
+	if l.places > 0 {
+		example += string(l.decimal) + strings.Repeat("0", l.places)
+	}
+
+	if l.sign == domain.SignConventionType {
+		return "an amount like " + example
+	}
+
+	return "an amount like " + example + " or -" + example
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,55 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+)
+
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier appends notifications as JSON lines to path, so a local mail
+// catcher or a test can pick them up.
+func NewFileNotifier(path string) domain.Notifier {
+	return &fileNotifier{path: path}
+}
+
+type notification struct {
+	Type      string    `json:"type"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+func (n *fileNotifier) SendPasswordReset(user *domain.User, token string, expiresAt time.Time) error {
+	return n.write(notification{
+		Type:      "password_reset",
+		UserID:    user.ID,
+		Username:  user.Username,
+		Token:     token,
+		ExpiresAt: expiresAt,
+		SentAt:    time.Now(),
+	})
+}
+
+func (n *fileNotifier) write(message notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %v", err)
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(message)
+}
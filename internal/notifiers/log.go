@@ -0,0 +1,21 @@
+package notifiers
+
+import (
+	"log"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+)
+
+type logNotifier struct{}
+
+// NewLogNotifier writes notifications to the application log. Meant for local
+// development only, as reset tokens end up in plain text in the logs.
+func NewLogNotifier() domain.Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) SendPasswordReset(user *domain.User, token string, expiresAt time.Time) error {
+	log.Printf("Password reset for %q: token=%s expires=%s", user.Username, token, expiresAt.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,14 @@
+package notifiers
+
+import (
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/config"
+)
+
+func New(cfg config.Password) domain.Notifier {
+	if cfg.Notifier == "file" {
+		return NewFileNotifier(cfg.NotifierFile)
+	}
+
+	return NewLogNotifier()
+}
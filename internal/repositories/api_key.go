@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type apiKeyRepository struct {
+	mu      sync.RWMutex
+	keys    map[string]*domain.APIKey
+	journal *journal.Journal
+}
+
+func NewAPIKeyRepository() domain.APIKeyRepository {
+	return &apiKeyRepository{
+		keys: make(map[string]*domain.APIKey),
+	}
+}
+
+func (r *apiKeyRepository) Save(key *domain.APIKey) (*domain.APIKey, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	if key.Hash == "" {
+		return nil, fmt.Errorf("key hash is required")
+	}
+
+	stored := copyAPIKey(key)
+	stored.ID = util.GenerateRandomID()
+
+	if err := r.journal.Append(apiKeyStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.keys[stored.ID] = stored
+	key.ID = stored.ID
+	return key, nil
+}
+
+func (r *apiKeyRepository) Update(key *domain.APIKey) (*domain.APIKey, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[key.ID]; !exists {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	stored := copyAPIKey(key)
+	if err := r.journal.Append(apiKeyStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.keys[key.ID] = stored
+	return key, nil
+}
+
+func (r *apiKeyRepository) FindByID(id string) (*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	return copyAPIKey(key), nil
+}
+
+func (r *apiKeyRepository) FindByHash(hash string) (*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return nil, fmt.Errorf("api key not found")
+}
+
+func (r *apiKeyRepository) FindByUserID(userID string) ([]*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*domain.APIKey, 0)
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+
+	return keys, nil
+}
+
+// copyAPIKey copies a key, scopes included, so that the stored one can't be
+// changed from outside the repository.
+func copyAPIKey(key *domain.APIKey) *domain.APIKey {
+	copied := *key
+	copied.Scopes = slices.Clone(key.Scopes)
+	return &copied
+}
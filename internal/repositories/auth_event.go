@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"sync"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type authEventRepository struct {
+	mu      sync.RWMutex
+	events  []*domain.AuthEvent
+	journal *journal.Journal
+}
+
+func NewAuthEventRepository() domain.AuthEventRepository {
+	return &authEventRepository{
+		events: make([]*domain.AuthEvent, 0),
+	}
+}
+
+func (r *authEventRepository) Save(event *domain.AuthEvent) (*domain.AuthEvent, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *event
+	stored.ID = util.GenerateRandomID()
+
+	if err := r.journal.Append(authEventStore, journalOpAppend, &stored); err != nil {
+		return nil, err
+	}
+
+	r.events = append(r.events, &stored)
+	event.ID = stored.ID
+	return event, nil
+}
+
+func (r *authEventRepository) FindByUsername(username string) ([]*domain.AuthEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := make([]*domain.AuthEvent, 0)
+	for _, event := range r.events {
+		if event.Username == username {
+			copied := *event
+			events = append(events, &copied)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type importProfileRepository struct {
+	mu       sync.RWMutex
+	profiles map[string]*domain.ImportProfile
+	journal  *journal.Journal
+}
+
+func NewImportProfileRepository() domain.ImportProfileRepository {
+	return &importProfileRepository{
+		profiles: make(map[string]*domain.ImportProfile),
+	}
+}
+
+func (r *importProfileRepository) Save(profile *domain.ImportProfile) (*domain.ImportProfile, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkName(profile); err != nil {
+		return nil, err
+	}
+
+	stored := copyImportProfile(profile)
+	stored.ID = util.GenerateRandomID()
+
+	if err := r.journal.Append(importProfileStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.profiles[stored.ID] = stored
+	profile.ID = stored.ID
+	return profile, nil
+}
+
+func (r *importProfileRepository) Update(profile *domain.ImportProfile) (*domain.ImportProfile, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.profiles[profile.ID]; !exists {
+		return nil, domain.ErrImportProfileNotFound
+	}
+
+	if err := r.checkName(profile); err != nil {
+		return nil, err
+	}
+
+	stored := copyImportProfile(profile)
+	if err := r.journal.Append(importProfileStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.profiles[profile.ID] = stored
+	return profile, nil
+}
+
+func (r *importProfileRepository) Delete(id string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.profiles[id]; !exists {
+		return domain.ErrImportProfileNotFound
+	}
+
+	if err := r.journal.Append(importProfileStore, journalOpDelete, id); err != nil {
+		return err
+	}
+
+	delete(r.profiles, id)
+	return nil
+}
+
+func (r *importProfileRepository) FindByID(id string) (*domain.ImportProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profile, exists := r.profiles[id]
+	if !exists {
+		return nil, domain.ErrImportProfileNotFound
+	}
+
+	return copyImportProfile(profile), nil
+}
+
+func (r *importProfileRepository) FindByUserID(userID string) ([]*domain.ImportProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profiles := make([]*domain.ImportProfile, 0)
+	for _, profile := range r.profiles {
+		if profile.UserID == userID {
+			profiles = append(profiles, copyImportProfile(profile))
+		}
+	}
+
+	slices.SortFunc(profiles, func(a *domain.ImportProfile, b *domain.ImportProfile) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return profiles, nil
+}
+
+// checkName enforces what the SQLite repository's unique index does: names
+// are unique per user, ignoring case.
+func (r *importProfileRepository) checkName(profile *domain.ImportProfile) error {
+	for _, existing := range r.profiles {
+		if existing.ID != profile.ID && existing.UserID == profile.UserID && strings.EqualFold(existing.Name, profile.Name) {
+			return domain.ErrImportProfileNameTaken
+		}
+	}
+
+	return nil
+}
+
+// copyImportProfile copies a profile, columns and defaults included, so the
+// stored profile and the callers' never share anything.
+func copyImportProfile(profile *domain.ImportProfile) *domain.ImportProfile {
+	copied := *profile
+	copied.Columns = maps.Clone(profile.Columns)
+	copied.Defaults = maps.Clone(profile.Defaults)
+	return &copied
+}
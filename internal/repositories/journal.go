@@ -0,0 +1,610 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/config"
+	"firstpersoncode/go-uploader/internal/journal"
+)
+
+// Names and operations of the in-memory repositories' journal records.
+const (
+	userStore          = "users"
+	sessionStore       = "sessions"
+	transactionStore   = "transactions"
+	uploadStore        = "uploads"
+	importProfileStore = "import_profiles"
+	apiKeyStore        = "api_keys"
+	loginAttemptStore  = "login_attempts"
+	authEventStore     = "auth_events"
+	passwordResetStore = "password_reset_tokens"
+
+	journalOpPut          = "put"
+	journalOpDelete       = "delete"
+	journalOpDeleteByUser = "delete_by_user"
+	journalOpDeleteBatch  = "delete_batch"
+	journalOpRevokeFamily = "revoke_family"
+	journalOpTouch        = "touch"
+	journalOpRollBack     = "roll_back"
+	journalOpAppend       = "append"
+	journalOpClear        = "clear"
+)
+
+// openJournaledStorage returns in-memory repositories restored from, and
+// persisted to, the journal in cfg.JournalDir.
+func openJournaledStorage(cfg config.Storage) (*Storage, error) {
+	j, err := journal.Open(cfg.JournalDir, cfg.JournalCompactEvery)
+	if err != nil {
+		return nil, err
+	}
+
+	users := &userRepository{users: make(map[string]*domain.User), journal: j}
+	sessions := &sessionRepository{sessions: make(map[string]*domain.Session), journal: j}
+	transactions := newTransactionRepository(j)
+	uploads := newUploadBatchRepository(transactions, j)
+	profiles := &importProfileRepository{profiles: make(map[string]*domain.ImportProfile), journal: j}
+	apiKeys := &apiKeyRepository{keys: make(map[string]*domain.APIKey), journal: j}
+	attempts := &loginAttemptRepository{attempts: make(map[string]*domain.LoginAttempt), journal: j}
+	events := &authEventRepository{events: make([]*domain.AuthEvent, 0), journal: j}
+	resets := &passwordResetRepository{tokens: make(map[string]*domain.PasswordResetToken), journal: j}
+
+	if err := j.Load(users, sessions, transactions, uploads, profiles, apiKeys, attempts, events, resets); err != nil {
+		j.Close()
+		return nil, fmt.Errorf("failed to restore journal: %v", err)
+	}
+
+	return &Storage{
+		Users:          users,
+		Sessions:       sessions,
+		Transactions:   transactions,
+		Uploads:        uploads,
+		Profiles:       profiles,
+		APIKeys:        apiKeys,
+		LoginAttempts:  attempts,
+		AuthEvents:     events,
+		PasswordResets: resets,
+		journal:        j,
+	}, nil
+}
+
+func (r *userRepository) JournalName() string {
+	return userStore
+}
+
+func (r *userRepository) ApplyRecord(op string, data json.RawMessage) error {
+	if op != journalOpPut {
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	var user domain.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[user.ID] = &user
+	return nil
+}
+
+func (r *userRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*domain.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, copyUser(user))
+	}
+
+	return users
+}
+
+func (r *userRepository) RestoreState(data json.RawMessage) error {
+	var users []*domain.User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users = make(map[string]*domain.User, len(users))
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) JournalName() string {
+	return sessionStore
+}
+
+func (r *sessionRepository) ApplyRecord(op string, data json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch op {
+	case journalOpPut:
+		var session domain.Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		r.sessions[session.ID] = &session
+
+	case journalOpTouch:
+		var touch sessionTouch
+		if err := json.Unmarshal(data, &touch); err != nil {
+			return err
+		}
+		r.touch(touch)
+
+	case journalOpRevokeFamily, journalOpDelete, journalOpDeleteByUser:
+		var id string
+		if err := json.Unmarshal(data, &id); err != nil {
+			return err
+		}
+
+		switch op {
+		case journalOpRevokeFamily:
+			r.revokeFamily(id)
+		case journalOpDelete:
+			delete(r.sessions, id)
+		case journalOpDeleteByUser:
+			r.deleteByUserID(id)
+		}
+
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*domain.Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, copySession(session))
+	}
+
+	return sessions
+}
+
+func (r *sessionRepository) RestoreState(data json.RawMessage) error {
+	var sessions []*domain.Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions = make(map[string]*domain.Session, len(sessions))
+	for _, session := range sessions {
+		r.sessions[session.ID] = session
+	}
+
+	return nil
+}
+
+func (r *transactionRepository) JournalName() string {
+	return transactionStore
+}
+
+func (r *transactionRepository) ApplyRecord(op string, data json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch op {
+	case journalOpAppend:
+		var transactions []domain.Transaction
+		if err := json.Unmarshal(data, &transactions); err != nil {
+			return err
+		}
+		r.add(transactions)
+
+	case journalOpPut:
+		var transaction domain.Transaction
+		if err := json.Unmarshal(data, &transaction); err != nil {
+			return err
+		}
+
+		partition, position, exists := r.locate(transaction.ID)
+		if !exists {
+			return fmt.Errorf("transaction %s not found", transaction.ID)
+		}
+		partition.update(position, transaction)
+
+	case journalOpDelete:
+		var id string
+		if err := json.Unmarshal(data, &id); err != nil {
+			return err
+		}
+
+		if _, exists := r.owners[id]; !exists {
+			return fmt.Errorf("transaction %s not found", id)
+		}
+		r.delete(id)
+
+	case journalOpDeleteBatch:
+		var batchID string
+		if err := json.Unmarshal(data, &batchID); err != nil {
+			return err
+		}
+		r.deleteBatch(batchID)
+
+	case journalOpClear:
+		r.partitions = make(map[string]*userPartition)
+		r.owners = make(map[string]string)
+		r.batches = make(map[string]string)
+
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	return nil
+}
+
+func (r *transactionRepository) SnapshotState() any {
+	transactions, _ := r.GetAll()
+	return transactions
+}
+
+func (r *transactionRepository) RestoreState(data json.RawMessage) error {
+	var transactions []domain.Transaction
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.partitions = make(map[string]*userPartition)
+	r.owners = make(map[string]string)
+	r.batches = make(map[string]string)
+	r.add(transactions)
+	return nil
+}
+
+func (r *uploadBatchRepository) JournalName() string {
+	return uploadStore
+}
+
+func (r *uploadBatchRepository) ApplyRecord(op string, data json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch op {
+	case journalOpPut:
+		var batch domain.UploadBatch
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return err
+		}
+		r.batches[batch.ID] = &batch
+
+	case journalOpRollBack:
+		var id string
+		if err := json.Unmarshal(data, &id); err != nil {
+			return err
+		}
+
+		if _, exists := r.batches[id]; !exists {
+			return fmt.Errorf("upload %s not found", id)
+		}
+		r.rollBack(id)
+
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	return nil
+}
+
+func (r *uploadBatchRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batches := make([]*domain.UploadBatch, 0, len(r.batches))
+	for _, batch := range r.batches {
+		batches = append(batches, copyUploadBatch(batch))
+	}
+
+	return batches
+}
+
+func (r *uploadBatchRepository) RestoreState(data json.RawMessage) error {
+	var batches []*domain.UploadBatch
+	if err := json.Unmarshal(data, &batches); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches = make(map[string]*domain.UploadBatch, len(batches))
+	for _, batch := range batches {
+		r.batches[batch.ID] = batch
+	}
+
+	return nil
+}
+
+func (r *importProfileRepository) JournalName() string {
+	return importProfileStore
+}
+
+func (r *importProfileRepository) ApplyRecord(op string, data json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch op {
+	case journalOpPut:
+		var profile domain.ImportProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return err
+		}
+		r.profiles[profile.ID] = &profile
+
+	case journalOpDelete:
+		var id string
+		if err := json.Unmarshal(data, &id); err != nil {
+			return err
+		}
+		delete(r.profiles, id)
+
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	return nil
+}
+
+func (r *importProfileRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profiles := make([]*domain.ImportProfile, 0, len(r.profiles))
+	for _, profile := range r.profiles {
+		profiles = append(profiles, copyImportProfile(profile))
+	}
+
+	return profiles
+}
+
+func (r *importProfileRepository) RestoreState(data json.RawMessage) error {
+	var profiles []*domain.ImportProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.profiles = make(map[string]*domain.ImportProfile, len(profiles))
+	for _, profile := range profiles {
+		r.profiles[profile.ID] = profile
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) JournalName() string {
+	return apiKeyStore
+}
+
+func (r *apiKeyRepository) ApplyRecord(op string, data json.RawMessage) error {
+	if op != journalOpPut {
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	var key domain.APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[key.ID] = &key
+	return nil
+}
+
+func (r *apiKeyRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*domain.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, copyAPIKey(key))
+	}
+
+	return keys
+}
+
+func (r *apiKeyRepository) RestoreState(data json.RawMessage) error {
+	var keys []*domain.APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = make(map[string]*domain.APIKey, len(keys))
+	for _, key := range keys {
+		r.keys[key.ID] = key
+	}
+
+	return nil
+}
+
+func (r *loginAttemptRepository) JournalName() string {
+	return loginAttemptStore
+}
+
+func (r *loginAttemptRepository) ApplyRecord(op string, data json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch op {
+	case journalOpPut:
+		var attempt domain.LoginAttempt
+		if err := json.Unmarshal(data, &attempt); err != nil {
+			return err
+		}
+		r.attempts[attempt.Key] = &attempt
+
+	case journalOpDelete:
+		var key string
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+		delete(r.attempts, key)
+
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	return nil
+}
+
+func (r *loginAttemptRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attempts := make([]domain.LoginAttempt, 0, len(r.attempts))
+	for _, attempt := range r.attempts {
+		attempts = append(attempts, *attempt)
+	}
+
+	return attempts
+}
+
+func (r *loginAttemptRepository) RestoreState(data json.RawMessage) error {
+	var attempts []*domain.LoginAttempt
+	if err := json.Unmarshal(data, &attempts); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts = make(map[string]*domain.LoginAttempt, len(attempts))
+	for _, attempt := range attempts {
+		r.attempts[attempt.Key] = attempt
+	}
+
+	return nil
+}
+
+func (r *authEventRepository) JournalName() string {
+	return authEventStore
+}
+
+func (r *authEventRepository) ApplyRecord(op string, data json.RawMessage) error {
+	if op != journalOpAppend {
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	var event domain.AuthEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, &event)
+	return nil
+}
+
+// SnapshotState keeps the events in the order they were recorded.
+func (r *authEventRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := make([]domain.AuthEvent, 0, len(r.events))
+	for _, event := range r.events {
+		events = append(events, *event)
+	}
+
+	return events
+}
+
+func (r *authEventRepository) RestoreState(data json.RawMessage) error {
+	var events []*domain.AuthEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = events
+	return nil
+}
+
+func (r *passwordResetRepository) JournalName() string {
+	return passwordResetStore
+}
+
+func (r *passwordResetRepository) ApplyRecord(op string, data json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch op {
+	case journalOpPut:
+		var token domain.PasswordResetToken
+		if err := json.Unmarshal(data, &token); err != nil {
+			return err
+		}
+		r.tokens[token.ID] = &token
+
+	case journalOpDeleteByUser:
+		var userID string
+		if err := json.Unmarshal(data, &userID); err != nil {
+			return err
+		}
+		r.deleteByUserID(userID)
+
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+
+	return nil
+}
+
+func (r *passwordResetRepository) SnapshotState() any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tokens := make([]domain.PasswordResetToken, 0, len(r.tokens))
+	for _, token := range r.tokens {
+		tokens = append(tokens, *token)
+	}
+
+	return tokens
+}
+
+func (r *passwordResetRepository) RestoreState(data json.RawMessage) error {
+	var tokens []*domain.PasswordResetToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens = make(map[string]*domain.PasswordResetToken, len(tokens))
+	for _, token := range tokens {
+		r.tokens[token.ID] = token
+	}
+
+	return nil
+}
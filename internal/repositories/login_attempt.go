@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"fmt"
+	"sync"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
+)
+
+type loginAttemptRepository struct {
+	mu       sync.RWMutex
+	attempts map[string]*domain.LoginAttempt
+	journal  *journal.Journal
+}
+
+func NewLoginAttemptRepository() domain.LoginAttemptRepository {
+	return &loginAttemptRepository{
+		attempts: make(map[string]*domain.LoginAttempt),
+	}
+}
+
+func (r *loginAttemptRepository) FindByKey(key string) (*domain.LoginAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attempt, exists := r.attempts[key]
+	if !exists {
+		return nil, fmt.Errorf("login attempt not found")
+	}
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (r *loginAttemptRepository) Save(attempt *domain.LoginAttempt) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt.Key == "" {
+		return fmt.Errorf("key is required")
+	}
+
+	copied := *attempt
+	if err := r.journal.Append(loginAttemptStore, journalOpPut, &copied); err != nil {
+		return err
+	}
+
+	r.attempts[attempt.Key] = &copied
+	return nil
+}
+
+func (r *loginAttemptRepository) Delete(key string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.attempts[key]; !exists {
+		return nil
+	}
+
+	if err := r.journal.Append(loginAttemptStore, journalOpDelete, key); err != nil {
+		return err
+	}
+
+	delete(r.attempts, key)
+	return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"fmt"
+	"sync"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type passwordResetRepository struct {
+	mu      sync.RWMutex
+	tokens  map[string]*domain.PasswordResetToken
+	journal *journal.Journal
+}
+
+func NewPasswordResetRepository() domain.PasswordResetRepository {
+	return &passwordResetRepository{
+		tokens: make(map[string]*domain.PasswordResetToken),
+	}
+}
+
+func (r *passwordResetRepository) Save(token *domain.PasswordResetToken) (*domain.PasswordResetToken, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	if token.TokenHash == "" {
+		return nil, fmt.Errorf("token hash is required")
+	}
+
+	stored := *token
+	stored.ID = util.GenerateRandomID()
+
+	if err := r.journal.Append(passwordResetStore, journalOpPut, &stored); err != nil {
+		return nil, err
+	}
+
+	r.tokens[stored.ID] = &stored
+	token.ID = stored.ID
+	return token, nil
+}
+
+func (r *passwordResetRepository) Update(token *domain.PasswordResetToken) (*domain.PasswordResetToken, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tokens[token.ID]; !exists {
+		return nil, fmt.Errorf("password reset token not found")
+	}
+
+	stored := *token
+	if err := r.journal.Append(passwordResetStore, journalOpPut, &stored); err != nil {
+		return nil, err
+	}
+
+	r.tokens[token.ID] = &stored
+	return token, nil
+}
+
+func (r *passwordResetRepository) FindByHash(hash string) (*domain.PasswordResetToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+
+	return nil, fmt.Errorf("password reset token not found")
+}
+
+func (r *passwordResetRepository) DeleteByUserID(userID string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.journal.Append(passwordResetStore, journalOpDeleteByUser, userID); err != nil {
+		return err
+	}
+
+	r.deleteByUserID(userID)
+	return nil
+}
+
+func (r *passwordResetRepository) deleteByUserID(userID string) {
+	for id, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, id)
+		}
+	}
+}
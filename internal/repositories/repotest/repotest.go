@@ -0,0 +1,39 @@
+// Package repotest gives tests repositories backed by the storage driver named
+// in STORAGE_DRIVER, so the service tests can be run against every backend:
+//
+//	go test ./...
+//	STORAGE_DRIVER=sqlite go test ./...
+package repotest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"firstpersoncode/go-uploader/internal/config"
+	"firstpersoncode/go-uploader/internal/repositories"
+)
+
+// Open returns a fresh, empty storage that is closed when the test ends.
+func Open(tb testing.TB) *repositories.Storage {
+	tb.Helper()
+
+	return OpenDriver(tb, os.Getenv("STORAGE_DRIVER"))
+}
+
+func OpenDriver(tb testing.TB, driver string) *repositories.Storage {
+	tb.Helper()
+
+	storage, err := repositories.OpenStorage(config.Storage{
+		Driver:      driver,
+		SQLitePath:  filepath.Join(tb.TempDir(), "test.db"),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		tb.Fatalf("failed to open %s storage: %v", driver, err)
+	}
+
+	tb.Cleanup(func() { storage.Close() })
+
+	return storage
+}
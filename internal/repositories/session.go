@@ -2,15 +2,27 @@ package repositories
 
 import (
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
 	"firstpersoncode/go-uploader/internal/util"
 )
 
+// sessionTouch is the journal record of a Touch.
+type sessionTouch struct {
+	ID         string    `json:"id"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
 type sessionRepository struct {
 	mu       sync.RWMutex
 	sessions map[string]*domain.Session
+	journal  *journal.Journal
 }
 
 func NewSessionRepository() domain.SessionRepository {
@@ -20,6 +32,7 @@ func NewSessionRepository() domain.SessionRepository {
 }
 
 func (r *sessionRepository) Save(session *domain.Session) (*domain.Session, error) {
+	defer r.journal.Begin()()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -27,13 +40,74 @@ func (r *sessionRepository) Save(session *domain.Session) (*domain.Session, erro
 		return nil, fmt.Errorf("user ID is required")
 	}
 
-	session.ID = util.GenerateRandomID()
-	session.RefreshToken = util.GenerateRandomID()
+	stored := copySession(session)
+	stored.ID = util.GenerateRandomID()
+	stored.RefreshToken = util.GenerateRandomID()
+
+	if stored.FamilyID == "" {
+		stored.FamilyID = stored.ID
+	}
+
+	if err := r.journal.Append(sessionStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.sessions[stored.ID] = stored
+	session.ID, session.RefreshToken, session.FamilyID = stored.ID, stored.RefreshToken, stored.FamilyID
+	return session, nil
+}
+
+func (r *sessionRepository) Update(session *domain.Session) (*domain.Session, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[session.ID]; !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	stored := copySession(session)
+	if err := r.journal.Append(sessionStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
 
-	r.sessions[session.ID] = session
+	r.sessions[session.ID] = stored
 	return session, nil
 }
 
+func (r *sessionRepository) Touch(id string, lastSeenAt time.Time, ip string, userAgent string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[id]; !exists {
+		return fmt.Errorf("session not found")
+	}
+
+	touch := sessionTouch{ID: id, LastSeenAt: lastSeenAt, IP: ip, UserAgent: userAgent}
+	if err := r.journal.Append(sessionStore, journalOpTouch, touch); err != nil {
+		return err
+	}
+
+	r.touch(touch)
+	return nil
+}
+
+// touch replaces the session rather than changing it, as a snapshot being
+// written may still hold the stored one.
+func (r *sessionRepository) touch(touch sessionTouch) {
+	session, exists := r.sessions[touch.ID]
+	if !exists {
+		return
+	}
+
+	touched := *session
+	touched.LastSeenAt = touch.LastSeenAt
+	touched.IP = touch.IP
+	touched.UserAgent = touch.UserAgent
+	r.sessions[touch.ID] = &touched
+}
+
 func (r *sessionRepository) FindByID(id string) (*domain.Session, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -43,5 +117,103 @@ func (r *sessionRepository) FindByID(id string) (*domain.Session, error) {
 		return nil, fmt.Errorf("session not found")
 	}
 
-	return session, nil
+	return copySession(session), nil
+}
+
+func (r *sessionRepository) FindByRefreshToken(refreshToken string) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, session := range r.sessions {
+		if session.RefreshToken == refreshToken {
+			return copySession(session), nil
+		}
+	}
+
+	return nil, fmt.Errorf("session not found")
+}
+
+func (r *sessionRepository) FindByUserID(userID string) ([]*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*domain.Session, 0)
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepository) RevokeFamily(familyID string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.journal.Append(sessionStore, journalOpRevokeFamily, familyID); err != nil {
+		return err
+	}
+
+	r.revokeFamily(familyID)
+	return nil
+}
+
+// revokeFamily replaces the sessions rather than changing them, as touch
+// does.
+func (r *sessionRepository) revokeFamily(familyID string) {
+	for id, session := range r.sessions {
+		if session.FamilyID == familyID {
+			revoked := copySession(session)
+			revoked.Revoked = true
+			r.sessions[id] = revoked
+		}
+	}
+}
+
+func (r *sessionRepository) Delete(id string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[id]; !exists {
+		return fmt.Errorf("session not found")
+	}
+
+	if err := r.journal.Append(sessionStore, journalOpDelete, id); err != nil {
+		return err
+	}
+
+	delete(r.sessions, id)
+	return nil
+}
+
+func (r *sessionRepository) DeleteByUserID(userID string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.journal.Append(sessionStore, journalOpDeleteByUser, userID); err != nil {
+		return err
+	}
+
+	r.deleteByUserID(userID)
+	return nil
+}
+
+func (r *sessionRepository) deleteByUserID(userID string) {
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+}
+
+// copySession copies a session, so the stored session and the callers'
+// never share anything.
+func copySession(session *domain.Session) *domain.Session {
+	copied := *session
+	copied.Scopes = slices.Clone(session.Scopes)
+	return &copied
 }
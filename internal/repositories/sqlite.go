@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/config"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/migrations"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	StorageDriverMemory = "memory"
+	StorageDriverSQLite = "sqlite"
+)
+
+// Storage holds the repositories whose backend is picked by STORAGE_DRIVER.
+type Storage struct {
+	Users          domain.UserRepository
+	Sessions       domain.SessionRepository
+	Transactions   domain.TransactionRepository
+	Uploads        domain.UploadBatchRepository
+	Profiles       domain.ImportProfileRepository
+	APIKeys        domain.APIKeyRepository
+	LoginAttempts  domain.LoginAttemptRepository
+	AuthEvents     domain.AuthEventRepository
+	PasswordResets domain.PasswordResetRepository
+	db             *sql.DB
+	journal        *journal.Journal
+}
+
+func OpenStorage(cfg config.Storage) (*Storage, error) {
+	switch cfg.Driver {
+	case "", StorageDriverMemory:
+		if cfg.JournalDir != "" {
+			return openJournaledStorage(cfg)
+		}
+
+		transactions := newTransactionRepository(nil)
+		return &Storage{
+			Users:          NewUserRepository(),
+			Sessions:       NewSessionRepository(),
+			Transactions:   transactions,
+			Uploads:        newUploadBatchRepository(transactions, nil),
+			Profiles:       NewImportProfileRepository(),
+			APIKeys:        NewAPIKeyRepository(),
+			LoginAttempts:  NewLoginAttemptRepository(),
+			AuthEvents:     NewAuthEventRepository(),
+			PasswordResets: NewPasswordResetRepository(),
+		}, nil
+
+	case StorageDriverSQLite:
+		db, err := OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := prepareSchema(db, cfg.AutoMigrate); err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return &Storage{
+			Users:          NewSQLiteUserRepository(db),
+			Sessions:       NewSQLiteSessionRepository(db),
+			Transactions:   NewSQLiteTransactionRepository(db),
+			Uploads:        NewSQLiteUploadBatchRepository(db),
+			Profiles:       NewSQLiteImportProfileRepository(db),
+			APIKeys:        NewSQLiteAPIKeyRepository(db),
+			LoginAttempts:  NewSQLiteLoginAttemptRepository(db),
+			AuthEvents:     NewSQLiteAuthEventRepository(db),
+			PasswordResets: NewSQLitePasswordResetRepository(db),
+			db:             db,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+func (s *Storage) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+
+	return s.journal.Close()
+}
+
+// OpenSQLite opens the database at path. It doesn't touch the schema, see
+// the migrations package for that.
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer anyway. One connection avoids SQLITE_BUSY
+	// between our own goroutines and keeps ":memory:" databases in one piece.
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialise sqlite database: %v", err)
+		}
+	}
+
+	return db, nil
+}
+
+// prepareSchema makes sure the database is at the schema version this binary
+// expects, either by migrating it or by refusing to use it.
+func prepareSchema(db *sql.DB, autoMigrate bool) error {
+	if autoMigrate {
+		applied, err := migrations.Up(db)
+		for _, migration := range applied {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+		return err
+	}
+
+	pending, err := migrations.Pending(db)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date, %d migration(s) pending: run the \"migrate up\" command or set STORAGE_AUTO_MIGRATE=true", len(pending))
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// Times are stored as Unix nanoseconds, with 0 standing for the zero time,
+// and read back in UTC. Those before 1678 or after 2262 don't fit and are
+// clamped to the earliest or latest time that does: a filter from the year
+// 1000 still matches everything, rather than wrapping around.
+func toUnixNano(t time.Time) int64 {
+	switch {
+	case t.IsZero():
+		return 0
+	case t.Before(time.Unix(0, math.MinInt64)):
+		return math.MinInt64
+	case t.After(time.Unix(0, math.MaxInt64)):
+		return math.MaxInt64
+	}
+
+	return t.UnixNano()
+}
+
+func fromUnixNano(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos).UTC()
+}
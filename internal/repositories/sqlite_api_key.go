@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, hash, scopes, created_at, last_used_at, revoked"
+
+type sqliteAPIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAPIKeyRepository(db *sql.DB) domain.APIKeyRepository {
+	return &sqliteAPIKeyRepository{db: db}
+}
+
+func (r *sqliteAPIKeyRepository) Save(key *domain.APIKey) (*domain.APIKey, error) {
+	if key.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	if key.Hash == "" {
+		return nil, fmt.Errorf("key hash is required")
+	}
+
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	id := util.GenerateRandomID()
+
+	_, err = r.db.Exec(
+		"INSERT INTO api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, key.UserID, key.Name, key.Prefix, key.Hash, string(scopes), toUnixNano(key.CreatedAt), toUnixNano(key.LastUsedAt), key.Revoked,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = id
+	return key, nil
+}
+
+func (r *sqliteAPIKeyRepository) Update(key *domain.APIKey) (*domain.APIKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(
+		"UPDATE api_keys SET user_id = ?, name = ?, prefix = ?, hash = ?, scopes = ?, created_at = ?, last_used_at = ?, revoked = ? WHERE id = ?",
+		key.UserID, key.Name, key.Prefix, key.Hash, string(scopes), toUnixNano(key.CreatedAt), toUnixNano(key.LastUsedAt), key.Revoked, key.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	return key, nil
+}
+
+func (r *sqliteAPIKeyRepository) FindByID(id string) (*domain.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+}
+
+func (r *sqliteAPIKeyRepository) FindByHash(hash string) (*domain.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = ?", hash))
+}
+
+func (r *sqliteAPIKeyRepository) FindByUserID(userID string) ([]*domain.APIKey, error) {
+	rows, err := r.db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*domain.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func scanAPIKey(row rowScanner) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes string
+	var createdAt, lastUsedAt int64
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &createdAt, &lastUsedAt, &key.Revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("api key not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("invalid scopes for api key %s: %v", key.ID, err)
+	}
+
+	key.CreatedAt = fromUnixNano(createdAt)
+	key.LastUsedAt = fromUnixNano(lastUsedAt)
+
+	return &key, nil
+}
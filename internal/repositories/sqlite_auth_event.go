@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type sqliteAuthEventRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuthEventRepository(db *sql.DB) domain.AuthEventRepository {
+	return &sqliteAuthEventRepository{db: db}
+}
+
+func (r *sqliteAuthEventRepository) Save(event *domain.AuthEvent) (*domain.AuthEvent, error) {
+	id := util.GenerateRandomID()
+
+	_, err := r.db.Exec(
+		"INSERT INTO auth_events (id, type, user_id, username, ip, user_agent, reason, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, event.Type, event.UserID, event.Username, event.IP, event.UserAgent, event.Reason, toUnixNano(event.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	event.ID = id
+	return event, nil
+}
+
+// FindByUsername returns the events in the order they were recorded.
+func (r *sqliteAuthEventRepository) FindByUsername(username string) ([]*domain.AuthEvent, error) {
+	rows, err := r.db.Query(
+		"SELECT id, type, user_id, username, ip, user_agent, reason, created_at FROM auth_events WHERE username = ? ORDER BY rowid",
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*domain.AuthEvent, 0)
+	for rows.Next() {
+		var event domain.AuthEvent
+		var createdAt int64
+
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.Username, &event.IP, &event.UserAgent, &event.Reason, &createdAt); err != nil {
+			return nil, err
+		}
+
+		event.CreatedAt = fromUnixNano(createdAt)
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const importProfileColumns = "id, user_id, name, delimiter, quote, skip_rows, columns, defaults, date_layout, time_zone, " +
+	"decimal_separator, thousands_separator, decimal_places, sign_convention, created_at, updated_at"
+
+type sqliteImportProfileRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteImportProfileRepository(db *sql.DB) domain.ImportProfileRepository {
+	return &sqliteImportProfileRepository{db: db}
+}
+
+func (r *sqliteImportProfileRepository) Save(profile *domain.ImportProfile) (*domain.ImportProfile, error) {
+	columns, defaults, err := marshalImportProfileMaps(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	id := util.GenerateRandomID()
+
+	_, err = r.db.Exec(
+		"INSERT INTO import_profiles ("+importProfileColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, profile.UserID, profile.Name, profile.Delimiter, profile.Quote, profile.SkipRows, columns, defaults,
+		profile.DateLayout, profile.TimeZone, profile.DecimalSeparator, profile.ThousandsSeparator, profile.DecimalPlaces,
+		profile.SignConvention, toUnixNano(profile.CreatedAt), toUnixNano(profile.UpdatedAt),
+	)
+	if isUniqueViolation(err) {
+		return nil, domain.ErrImportProfileNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profile.ID = id
+	return profile, nil
+}
+
+func (r *sqliteImportProfileRepository) Update(profile *domain.ImportProfile) (*domain.ImportProfile, error) {
+	columns, defaults, err := marshalImportProfileMaps(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE import_profiles SET user_id = ?, name = ?, delimiter = ?, quote = ?, skip_rows = ?, columns = ?,
+			defaults = ?, date_layout = ?, time_zone = ?, decimal_separator = ?, thousands_separator = ?,
+			decimal_places = ?, sign_convention = ?, created_at = ?, updated_at = ? WHERE id = ?`,
+		profile.UserID, profile.Name, profile.Delimiter, profile.Quote, profile.SkipRows, columns,
+		defaults, profile.DateLayout, profile.TimeZone, profile.DecimalSeparator, profile.ThousandsSeparator,
+		profile.DecimalPlaces, profile.SignConvention, toUnixNano(profile.CreatedAt), toUnixNano(profile.UpdatedAt), profile.ID,
+	)
+	if isUniqueViolation(err) {
+		return nil, domain.ErrImportProfileNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, domain.ErrImportProfileNotFound
+	}
+
+	return profile, nil
+}
+
+func (r *sqliteImportProfileRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM import_profiles WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return domain.ErrImportProfileNotFound
+	}
+
+	return nil
+}
+
+func (r *sqliteImportProfileRepository) FindByID(id string) (*domain.ImportProfile, error) {
+	return scanImportProfile(r.db.QueryRow("SELECT "+importProfileColumns+" FROM import_profiles WHERE id = ?", id))
+}
+
+func (r *sqliteImportProfileRepository) FindByUserID(userID string) ([]*domain.ImportProfile, error) {
+	rows, err := r.db.Query("SELECT "+importProfileColumns+" FROM import_profiles WHERE user_id = ? ORDER BY name COLLATE NOCASE", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make([]*domain.ImportProfile, 0)
+	for rows.Next() {
+		profile, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// The column mapping and defaults are stored as JSON objects.
+func marshalImportProfileMaps(profile *domain.ImportProfile) (string, string, error) {
+	columns, err := json.Marshal(profile.Columns)
+	if err != nil {
+		return "", "", err
+	}
+
+	defaults, err := json.Marshal(profile.Defaults)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(columns), string(defaults), nil
+}
+
+func scanImportProfile(row rowScanner) (*domain.ImportProfile, error) {
+	var profile domain.ImportProfile
+	var columns, defaults string
+	var createdAt, updatedAt int64
+
+	err := row.Scan(
+		&profile.ID, &profile.UserID, &profile.Name, &profile.Delimiter, &profile.Quote, &profile.SkipRows,
+		&columns, &defaults, &profile.DateLayout, &profile.TimeZone, &profile.DecimalSeparator, &profile.ThousandsSeparator,
+		&profile.DecimalPlaces, &profile.SignConvention, &createdAt, &updatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrImportProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(columns), &profile.Columns); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(defaults), &profile.Defaults); err != nil {
+		return nil, err
+	}
+
+	profile.CreatedAt = fromUnixNano(createdAt)
+	profile.UpdatedAt = fromUnixNano(updatedAt)
+	return &profile, nil
+}
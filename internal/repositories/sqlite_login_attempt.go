@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+)
+
+type sqliteLoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteLoginAttemptRepository(db *sql.DB) domain.LoginAttemptRepository {
+	return &sqliteLoginAttemptRepository{db: db}
+}
+
+func (r *sqliteLoginAttemptRepository) FindByKey(key string) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	var lastFailure, lockedUntil int64
+
+	err := r.db.QueryRow("SELECT key, failures, last_failure, locked_until FROM login_attempts WHERE key = ?", key).
+		Scan(&attempt.Key, &attempt.Failures, &lastFailure, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("login attempt not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attempt.LastFailure = fromUnixNano(lastFailure)
+	attempt.LockedUntil = fromUnixNano(lockedUntil)
+
+	return &attempt, nil
+}
+
+func (r *sqliteLoginAttemptRepository) Save(attempt *domain.LoginAttempt) error {
+	if attempt.Key == "" {
+		return fmt.Errorf("key is required")
+	}
+
+	_, err := r.db.Exec(
+		`INSERT INTO login_attempts (key, failures, last_failure, locked_until) VALUES (?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET failures = excluded.failures, last_failure = excluded.last_failure,
+			locked_until = excluded.locked_until`,
+		attempt.Key, attempt.Failures, toUnixNano(attempt.LastFailure), toUnixNano(attempt.LockedUntil),
+	)
+	return err
+}
+
+func (r *sqliteLoginAttemptRepository) Delete(key string) error {
+	_, err := r.db.Exec("DELETE FROM login_attempts WHERE key = ?", key)
+	return err
+}
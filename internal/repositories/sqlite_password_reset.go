@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type sqlitePasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewSQLitePasswordResetRepository(db *sql.DB) domain.PasswordResetRepository {
+	return &sqlitePasswordResetRepository{db: db}
+}
+
+func (r *sqlitePasswordResetRepository) Save(token *domain.PasswordResetToken) (*domain.PasswordResetToken, error) {
+	if token.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	if token.TokenHash == "" {
+		return nil, fmt.Errorf("token hash is required")
+	}
+
+	id := util.GenerateRandomID()
+
+	_, err := r.db.Exec(
+		"INSERT INTO password_reset_tokens (id, user_id, token_hash, created_at, expires_at, used) VALUES (?, ?, ?, ?, ?, ?)",
+		id, token.UserID, token.TokenHash, toUnixNano(token.CreatedAt), toUnixNano(token.ExpiresAt), token.Used,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = id
+	return token, nil
+}
+
+func (r *sqlitePasswordResetRepository) Update(token *domain.PasswordResetToken) (*domain.PasswordResetToken, error) {
+	result, err := r.db.Exec(
+		"UPDATE password_reset_tokens SET user_id = ?, token_hash = ?, created_at = ?, expires_at = ?, used = ? WHERE id = ?",
+		token.UserID, token.TokenHash, toUnixNano(token.CreatedAt), toUnixNano(token.ExpiresAt), token.Used, token.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, fmt.Errorf("password reset token not found")
+	}
+
+	return token, nil
+}
+
+func (r *sqlitePasswordResetRepository) FindByHash(hash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	var createdAt, expiresAt int64
+
+	err := r.db.QueryRow("SELECT id, user_id, token_hash, created_at, expires_at, used FROM password_reset_tokens WHERE token_hash = ?", hash).
+		Scan(&token.ID, &token.UserID, &token.TokenHash, &createdAt, &expiresAt, &token.Used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("password reset token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.CreatedAt = fromUnixNano(createdAt)
+	token.ExpiresAt = fromUnixNano(expiresAt)
+
+	return &token, nil
+}
+
+func (r *sqlitePasswordResetRepository) DeleteByUserID(userID string) error {
+	_, err := r.db.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const sessionColumns = "id, user_id, family_id, refresh_token, refresh_expiry, revoked, created_at, last_seen_at, ip, user_agent"
+
+type sqliteSessionRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteSessionRepository(db *sql.DB) domain.SessionRepository {
+	return &sqliteSessionRepository{db: db}
+}
+
+func (r *sqliteSessionRepository) Save(session *domain.Session) (*domain.Session, error) {
+	if session.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	saved := *session
+	saved.ID = util.GenerateRandomID()
+	saved.RefreshToken = util.GenerateRandomID()
+
+	if saved.FamilyID == "" {
+		saved.FamilyID = saved.ID
+	}
+
+	_, err := r.db.Exec(
+		"INSERT INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		saved.ID, saved.UserID, saved.FamilyID, saved.RefreshToken, toUnixNano(saved.RefreshExpiry),
+		saved.Revoked, toUnixNano(saved.CreatedAt), toUnixNano(saved.LastSeenAt), saved.IP, saved.UserAgent,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	*session = saved
+	return session, nil
+}
+
+func (r *sqliteSessionRepository) Update(session *domain.Session) (*domain.Session, error) {
+	result, err := r.db.Exec(
+		`UPDATE sessions SET user_id = ?, family_id = ?, refresh_token = ?, refresh_expiry = ?, revoked = ?,
+			created_at = ?, last_seen_at = ?, ip = ?, user_agent = ? WHERE id = ?`,
+		session.UserID, session.FamilyID, session.RefreshToken, toUnixNano(session.RefreshExpiry), session.Revoked,
+		toUnixNano(session.CreatedAt), toUnixNano(session.LastSeenAt), session.IP, session.UserAgent, session.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	return session, nil
+}
+
+func (r *sqliteSessionRepository) Touch(id string, lastSeenAt time.Time, ip string, userAgent string) error {
+	result, err := r.db.Exec(
+		"UPDATE sessions SET last_seen_at = ?, ip = ?, user_agent = ? WHERE id = ?",
+		toUnixNano(lastSeenAt), ip, userAgent, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	return nil
+}
+
+func (r *sqliteSessionRepository) FindByID(id string) (*domain.Session, error) {
+	return scanSession(r.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+}
+
+func (r *sqliteSessionRepository) FindByRefreshToken(refreshToken string) (*domain.Session, error) {
+	return scanSession(r.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE refresh_token = ?", refreshToken))
+}
+
+func (r *sqliteSessionRepository) FindByUserID(userID string) ([]*domain.Session, error) {
+	rows, err := r.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*domain.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *sqliteSessionRepository) RevokeFamily(familyID string) error {
+	_, err := r.db.Exec("UPDATE sessions SET revoked = 1 WHERE family_id = ?", familyID)
+	return err
+}
+
+func (r *sqliteSessionRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM sessions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	return nil
+}
+
+func (r *sqliteSessionRepository) DeleteByUserID(userID string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	return err
+}
+
+func scanSession(row rowScanner) (*domain.Session, error) {
+	var session domain.Session
+	var refreshExpiry, createdAt, lastSeenAt int64
+
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.FamilyID, &session.RefreshToken, &refreshExpiry,
+		&session.Revoked, &createdAt, &lastSeenAt, &session.IP, &session.UserAgent,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session.RefreshExpiry = fromUnixNano(refreshExpiry)
+	session.CreatedAt = fromUnixNano(createdAt)
+	session.LastSeenAt = fromUnixNano(lastSeenAt)
+
+	return &session, nil
+}
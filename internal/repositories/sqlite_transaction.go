@@ -0,0 +1,430 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const transactionColumns = "id, user_id, timestamp, name, type, amount, status, description, batch_id, external_id, account"
+
+// transactionSortColumns maps dto_transaction.SortableFields to the columns
+// spliced into ORDER BY.
+var transactionSortColumns = map[string]string{
+	"timestamp": "timestamp",
+	"name":      "name",
+	"amount":    "amount",
+	"type":      "type",
+	"status":    "status",
+}
+
+type sqliteTransactionRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteTransactionRepository(db *sql.DB) domain.TransactionRepository {
+	return &sqliteTransactionRepository{db: db}
+}
+
+func (r *sqliteTransactionRepository) SaveAll(transactions []domain.Transaction) error {
+	if err := validateTransactions(transactions); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare("INSERT INTO transactions (" + transactionColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for index := range transactions {
+		transaction := &transactions[index]
+		transaction.ID = util.GenerateRandomID()
+
+		if _, err := statement.Exec(
+			transaction.ID, transaction.UserID, toUnixNano(transaction.Timestamp), transaction.Name, transaction.Type,
+			transaction.Amount, transaction.Status, transaction.Description, transaction.BatchID, transaction.ExternalID,
+			transaction.Account,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteTransactionRepository) FindByID(id string) (*domain.Transaction, error) {
+	transactions, err := r.query("SELECT "+transactionColumns+" FROM transactions WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transactions) == 0 {
+		return nil, domain.ErrTransactionNotFound
+	}
+
+	return &transactions[0], nil
+}
+
+func (r *sqliteTransactionRepository) Update(transaction *domain.Transaction) (*domain.Transaction, error) {
+	if err := transaction.Validate(); err != nil {
+		return nil, err
+	}
+
+	var userID, batchID, externalID, account string
+	err := r.db.QueryRow("SELECT user_id, batch_id, external_id, account FROM transactions WHERE id = ?", transaction.ID).Scan(&userID, &batchID, &externalID, &account)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if userID != transaction.UserID {
+		return nil, fmt.Errorf("transaction owner can't be changed")
+	}
+
+	if _, err := r.db.Exec(
+		"UPDATE transactions SET timestamp = ?, name = ?, type = ?, amount = ?, status = ?, description = ? WHERE id = ?",
+		toUnixNano(transaction.Timestamp), transaction.Name, transaction.Type, transaction.Amount,
+		transaction.Status, transaction.Description, transaction.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	updated := *transaction
+	updated.BatchID = batchID
+	updated.ExternalID = externalID
+	updated.Account = account
+	return &updated, nil
+}
+
+func (r *sqliteTransactionRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM transactions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return domain.ErrTransactionNotFound
+	}
+
+	return nil
+}
+
+func (r *sqliteTransactionRepository) DeleteByBatchID(batchID string) (int, error) {
+	// Rows stored before uploads were tracked have an empty batch ID, and
+	// don't form a batch.
+	if batchID == "" {
+		return 0, nil
+	}
+
+	result, err := r.db.Exec("DELETE FROM transactions WHERE batch_id = ?", batchID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+func (r *sqliteTransactionRepository) GetAll() ([]domain.Transaction, error) {
+	transactions, err := r.query("SELECT " + transactionColumns + " FROM transactions ORDER BY rowid")
+	if transactions == nil && err == nil {
+		transactions = make([]domain.Transaction, 0)
+	}
+
+	return transactions, err
+}
+
+func (r *sqliteTransactionRepository) GetAllByUserID(userID string) ([]domain.Transaction, error) {
+	return r.query("SELECT "+transactionColumns+" FROM transactions WHERE user_id = ? ORDER BY rowid", userID)
+}
+
+func (r *sqliteTransactionRepository) CountFingerprints(userID string, transactions []domain.Transaction) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(transactions) == 0 {
+		return counts, nil
+	}
+
+	wanted := make(map[string]bool, len(transactions))
+	for index := range transactions {
+		wanted[transactions[index].Fingerprint()] = true
+	}
+
+	// Duplicates share their timestamp, so only the stored rows within the
+	// time span of transactions need fingerprinting.
+	where, args := transactionWhere(fingerprintSpan(userID, transactions))
+
+	stored, err := r.query("SELECT "+transactionColumns+" FROM transactions "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for index := range stored {
+		if fingerprint := stored[index].Fingerprint(); wanted[fingerprint] {
+			counts[fingerprint]++
+		}
+	}
+
+	return counts, nil
+}
+
+func (r *sqliteTransactionRepository) Search(filter domain.TransactionFilter, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, cursor *domain.TransactionCursor) ([]domain.Transaction, int, error) {
+	keys, err := sorting.Keys()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+
+	if pagination.Limit < 1 {
+		pagination.Limit = 10
+	}
+
+	where, filterArgs := transactionWhere(filter)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM transactions "+where, filterArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if cursor == nil {
+		start := (pagination.Page - 1) * pagination.Limit
+		if start >= total {
+			return make([]domain.Transaction, 0), total, nil
+		}
+
+		orderBy, err := transactionOrderBy(keys, false)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		page, err := r.query(
+			"SELECT "+transactionColumns+" FROM transactions "+where+" ORDER BY "+orderBy+" LIMIT ? OFFSET ?",
+			append(filterArgs, pagination.Limit, start)...,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return page, total, nil
+	}
+
+	// A backward page is read in reverse order, starting next to the anchor,
+	// and flipped back afterwards.
+	orderBy, err := transactionOrderBy(keys, cursor.Backward)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	after, afterArgs := transactionKeysetFilter(keys, &cursor.Anchor, cursor.Backward)
+
+	page, err := r.query(
+		"SELECT "+transactionColumns+" FROM transactions "+where+" AND "+after+" ORDER BY "+orderBy+" LIMIT ?",
+		append(append(filterArgs, afterArgs...), pagination.Limit)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page == nil {
+		page = make([]domain.Transaction, 0)
+	}
+
+	if cursor.Backward {
+		slices.Reverse(page)
+	}
+
+	return page, total, nil
+}
+
+// transactionWhere builds the WHERE clause selecting filter's rows. LIKE and
+// NOCASE ignore ASCII case only, which the memory repository matches.
+func transactionWhere(filter domain.TransactionFilter) (string, []any) {
+	conditions := []string{"user_id = ?"}
+	args := []any{filter.UserID}
+
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status IN ("+placeholders(len(filter.Statuses))+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "type IN ("+placeholders(len(filter.Types))+")")
+		for _, transactionType := range filter.Types {
+			args = append(args, transactionType)
+		}
+	}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, toUnixNano(filter.From))
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, toUnixNano(filter.To))
+	}
+
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "amount >= ?")
+		args = append(args, *filter.MinAmount)
+	}
+
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "amount <= ?")
+		args = append(args, *filter.MaxAmount)
+	}
+
+	if filter.Name != "" {
+		conditions = append(conditions, "name = ? COLLATE NOCASE")
+		args = append(args, filter.Name)
+	}
+
+	for _, term := range filter.DescriptionTerms {
+		conditions = append(conditions, `description LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(term)+"%")
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+// transactionOrderBy builds an ORDER BY clause from keys, reversing every
+// direction when reverse is set. The id tie-breaker keeps pages stable when
+// many rows share the sort values.
+func transactionOrderBy(keys []dto_transaction.SortKey, reverse bool) (string, error) {
+	terms := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		column, ok := transactionSortColumns[key.Field]
+		if !ok {
+			return "", fmt.Errorf("invalid sortBy field: %s", key.Field)
+		}
+
+		if key.Descending != reverse {
+			column += " DESC"
+		}
+		terms = append(terms, column)
+	}
+
+	tieBreaker := "id"
+	if reverse {
+		tieBreaker += " DESC"
+	}
+
+	return strings.Join(append(terms, tieBreaker), ", "), nil
+}
+
+// transactionKeysetFilter builds a WHERE condition matching the rows that
+// sort after anchor by keys and then id, or before it when reverse is set.
+// For keys "a,-b" it reads
+//
+//	(a > ?) OR (a = ? AND b < ?) OR (a = ? AND b = ? AND id > ?)
+//
+// The keys must have been checked by transactionOrderBy.
+func transactionKeysetFilter(keys []dto_transaction.SortKey, anchor *domain.Transaction, reverse bool) (string, []any) {
+	type term struct {
+		column     string
+		descending bool
+		value      any
+	}
+
+	terms := make([]term, 0, len(keys)+1)
+	for _, key := range keys {
+		terms = append(terms, term{transactionSortColumns[key.Field], key.Descending, transactionSortValue(anchor, key.Field)})
+	}
+	terms = append(terms, term{"id", false, anchor.ID})
+
+	var alternatives []string
+	var args []any
+
+	for index, last := range terms {
+		conditions := make([]string, 0, index+1)
+		for _, equal := range terms[:index] {
+			conditions = append(conditions, equal.column+" = ?")
+			args = append(args, equal.value)
+		}
+
+		operator := " > ?"
+		if last.descending != reverse {
+			operator = " < ?"
+		}
+		conditions = append(conditions, last.column+operator)
+		args = append(args, last.value)
+
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func transactionSortValue(transaction *domain.Transaction, field string) any {
+	switch field {
+	case "timestamp":
+		return toUnixNano(transaction.Timestamp)
+	case "name":
+		return transaction.Name
+	case "type":
+		return transaction.Type
+	case "amount":
+		return transaction.Amount
+	case "status":
+		return transaction.Status
+	}
+
+	return nil
+}
+
+func (r *sqliteTransactionRepository) Clear() error {
+	_, err := r.db.Exec("DELETE FROM transactions")
+	return err
+}
+
+func (r *sqliteTransactionRepository) query(query string, args ...any) ([]domain.Transaction, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []domain.Transaction
+	for rows.Next() {
+		var transaction domain.Transaction
+		var timestamp int64
+
+		if err := rows.Scan(
+			&transaction.ID, &transaction.UserID, &timestamp, &transaction.Name, &transaction.Type,
+			&transaction.Amount, &transaction.Status, &transaction.Description, &transaction.BatchID, &transaction.ExternalID,
+			&transaction.Account,
+		); err != nil {
+			return nil, err
+		}
+
+		transaction.Timestamp = fromUnixNano(timestamp)
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const uploadBatchColumns = "id, user_id, filename, checksum, row_count, uploaded_at, status, idempotency_key, skipped_rows, failed_rows, duplicate_of, " +
+	"balances"
+
+type sqliteUploadBatchRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteUploadBatchRepository(db *sql.DB) domain.UploadBatchRepository {
+	return &sqliteUploadBatchRepository{db: db}
+}
+
+func (r *sqliteUploadBatchRepository) Save(batch *domain.UploadBatch) (*domain.UploadBatch, error) {
+	if batch.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	balances, err := json.Marshal(batch.Balances)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := *batch
+	saved.ID = util.GenerateRandomID()
+
+	_, err = r.db.Exec(
+		"INSERT INTO upload_batches ("+uploadBatchColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		saved.ID, saved.UserID, saved.Filename, saved.Checksum, saved.RowCount, toUnixNano(saved.UploadedAt), saved.Status,
+		saved.IdempotencyKey, saved.SkippedRows, saved.FailedRows, saved.DuplicateOf, string(balances),
+	)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: it was used by another upload", domain.ErrIdempotencyKeyConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	*batch = saved
+	return batch, nil
+}
+
+func (r *sqliteUploadBatchRepository) Update(batch *domain.UploadBatch) (*domain.UploadBatch, error) {
+	balances, err := json.Marshal(batch.Balances)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE upload_batches SET user_id = ?, filename = ?, checksum = ?, row_count = ?, uploaded_at = ?, status = ?,
+			idempotency_key = ?, skipped_rows = ?, failed_rows = ?, duplicate_of = ?, balances = ?
+			WHERE id = ?`,
+		batch.UserID, batch.Filename, batch.Checksum, batch.RowCount, toUnixNano(batch.UploadedAt), batch.Status,
+		batch.IdempotencyKey, batch.SkippedRows, batch.FailedRows, batch.DuplicateOf, string(balances), batch.ID,
+	)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: it was used by another upload", domain.ErrIdempotencyKeyConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	return batch, nil
+}
+
+func (r *sqliteUploadBatchRepository) RollBack(id string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE upload_batches SET status = ? WHERE id = ?", domain.UploadStatusRolledBack, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, domain.ErrUploadNotFound
+	}
+
+	result, err = tx.Exec("DELETE FROM transactions WHERE batch_id = ?", id)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(deleted), nil
+}
+
+func (r *sqliteUploadBatchRepository) FindByID(id string) (*domain.UploadBatch, error) {
+	return scanUploadBatch(r.db.QueryRow("SELECT "+uploadBatchColumns+" FROM upload_batches WHERE id = ?", id))
+}
+
+func (r *sqliteUploadBatchRepository) FindByIdempotencyKey(userID string, key string) (*domain.UploadBatch, error) {
+	if key == "" {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	return scanUploadBatch(r.db.QueryRow("SELECT "+uploadBatchColumns+" FROM upload_batches WHERE user_id = ? AND idempotency_key = ?", userID, key))
+}
+
+func (r *sqliteUploadBatchRepository) FindByChecksum(userID string, checksum string) ([]*domain.UploadBatch, error) {
+	rows, err := r.db.Query(
+		"SELECT "+uploadBatchColumns+" FROM upload_batches WHERE user_id = ? AND checksum = ? ORDER BY uploaded_at DESC, id",
+		userID, checksum,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make([]*domain.UploadBatch, 0)
+	for rows.Next() {
+		batch, err := scanUploadBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches, rows.Err()
+}
+
+func (r *sqliteUploadBatchRepository) FindByUserID(userID string, page int, limit int) ([]*domain.UploadBatch, int, error) {
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM upload_batches WHERE user_id = ?", userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		"SELECT "+uploadBatchColumns+" FROM upload_batches WHERE user_id = ? ORDER BY uploaded_at DESC, id LIMIT ? OFFSET ?",
+		userID, limit, (page-1)*limit,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	batches := make([]*domain.UploadBatch, 0, limit)
+	for rows.Next() {
+		batch, err := scanUploadBatch(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches, total, rows.Err()
+}
+
+func scanUploadBatch(row rowScanner) (*domain.UploadBatch, error) {
+	var batch domain.UploadBatch
+	var uploadedAt int64
+	var balances string
+
+	err := row.Scan(
+		&batch.ID, &batch.UserID, &batch.Filename, &batch.Checksum, &batch.RowCount, &uploadedAt, &batch.Status,
+		&batch.IdempotencyKey, &batch.SkippedRows, &batch.FailedRows, &batch.DuplicateOf, &balances,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(balances), &batch.Balances); err != nil {
+		return nil, fmt.Errorf("invalid balances for upload %s: %v", batch.ID, err)
+	}
+
+	batch.UploadedAt = fromUnixNano(uploadedAt)
+
+	return &batch, nil
+}
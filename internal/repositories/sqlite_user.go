@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+const userColumns = "id, username, password, role, disabled, mfa_secret, mfa_enabled, mfa_last_used_step, mfa_recovery_codes"
+
+type sqliteUserRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserRepository(db *sql.DB) domain.UserRepository {
+	return &sqliteUserRepository{db: db}
+}
+
+func (r *sqliteUserRepository) Save(user *domain.User) (*domain.User, error) {
+	if user.Username == "" {
+		return nil, fmt.Errorf("user username is required")
+	}
+
+	if user.Password == "" {
+		return nil, fmt.Errorf("user password is required")
+	}
+
+	recoveryCodes, err := json.Marshal(user.MFARecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	id := util.GenerateRandomID()
+
+	_, err = r.db.Exec(
+		"INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, user.Username, user.Password, user.Role, user.Disabled, user.MFASecret, user.MFAEnabled, user.MFALastUsedStep, string(recoveryCodes),
+	)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("username already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID = id
+	return user, nil
+}
+
+func (r *sqliteUserRepository) Update(user *domain.User) (*domain.User, error) {
+	recoveryCodes, err := json.Marshal(user.MFARecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE users SET username = ?, password = ?, role = ?, disabled = ?, mfa_secret = ?,
+			mfa_enabled = ?, mfa_last_used_step = ?, mfa_recovery_codes = ? WHERE id = ?`,
+		user.Username, user.Password, user.Role, user.Disabled, user.MFASecret,
+		user.MFAEnabled, user.MFALastUsedStep, string(recoveryCodes), user.ID,
+	)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("username already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (r *sqliteUserRepository) FindByID(id string) (*domain.User, error) {
+	return scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+}
+
+func (r *sqliteUserRepository) FindByUsername(username string) (*domain.User, error) {
+	return scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+}
+
+func (r *sqliteUserRepository) FindAll(page int, limit int) ([]*domain.User, int, error) {
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query("SELECT "+userColumns+" FROM users ORDER BY username LIMIT ? OFFSET ?", limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0, limit)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	var user domain.User
+	var recoveryCodes string
+
+	err := row.Scan(
+		&user.ID, &user.Username, &user.Password, &user.Role, &user.Disabled,
+		&user.MFASecret, &user.MFAEnabled, &user.MFALastUsedStep, &recoveryCodes,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(recoveryCodes), &user.MFARecoveryCodes); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
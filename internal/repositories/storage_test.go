@@ -0,0 +1,1094 @@
+package repositories_test
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"firstpersoncode/go-uploader/domain"
+	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"firstpersoncode/go-uploader/internal/config"
+	"firstpersoncode/go-uploader/internal/repositories"
+	"firstpersoncode/go-uploader/internal/repositories/repotest"
+)
+
+var backends = map[string]func(t *testing.T) *repositories.Storage{
+	"memory": func(t *testing.T) *repositories.Storage {
+		return repotest.OpenDriver(t, repositories.StorageDriverMemory)
+	},
+	"journal": func(t *testing.T) *repositories.Storage {
+		return openJournaled(t, t.TempDir())
+	},
+	"sqlite": func(t *testing.T) *repositories.Storage {
+		return repotest.OpenDriver(t, repositories.StorageDriverSQLite)
+	},
+}
+
+func openJournaled(t *testing.T, dir string) *repositories.Storage {
+	t.Helper()
+
+	storage, err := repositories.OpenStorage(config.Storage{Driver: repositories.StorageDriverMemory, JournalDir: dir, JournalCompactEvery: 5})
+	if err != nil {
+		t.Fatalf("failed to open journaled storage: %v", err)
+	}
+
+	return storage
+}
+
+func TestStorage_Users(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			users := open(t).Users
+
+			for _, username := range []string{"carol", "alice", "bob"} {
+				if _, err := users.Save(&domain.User{Username: username, Password: "hash"}); err != nil {
+					t.Fatalf("failed to save user: %v", err)
+				}
+			}
+
+			if _, err := users.Save(&domain.User{Username: "alice", Password: "hash"}); err == nil || err.Error() != "username already exists" {
+				t.Errorf("expected 'username already exists' error, got %v", err)
+			}
+
+			alice, err := users.FindByUsername("alice")
+			if err != nil {
+				t.Fatalf("failed to find user: %v", err)
+			}
+
+			updated := *alice
+			updated.Role = domain.RoleAdmin
+			updated.MFARecoveryCodes = []string{"code-1", "code-2"}
+			if _, err := users.Update(&updated); err != nil {
+				t.Fatalf("failed to update user: %v", err)
+			}
+
+			found, _ := users.FindByID(alice.ID)
+			if found.Role != domain.RoleAdmin || len(found.MFARecoveryCodes) != 2 {
+				t.Errorf("expected update to be stored, got %+v", found)
+			}
+
+			page, total, err := users.FindAll(2, 2)
+			if err != nil {
+				t.Fatalf("failed to list users: %v", err)
+			}
+
+			if total != 3 || len(page) != 1 || page[0].Username != "carol" {
+				t.Errorf("expected carol alone on page 2 of 3 users, got %d users of %d", len(page), total)
+			}
+
+			if _, err := users.FindByID("missing"); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("expected ErrUserNotFound for an unknown user, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStorage_Sessions(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			sessions := open(t).Sessions
+
+			expiry := time.Now().Add(time.Hour)
+			first, err := sessions.Save(&domain.Session{UserID: "user-1", RefreshExpiry: expiry, CreatedAt: time.Now()})
+			if err != nil {
+				t.Fatalf("failed to save session: %v", err)
+			}
+
+			second, _ := sessions.Save(&domain.Session{UserID: "user-1", FamilyID: first.FamilyID, CreatedAt: time.Now()})
+			other, _ := sessions.Save(&domain.Session{UserID: "user-2", CreatedAt: time.Now()})
+
+			found, err := sessions.FindByRefreshToken(first.RefreshToken)
+			if err != nil || found.ID != first.ID {
+				t.Fatalf("expected to find the session by refresh token, got %v", err)
+			}
+
+			if !found.RefreshExpiry.Equal(expiry) {
+				t.Errorf("expected refresh expiry %v, got %v", expiry, found.RefreshExpiry)
+			}
+
+			if err := sessions.RevokeFamily(first.FamilyID); err != nil {
+				t.Fatalf("failed to revoke family: %v", err)
+			}
+
+			// Touching only writes the activity, so a stale read can't undo
+			// the revocation.
+			seen := time.Now().Add(time.Minute)
+			if err := sessions.Touch(first.ID, seen, "10.0.0.1", "curl"); err != nil {
+				t.Fatalf("failed to touch session: %v", err)
+			}
+
+			if touched, _ := sessions.FindByID(first.ID); !touched.LastSeenAt.Equal(seen) || touched.IP != "10.0.0.1" || touched.UserAgent != "curl" {
+				t.Errorf("expected the activity to be stored, got %+v", touched)
+			}
+
+			if err := sessions.Touch("missing", seen, "", ""); err == nil {
+				t.Error("expected an error touching a missing session")
+			}
+
+			for _, id := range []string{first.ID, second.ID} {
+				if session, _ := sessions.FindByID(id); !session.Revoked {
+					t.Errorf("expected session %s to be revoked", id)
+				}
+			}
+
+			if session, _ := sessions.FindByID(other.ID); session.Revoked {
+				t.Error("expected sessions of other families to be left alone")
+			}
+
+			if err := sessions.DeleteByUserID("user-1"); err != nil {
+				t.Fatalf("failed to delete sessions: %v", err)
+			}
+
+			remaining, _ := sessions.FindByUserID("user-1")
+			if len(remaining) != 0 {
+				t.Errorf("expected no sessions left, got %d", len(remaining))
+			}
+
+			if err := sessions.Delete(first.ID); err == nil {
+				t.Error("expected an error deleting a missing session")
+			}
+		})
+	}
+}
+
+func TestStorage_TransactionIssues(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			err := transactions.SaveAll([]domain.Transaction{
+				{Timestamp: time.Unix(300, 0), Name: "C", Type: domain.TransactionTypeDebit, Amount: 10, Status: domain.TransactionStatusFailed, Description: "c", UserID: "user-1"},
+				{Timestamp: time.Unix(100, 0), Name: "A", Type: domain.TransactionTypeDebit, Amount: 30, Status: domain.TransactionStatusPending, Description: "a", UserID: "user-1"},
+				{Timestamp: time.Unix(200, 0), Name: "B", Type: domain.TransactionTypeCredit, Amount: 20, Status: domain.TransactionStatusSuccess, Description: "b", UserID: "user-1"},
+				{Timestamp: time.Unix(400, 0), Name: "D", Type: domain.TransactionTypeDebit, Amount: 40, Status: domain.TransactionStatusFailed, Description: "d", UserID: "user-2"},
+			})
+			if err != nil {
+				t.Fatalf("failed to save transactions: %v", err)
+			}
+
+			issues, total, err := transactions.Search(domain.IssuesFilter("user-1"),
+				dto_transaction.PaginationDTO{Page: 1, Limit: 1},
+				dto_transaction.SortingDTO{Sort: dto_transaction.SortDesc, SortBy: "amount"}, nil)
+			if err != nil {
+				t.Fatalf("failed to get issues: %v", err)
+			}
+
+			if total != 2 || len(issues) != 1 || issues[0].Name != "A" {
+				t.Errorf("expected A first of 2 issues, got %+v of %d", issues, total)
+			}
+
+			if !issues[0].Timestamp.Equal(time.Unix(100, 0)) {
+				t.Errorf("expected timestamp to round-trip, got %v", issues[0].Timestamp)
+			}
+
+			if issues, total, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Page: 3, Limit: 1}, dto_transaction.SortingDTO{}, nil); len(issues) != 0 || total != 2 {
+				t.Errorf("expected no issues but the real total past the last page, got %d of %d", len(issues), total)
+			}
+
+			if _, _, err := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{SortBy: "user_id"}, nil); err == nil {
+				t.Error("expected an unknown sortBy field to be rejected")
+			}
+
+			invalid := []domain.Transaction{{Timestamp: time.Unix(100, 0), Name: "X", Type: "REFUND", Amount: 1, Status: domain.TransactionStatusSuccess, Description: "x", UserID: "user-1"}}
+			if err := transactions.SaveAll(invalid); err == nil {
+				t.Error("expected an invalid transaction to be rejected")
+			}
+
+			all, _ := transactions.GetAllByUserID("user-1")
+			if len(all) != 3 {
+				t.Errorf("expected 3 transactions for user-1, got %d", len(all))
+			}
+		})
+	}
+}
+
+func TestStorage_JournalRestart(t *testing.T) {
+	dir := t.TempDir()
+	storage := openJournaled(t, dir)
+
+	user, err := storage.Users.Save(&domain.User{Username: "alice", Password: "hash"})
+	if err != nil {
+		t.Fatalf("failed to save user: %v", err)
+	}
+
+	first, _ := storage.Sessions.Save(&domain.Session{UserID: user.ID, CreatedAt: time.Now()})
+	second, _ := storage.Sessions.Save(&domain.Session{UserID: user.ID, FamilyID: first.FamilyID, CreatedAt: time.Now()})
+	storage.Sessions.RevokeFamily(first.FamilyID)
+	storage.Sessions.Touch(second.ID, time.Now(), "10.0.0.1", "curl")
+	storage.Sessions.Delete(first.ID)
+
+	var saved []domain.Transaction
+	for i := 0; i < 4; i++ {
+		batch := []domain.Transaction{
+			{Timestamp: time.Unix(int64(100+i), 0), Name: "A", Type: domain.TransactionTypeCredit, Amount: 10, Status: domain.TransactionStatusPending, Description: "a", UserID: user.ID},
+		}
+		storage.Transactions.SaveAll(batch)
+		saved = append(saved, batch...)
+	}
+
+	resolved := saved[1]
+	resolved.Status = domain.TransactionStatusSuccess
+	storage.Transactions.Update(&resolved)
+	storage.Transactions.Delete(saved[0].ID)
+
+	upload, _ := storage.Uploads.Save(&domain.UploadBatch{UserID: user.ID, UploadedAt: time.Now(), Status: domain.UploadStatusProcessing})
+	storage.Transactions.SaveAll([]domain.Transaction{
+		{Timestamp: time.Unix(200, 0), Name: "B", Type: domain.TransactionTypeDebit, Amount: 10, Status: domain.TransactionStatusFailed, Description: "b", UserID: user.ID, BatchID: upload.ID},
+	})
+	storage.Uploads.RollBack(upload.ID)
+
+	profile, _ := storage.Profiles.Save(&domain.ImportProfile{UserID: user.ID, Name: "bank", Columns: map[string]string{"name": "Payee"}})
+	dropped, _ := storage.Profiles.Save(&domain.ImportProfile{UserID: user.ID, Name: "old"})
+	storage.Profiles.Delete(dropped.ID)
+
+	key, _ := storage.APIKeys.Save(&domain.APIKey{UserID: user.ID, Name: "ci", Hash: "key-hash", Scopes: []domain.Scope{domain.ScopeRead}})
+	storage.LoginAttempts.Save(&domain.LoginAttempt{Key: "user:alice", Failures: 1})
+	storage.AuthEvents.Save(&domain.AuthEvent{Type: domain.AuthEventSignInFailure, Username: "alice"})
+	storage.PasswordResets.Save(&domain.PasswordResetToken{UserID: user.ID, TokenHash: "reset-hash"})
+
+	// Reopen without closing, as after a crash. With compaction every 5
+	// records, this restores from a snapshot plus the journal after it.
+	restored := openJournaled(t, dir)
+
+	if found, err := restored.Users.FindByUsername("alice"); err != nil || found.ID != user.ID {
+		t.Errorf("expected user to survive a restart, got %v", err)
+	}
+
+	if _, err := restored.Sessions.FindByID(first.ID); err == nil {
+		t.Error("expected the deleted session to stay deleted")
+	}
+
+	if session, err := restored.Sessions.FindByID(second.ID); err != nil || !session.Revoked || session.IP != "10.0.0.1" {
+		t.Errorf("expected the revoked session to stay revoked and touched, got %v", err)
+	}
+
+	transactions, _ := restored.Transactions.GetAllByUserID(user.ID)
+	if len(transactions) != 3 || transactions[0].ID != resolved.ID || transactions[0].Status != domain.TransactionStatusSuccess {
+		t.Errorf("expected the update and deletes to survive a restart, got %+v", transactions)
+	}
+
+	if found, err := restored.Uploads.FindByID(upload.ID); err != nil || found.Status != domain.UploadStatusRolledBack {
+		t.Errorf("expected the upload to survive a restart, got %v", err)
+	}
+
+	for _, tx := range transactions {
+		if tx.BatchID == upload.ID {
+			t.Errorf("expected the rolled back upload's transactions to stay deleted, got %+v", tx)
+		}
+	}
+
+	if profiles, _ := restored.Profiles.FindByUserID(user.ID); len(profiles) != 1 || profiles[0].ID != profile.ID || profiles[0].Columns["name"] != "Payee" {
+		t.Errorf("expected only the kept import profile to survive a restart, got %+v", profiles)
+	}
+
+	if found, err := restored.APIKeys.FindByHash("key-hash"); err != nil || found.ID != key.ID || len(found.Scopes) != 1 {
+		t.Errorf("expected the api key to survive a restart, got %v", err)
+	}
+
+	if attempt, err := restored.LoginAttempts.FindByKey("user:alice"); err != nil || attempt.Failures != 1 {
+		t.Errorf("expected the login attempt to survive a restart, got %v", err)
+	}
+
+	if events, _ := restored.AuthEvents.FindByUsername("alice"); len(events) != 1 {
+		t.Errorf("expected the auth event to survive a restart, got %+v", events)
+	}
+
+	if _, err := restored.PasswordResets.FindByHash("reset-hash"); err != nil {
+		t.Errorf("expected the reset token to survive a restart, got %v", err)
+	}
+
+	restored.Transactions.Clear()
+	if err := restored.Close(); err != nil {
+		t.Fatalf("failed to close storage: %v", err)
+	}
+
+	cleared := openJournaled(t, dir)
+	if transactions, _ := cleared.Transactions.GetAll(); len(transactions) != 0 {
+		t.Errorf("expected Clear to survive a restart, got %d transactions", len(transactions))
+	}
+}
+
+func TestStorage_TransactionsAreCopies(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			transactions.SaveAll([]domain.Transaction{
+				{Timestamp: time.Unix(100, 0), Name: "A", Type: domain.TransactionTypeDebit, Amount: 10, Status: domain.TransactionStatusFailed, Description: "a", UserID: "user-1"},
+			})
+
+			all, _ := transactions.GetAll()
+			all[0].Amount = 999
+
+			byUser, _ := transactions.GetAllByUserID("user-1")
+			byUser[0].Name = "changed"
+
+			issues, _, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, nil)
+			if issues[0].Amount != 10 || issues[0].Name != "A" {
+				t.Errorf("expected stored transactions to be unaffected by callers, got %+v", issues[0])
+			}
+		})
+	}
+}
+
+func TestStorage_RecordsAreCopies(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			storage := open(t)
+
+			user := &domain.User{Username: "alice", Password: "hash", MFARecoveryCodes: []string{"code-1"}}
+			storage.Users.Save(user)
+			user.Username = "mallory"
+			user.MFARecoveryCodes[0] = "changed"
+
+			found, _ := storage.Users.FindByID(user.ID)
+			found.Disabled = true
+			if again, err := storage.Users.FindByUsername("alice"); err != nil || again.Disabled || again.MFARecoveryCodes[0] != "code-1" {
+				t.Errorf("expected the stored user to be unaffected by callers, got %+v, %v", again, err)
+			}
+
+			session := &domain.Session{UserID: user.ID}
+			storage.Sessions.Save(session)
+			session.UserID = "user-2"
+
+			held, _ := storage.Sessions.FindByRefreshToken(session.RefreshToken)
+			held.Revoked = true
+			if again, err := storage.Sessions.FindByID(session.ID); err != nil || again.Revoked || again.UserID != user.ID {
+				t.Errorf("expected the stored session to be unaffected by callers, got %+v, %v", again, err)
+			}
+
+			storage.Sessions.RevokeFamily(session.FamilyID)
+			if sessions, _ := storage.Sessions.FindByUserID(user.ID); len(sessions) != 1 || !sessions[0].Revoked {
+				t.Errorf("expected the family to be revoked, got %+v", sessions)
+			}
+
+			profile := &domain.ImportProfile{UserID: user.ID, Name: "bank", Columns: map[string]string{"name": "Payee"}}
+			storage.Profiles.Save(profile)
+			profile.Columns["name"] = "changed"
+
+			listed, _ := storage.Profiles.FindByUserID(user.ID)
+			listed[0].Defaults = map[string]string{"status": "FAILED"}
+			if again, err := storage.Profiles.FindByID(profile.ID); err != nil || again.Columns["name"] != "Payee" || again.Defaults != nil {
+				t.Errorf("expected the stored profile to be unaffected by callers, got %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestStorage_IssuesMultiKeySort(t *testing.T) {
+	issue := func(timestamp int64, name string, amount int64, status domain.TransactionStatus) domain.Transaction {
+		return domain.Transaction{Timestamp: time.Unix(timestamp, 0), Name: name, Type: domain.TransactionTypeDebit, Amount: amount, Status: status, Description: name, UserID: "user-1"}
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			transactions.SaveAll([]domain.Transaction{
+				issue(300, "a", 10, domain.TransactionStatusPending),
+				issue(100, "b", 50, domain.TransactionStatusFailed),
+				issue(200, "c", 10, domain.TransactionStatusFailed),
+				issue(150, "d", 50, domain.TransactionStatusFailed),
+				issue(100, "e", 10, domain.TransactionStatusPending),
+			})
+
+			names := func(sorting dto_transaction.SortingDTO, limit int, page int) string {
+				t.Helper()
+
+				issues, _, err := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Page: page, Limit: limit}, sorting, nil)
+				if err != nil {
+					t.Fatalf("failed to get issues: %v", err)
+				}
+
+				result := ""
+				for _, tx := range issues {
+					result += tx.Name
+				}
+				return result
+			}
+
+			cases := map[string]string{
+				"status,-amount,timestamp": "bdcea",
+				"-amount,name":             "bdace",
+				"-amount,-timestamp":       "dbace",
+			}
+
+			for sortBy, expected := range cases {
+				if got := names(dto_transaction.SortingDTO{SortBy: sortBy}, 10, 1); got != expected {
+					t.Errorf("sortBy=%s: expected %s, got %s", sortBy, expected, got)
+				}
+			}
+
+			// Ties fall back to the ID, so paging through them neither
+			// repeats nor skips rows
+			var ids []string
+			for page := 1; page <= 5; page++ {
+				issues, _, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Page: page, Limit: 1}, dto_transaction.SortingDTO{SortBy: "type"}, nil)
+				for _, tx := range issues {
+					ids = append(ids, tx.ID)
+				}
+			}
+			if len(ids) != 5 || !slices.IsSorted(ids) || len(slices.Compact(slices.Clone(ids))) != 5 {
+				t.Errorf("expected paging through ties to return every row once in ID order, got %v", ids)
+			}
+
+			for _, field := range dto_transaction.SortableFields {
+				if _, _, err := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{SortBy: "-" + field}, nil); err != nil {
+					t.Errorf("expected sortable field %s to be supported, got %v", field, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_IssuesKeyset(t *testing.T) {
+	issue := func(timestamp int64, name string, amount int64) domain.Transaction {
+		return domain.Transaction{Timestamp: time.Unix(timestamp, 0), Name: name, Type: domain.TransactionTypeDebit, Amount: amount, Status: domain.TransactionStatusFailed, Description: name, UserID: "user-1"}
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			transactions.SaveAll([]domain.Transaction{
+				issue(500, "a", 10),
+				issue(100, "b", 20),
+				issue(300, "c", 10),
+				issue(100, "d", 30),
+				issue(200, "e", 20),
+				issue(400, "f", 10),
+				issue(100, "g", 20),
+			})
+
+			for _, sortBy := range []string{"", "-amount,timestamp", "type", "-timestamp"} {
+				sorting := dto_transaction.SortingDTO{SortBy: sortBy}
+
+				expected, total, err := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Limit: 100}, sorting, nil)
+				if err != nil || total != 7 {
+					t.Fatalf("sortBy=%s: failed to get issues: %d, %v", sortBy, total, err)
+				}
+
+				// Walk forward from the first row, then back from the last one.
+				forward := slices.Clone(expected[:1])
+				for {
+					cursor := &domain.TransactionCursor{Anchor: forward[len(forward)-1]}
+					page, total, err := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Page: 99, Limit: 2}, sorting, cursor)
+					if err != nil || total != 7 {
+						t.Fatalf("sortBy=%s: failed to get a keyset page: %d, %v", sortBy, total, err)
+					}
+					if len(page) == 0 {
+						break
+					}
+					forward = append(forward, page...)
+				}
+
+				backward := slices.Clone(expected[len(expected)-1:])
+				for {
+					cursor := &domain.TransactionCursor{Anchor: backward[0], Backward: true}
+					page, _, err := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Limit: 2}, sorting, cursor)
+					if err != nil {
+						t.Fatalf("sortBy=%s: failed to get a keyset page: %v", sortBy, err)
+					}
+					if len(page) == 0 {
+						break
+					}
+					backward = append(slices.Clone(page), backward...)
+				}
+
+				if !slices.EqualFunc(forward, expected, sameID) || !slices.EqualFunc(backward, expected, sameID) {
+					t.Errorf("sortBy=%s: expected keyset pages to match %v, got %v forward and %v backward", sortBy, expected, forward, backward)
+				}
+			}
+
+			// A cursor stays usable after its anchor is gone.
+			anchor := issue(250, "gone", 20)
+			anchor.ID = "0"
+			page, _, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{Limit: 10}, dto_transaction.SortingDTO{}, &domain.TransactionCursor{Anchor: anchor})
+			if len(page) != 3 || page[0].Name != "c" {
+				t.Errorf("expected the rows after a deleted anchor, got %+v", page)
+			}
+		})
+	}
+}
+
+func TestStorage_TransactionCRUD(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			saved := []domain.Transaction{
+				{Timestamp: time.Unix(100, 0), Name: "A", Type: domain.TransactionTypeDebit, Amount: 10, Status: domain.TransactionStatusPending, Description: "a", UserID: "user-1"},
+				{Timestamp: time.Unix(200, 0), Name: "B", Type: domain.TransactionTypeDebit, Amount: 20, Status: domain.TransactionStatusFailed, Description: "b", UserID: "user-1"},
+				{Timestamp: time.Unix(300, 0), Name: "C", Type: domain.TransactionTypeDebit, Amount: 30, Status: domain.TransactionStatusPending, Description: "c", UserID: "user-1"},
+			}
+			if err := transactions.SaveAll(saved); err != nil {
+				t.Fatalf("failed to save transactions: %v", err)
+			}
+
+			if saved[0].ID == "" || saved[0].ID == saved[1].ID {
+				t.Fatalf("expected SaveAll to assign unique IDs, got %q and %q", saved[0].ID, saved[1].ID)
+			}
+
+			found, err := transactions.FindByID(saved[1].ID)
+			if err != nil || found.Name != "B" {
+				t.Fatalf("expected to find B, got %+v, %v", found, err)
+			}
+
+			// Resolving A and moving it after C changes the issue index
+			resolved := saved[0]
+			resolved.Description = "fixed"
+			resolved.Timestamp = time.Unix(400, 0)
+			resolved.Status = domain.TransactionStatusFailed
+			if _, err := transactions.Update(&resolved); err != nil {
+				t.Fatalf("failed to update transaction: %v", err)
+			}
+
+			issues, total, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, nil)
+			if total != 3 || issues[0].Name != "B" || issues[2].Name != "A" || issues[2].Description != "fixed" {
+				t.Errorf("expected the update to be reflected in issues, got %+v", issues)
+			}
+
+			resolved.Status = domain.TransactionStatusSuccess
+			transactions.Update(&resolved)
+			if _, total, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, nil); total != 2 {
+				t.Errorf("expected a resolved transaction to leave the issues, got %d", total)
+			}
+
+			invalid := resolved
+			invalid.Type = "REFUND"
+			if _, err := transactions.Update(&invalid); err == nil {
+				t.Error("expected an invalid update to be rejected")
+			}
+
+			moved := resolved
+			moved.UserID = "user-2"
+			if _, err := transactions.Update(&moved); err == nil {
+				t.Error("expected a change of owner to be rejected")
+			}
+
+			missing := resolved
+			missing.ID = "missing"
+			if _, err := transactions.Update(&missing); !errors.Is(err, domain.ErrTransactionNotFound) {
+				t.Errorf("expected updating an unknown transaction to fail with not found, got %v", err)
+			}
+
+			if found, _ := transactions.FindByID(resolved.ID); found.Type != domain.TransactionTypeDebit || found.UserID != "user-1" {
+				t.Errorf("expected rejected updates to leave the transaction unchanged, got %+v", found)
+			}
+
+			if err := transactions.Delete(saved[1].ID); err != nil {
+				t.Fatalf("failed to delete transaction: %v", err)
+			}
+
+			if _, err := transactions.FindByID(saved[1].ID); !errors.Is(err, domain.ErrTransactionNotFound) {
+				t.Errorf("expected the deleted transaction to be gone, got %v", err)
+			}
+
+			if err := transactions.Delete(saved[1].ID); !errors.Is(err, domain.ErrTransactionNotFound) {
+				t.Errorf("expected deleting twice to fail with not found, got %v", err)
+			}
+
+			all, _ := transactions.GetAllByUserID("user-1")
+			if len(all) != 2 || all[0].Name != "A" || all[1].Name != "C" {
+				t.Errorf("expected A and C to remain in upload order, got %+v", all)
+			}
+
+			issues, total, _ = transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{}, nil)
+			if total != 1 || issues[0].Name != "C" {
+				t.Errorf("expected only C left among the issues, got %+v", issues)
+			}
+		})
+	}
+}
+
+func TestStorage_TimesInUTC(t *testing.T) {
+	// Times must come back the same whatever zone the server runs in
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			timestamp := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			saved := []domain.Transaction{{Timestamp: timestamp, Name: "A", Type: domain.TransactionTypeDebit, Amount: 10, Status: domain.TransactionStatusSuccess, Description: "a", UserID: "user-1"}}
+			if err := transactions.SaveAll(saved); err != nil {
+				t.Fatalf("failed to save transactions: %v", err)
+			}
+
+			found, err := transactions.FindByID(saved[0].ID)
+			if err != nil || !found.Timestamp.Equal(timestamp) || found.Timestamp.Location() != time.UTC {
+				t.Fatalf("expected %v in UTC, got %+v, %v", timestamp, found, err)
+			}
+
+			// Bounds Unix nanoseconds can't hold
+			filter := domain.TransactionFilter{
+				UserID: "user-1",
+				From:   time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC),
+				To:     time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			matched, total, err := transactions.Search(filter, dto_transaction.PaginationDTO{Page: 1, Limit: 10}, dto_transaction.SortingDTO{}, nil)
+			if err != nil || total != 1 || len(matched) != 1 {
+				t.Errorf("expected the transaction within far off bounds, got %d, %v", total, err)
+			}
+		})
+	}
+}
+
+func TestStorage_UploadBatches(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			storage := open(t)
+			uploads := storage.Uploads
+			transactions := storage.Transactions
+
+			var batches []*domain.UploadBatch
+			for i, userID := range []string{"user-1", "user-1", "user-2", "user-1"} {
+				batch, err := uploads.Save(&domain.UploadBatch{
+					UserID:     userID,
+					Filename:   "statement.csv",
+					UploadedAt: time.Unix(int64(100+i), 0),
+					Status:     domain.UploadStatusProcessing,
+				})
+				if err != nil {
+					t.Fatalf("failed to save upload batch: %v", err)
+				}
+				batches = append(batches, batch)
+			}
+
+			if batches[0].ID == "" || batches[0].ID == batches[1].ID {
+				t.Fatalf("expected Save to assign unique IDs, got %q and %q", batches[0].ID, batches[1].ID)
+			}
+
+			completed := *batches[0]
+			completed.Checksum = "abc"
+			completed.RowCount = 2
+			completed.Status = domain.UploadStatusCompleted
+			opening, closing := int64(-250), int64(1000)
+			completed.Balances = []domain.StatementBalance{
+				{Account: "DE89370400440532013000", Opening: &opening, Closing: &closing, Booked: 1250},
+				{Account: "DE02120300000000202051", Closing: &closing},
+			}
+			if _, err := uploads.Update(&completed); err != nil {
+				t.Fatalf("failed to update upload batch: %v", err)
+			}
+
+			if found, err := uploads.FindByID(batches[0].ID); err != nil || found.Status != domain.UploadStatusCompleted || found.RowCount != 2 ||
+				!reflect.DeepEqual(found.Balances, completed.Balances) {
+				t.Errorf("expected the update to be stored, got %+v, %v", found, err)
+			}
+
+			if found, _ := uploads.FindByID(batches[1].ID); found.Balances != nil {
+				t.Errorf("expected no balances on a statement without them, got %+v", found)
+			}
+
+			if _, err := uploads.FindByID("missing"); !errors.Is(err, domain.ErrUploadNotFound) {
+				t.Errorf("expected an unknown upload to fail with not found, got %v", err)
+			}
+
+			page, total, err := uploads.FindByUserID("user-1", 1, 2)
+			if err != nil {
+				t.Fatalf("failed to list upload batches: %v", err)
+			}
+			if total != 3 || len(page) != 2 || page[0].ID != batches[3].ID || page[1].ID != batches[1].ID {
+				t.Errorf("expected the two newest of 3 uploads, got %d of %d", len(page), total)
+			}
+
+			// Rows from two batches of one user, a batch of another user and
+			// rows stored before uploads were tracked, interleaved in time.
+			rows := func(batchID string, userID string, names ...string) []domain.Transaction {
+				var rows []domain.Transaction
+				for i, name := range names {
+					rows = append(rows, domain.Transaction{
+						Timestamp: time.Unix(int64(100+i), 0), Name: name, Type: domain.TransactionTypeDebit, Amount: 10,
+						Status: domain.TransactionStatusFailed, Description: "d", UserID: userID, BatchID: batchID, ExternalID: "fit-" + name,
+						Account: "acct-1",
+					})
+				}
+				return rows
+			}
+
+			for _, group := range [][]domain.Transaction{
+				rows(batches[0].ID, "user-1", "A", "B"),
+				rows("", "user-1", "L"),
+				rows(batches[1].ID, "user-1", "C", "D", "E"),
+				rows(batches[2].ID, "user-2", "X"),
+			} {
+				if err := transactions.SaveAll(group); err != nil {
+					t.Fatalf("failed to save transactions: %v", err)
+				}
+			}
+
+			all, _ := transactions.GetAllByUserID("user-1")
+			edited := all[0]
+			edited.BatchID = batches[1].ID
+			edited.ExternalID = "fit-Z"
+			edited.Account = "acct-2"
+			updated, err := transactions.Update(&edited)
+			if err != nil || updated.BatchID != batches[0].ID || updated.ExternalID != "fit-A" || updated.Account != "acct-1" {
+				t.Errorf("expected the batch and external ID of a transaction to stay fixed, got %+v, %v", updated, err)
+			}
+
+			if deleted, err := transactions.DeleteByBatchID(batches[1].ID); err != nil || deleted != 3 {
+				t.Errorf("expected 3 transactions to be deleted, got %d, %v", deleted, err)
+			}
+
+			if deleted, _ := transactions.DeleteByBatchID(""); deleted != 0 {
+				t.Errorf("expected rows without a batch not to form one, got %d deleted", deleted)
+			}
+
+			if deleted, _ := transactions.DeleteByBatchID(batches[1].ID); deleted != 0 {
+				t.Errorf("expected nothing left to delete, got %d", deleted)
+			}
+
+			names := ""
+			issues, total, _ := transactions.Search(domain.IssuesFilter("user-1"), dto_transaction.PaginationDTO{}, dto_transaction.SortingDTO{SortBy: "name"}, nil)
+			for _, tx := range issues {
+				names += tx.Name
+			}
+			if total != 3 || names != "ABL" {
+				t.Errorf("expected A, B and L to remain among the issues, got %s of %d", names, total)
+			}
+
+			if _, err := transactions.FindByID(all[3].ID); !errors.Is(err, domain.ErrTransactionNotFound) {
+				t.Errorf("expected the batch's transactions to be gone, got %v", err)
+			}
+
+			if others, _ := transactions.GetAllByUserID("user-2"); len(others) != 1 {
+				t.Errorf("expected other users' uploads to be kept, got %d", len(others))
+			}
+
+			if deleted, err := uploads.RollBack(batches[2].ID); err != nil || deleted != 1 {
+				t.Errorf("expected the rollback to delete the batch's transaction, got %d, %v", deleted, err)
+			}
+
+			if found, _ := uploads.FindByID(batches[2].ID); found.Status != domain.UploadStatusRolledBack {
+				t.Errorf("expected the rollback to mark the batch rolled back, got %s", found.Status)
+			}
+
+			if others, _ := transactions.GetAllByUserID("user-2"); len(others) != 0 {
+				t.Errorf("expected the rolled back transactions to be gone, got %d", len(others))
+			}
+
+			if _, err := uploads.RollBack("missing"); !errors.Is(err, domain.ErrUploadNotFound) {
+				t.Errorf("expected rolling back an unknown upload to fail with not found, got %v", err)
+			}
+
+			// The stored batches aren't shared with callers
+			found, _ := uploads.FindByID(batches[0].ID)
+			found.Status = domain.UploadStatusFailed
+			*found.Balances[0].Opening = 0
+			if again, _ := uploads.FindByID(batches[0].ID); again.Status != domain.UploadStatusCompleted || *again.Balances[0].Opening != -250 {
+				t.Errorf("expected changes to a found batch not to reach the stored one, got %+v", again)
+			}
+		})
+	}
+}
+
+func TestStorage_UploadDeduplication(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			storage := open(t)
+			uploads := storage.Uploads
+			transactions := storage.Transactions
+
+			first, err := uploads.Save(&domain.UploadBatch{UserID: "user-1", Checksum: "abc", UploadedAt: time.Unix(100, 0), IdempotencyKey: "key"})
+			if err != nil {
+				t.Fatalf("failed to save upload batch: %v", err)
+			}
+			uploads.Save(&domain.UploadBatch{UserID: "user-1", Checksum: "abc", UploadedAt: time.Unix(200, 0)})
+			uploads.Save(&domain.UploadBatch{UserID: "user-2", Checksum: "abc", UploadedAt: time.Unix(300, 0), IdempotencyKey: "key"})
+
+			if _, err := uploads.Save(&domain.UploadBatch{UserID: "user-1", IdempotencyKey: "key"}); !errors.Is(err, domain.ErrIdempotencyKeyConflict) {
+				t.Errorf("expected a reused idempotency key to be refused, got %v", err)
+			}
+
+			if found, err := uploads.FindByIdempotencyKey("user-1", "key"); err != nil || found.ID != first.ID {
+				t.Errorf("expected to find the first upload by its key, got %v", err)
+			}
+
+			if _, err := uploads.FindByIdempotencyKey("user-1", ""); !errors.Is(err, domain.ErrUploadNotFound) {
+				t.Errorf("expected an empty key to match nothing, got %v", err)
+			}
+
+			released := *first
+			released.IdempotencyKey = ""
+			if _, err := uploads.Update(&released); err != nil {
+				t.Fatalf("failed to update upload batch: %v", err)
+			}
+
+			if _, err := uploads.Save(&domain.UploadBatch{UserID: "user-1", IdempotencyKey: "key"}); err != nil {
+				t.Errorf("expected a released key to be reusable, got %v", err)
+			}
+
+			same, err := uploads.FindByChecksum("user-1", "abc")
+			if err != nil || len(same) != 2 || same[1].ID != first.ID {
+				t.Errorf("expected user-1's two uploads of the file, newest first, got %d, %v", len(same), err)
+			}
+
+			row := func(seconds int64, name string) domain.Transaction {
+				return domain.Transaction{
+					Timestamp: time.Unix(seconds, 0), Name: name, Type: domain.TransactionTypeDebit, Amount: 10,
+					Status: domain.TransactionStatusSuccess, Description: "d", UserID: "user-1",
+				}
+			}
+
+			transactions.SaveAll([]domain.Transaction{row(100, "A"), row(100, "A"), row(200, "B"), row(900, "C")})
+			transactions.SaveAll([]domain.Transaction{{Timestamp: time.Unix(100, 0), Name: "A", Type: domain.TransactionTypeDebit, Amount: 10, Status: domain.TransactionStatusSuccess, Description: "d", UserID: "user-2"}})
+
+			// The timestamp carries a fraction of a second, like a parsed
+			// date-time might, but fingerprints are taken at whole seconds.
+			candidates := []domain.Transaction{row(100, "A"), row(200, "B"), row(300, "B")}
+			candidates[1].Timestamp = candidates[1].Timestamp.Add(time.Millisecond)
+
+			counts, err := transactions.CountFingerprints("user-1", candidates)
+			if err != nil {
+				t.Fatalf("failed to count fingerprints: %v", err)
+			}
+
+			if len(counts) != 2 || counts[candidates[0].Fingerprint()] != 2 || counts[candidates[1].Fingerprint()] != 1 {
+				t.Errorf("expected A twice and B once, got %v", counts)
+			}
+
+			if counts, _ := transactions.CountFingerprints("user-3", candidates); len(counts) != 0 {
+				t.Errorf("expected nothing for a user without transactions, got %v", counts)
+			}
+		})
+	}
+}
+
+func TestStorage_ImportProfiles(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			profiles := open(t).Profiles
+
+			bank, err := profiles.Save(&domain.ImportProfile{
+				UserID:             "user-1",
+				Name:               "Bank",
+				Delimiter:          ";",
+				SkipRows:           2,
+				Columns:            map[string]string{"timestamp": "Date", "amount": "4"},
+				Defaults:           map[string]string{"status": "SUCCESS"},
+				DateLayout:         "02/01/2006",
+				TimeZone:           "Europe/Berlin",
+				DecimalSeparator:   ",",
+				ThousandsSeparator: ".",
+				DecimalPlaces:      2,
+				SignConvention:     domain.SignConventionNegativeDebit,
+				CreatedAt:          time.Unix(100, 0),
+				UpdatedAt:          time.Unix(100, 0),
+			})
+			if err != nil {
+				t.Fatalf("failed to save import profile: %v", err)
+			}
+			profiles.Save(&domain.ImportProfile{UserID: "user-1", Name: "another"})
+			profiles.Save(&domain.ImportProfile{UserID: "user-2", Name: "bank"})
+
+			if _, err := profiles.Save(&domain.ImportProfile{UserID: "user-1", Name: "BANK"}); !errors.Is(err, domain.ErrImportProfileNameTaken) {
+				t.Errorf("expected a taken name to be refused whatever its case, got %v", err)
+			}
+
+			found, err := profiles.FindByID(bank.ID)
+			if err != nil || found.Delimiter != ";" || found.SkipRows != 2 || found.Columns["amount"] != "4" || found.Defaults["status"] != "SUCCESS" || !found.CreatedAt.Equal(time.Unix(100, 0)) ||
+				found.DateLayout != "02/01/2006" || found.TimeZone != "Europe/Berlin" || found.DecimalSeparator != "," || found.ThousandsSeparator != "." ||
+				found.DecimalPlaces != 2 || found.SignConvention != domain.SignConventionNegativeDebit {
+				t.Errorf("expected the profile to be stored as saved, got %+v, %v", found, err)
+			}
+
+			renamed := *found
+			renamed.Name = "Zebra Bank"
+			renamed.Columns = map[string]string{"timestamp": "1"}
+			if _, err := profiles.Update(&renamed); err != nil {
+				t.Fatalf("failed to update import profile: %v", err)
+			}
+
+			clash := renamed
+			clash.Name = "Another"
+			if _, err := profiles.Update(&clash); !errors.Is(err, domain.ErrImportProfileNameTaken) {
+				t.Errorf("expected renaming onto a taken name to be refused, got %v", err)
+			}
+
+			list, err := profiles.FindByUserID("user-1")
+			if err != nil || len(list) != 2 || list[0].Name != "another" || list[1].Name != "Zebra Bank" || list[1].Columns["timestamp"] != "1" {
+				t.Errorf("expected user-1's two profiles ordered by name, got %+v, %v", list, err)
+			}
+
+			if err := profiles.Delete(bank.ID); err != nil {
+				t.Fatalf("failed to delete import profile: %v", err)
+			}
+
+			if _, err := profiles.FindByID(bank.ID); !errors.Is(err, domain.ErrImportProfileNotFound) {
+				t.Errorf("expected the deleted profile to be gone, got %v", err)
+			}
+
+			if err := profiles.Delete(bank.ID); !errors.Is(err, domain.ErrImportProfileNotFound) {
+				t.Errorf("expected deleting it again to fail with not found, got %v", err)
+			}
+
+			if _, err := profiles.Update(&renamed); !errors.Is(err, domain.ErrImportProfileNotFound) {
+				t.Errorf("expected updating a deleted profile to fail with not found, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStorage_AuthRecords(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			storage := open(t)
+
+			key, err := storage.APIKeys.Save(&domain.APIKey{UserID: "user-1", Name: "ci", Prefix: "abcd", Hash: "hash-1", Scopes: []domain.Scope{domain.ScopeRead}, CreatedAt: time.Unix(100, 0)})
+			if err != nil {
+				t.Fatalf("failed to save api key: %v", err)
+			}
+			storage.APIKeys.Save(&domain.APIKey{UserID: "user-2", Name: "other", Hash: "hash-2"})
+
+			revoked := *key
+			revoked.Revoked = true
+			revoked.Scopes = []domain.Scope{domain.ScopeRead, domain.ScopeUpload}
+			if _, err := storage.APIKeys.Update(&revoked); err != nil {
+				t.Fatalf("failed to update api key: %v", err)
+			}
+
+			found, err := storage.APIKeys.FindByHash("hash-1")
+			if err != nil || found.ID != key.ID || !found.Revoked || len(found.Scopes) != 2 || !found.CreatedAt.Equal(time.Unix(100, 0)) {
+				t.Errorf("expected the updated key to be found by its hash, got %+v, %v", found, err)
+			}
+
+			if keys, err := storage.APIKeys.FindByUserID("user-1"); err != nil || len(keys) != 1 || keys[0].Name != "ci" {
+				t.Errorf("expected user-1's key alone, got %+v, %v", keys, err)
+			}
+
+			if _, err := storage.APIKeys.Update(&domain.APIKey{ID: "missing"}); err == nil {
+				t.Error("expected updating a missing key to fail")
+			}
+
+			attempt := &domain.LoginAttempt{Key: "user:alice", Failures: 2, LastFailure: time.Unix(200, 0)}
+			if err := storage.LoginAttempts.Save(attempt); err != nil {
+				t.Fatalf("failed to save login attempt: %v", err)
+			}
+			attempt.Failures = 3
+			attempt.LockedUntil = time.Unix(300, 0)
+			storage.LoginAttempts.Save(attempt)
+
+			if found, err := storage.LoginAttempts.FindByKey("user:alice"); err != nil || found.Failures != 3 || !found.LockedUntil.Equal(time.Unix(300, 0)) {
+				t.Errorf("expected saving again to replace the attempt, got %+v, %v", found, err)
+			}
+
+			storage.LoginAttempts.Delete("user:alice")
+			if _, err := storage.LoginAttempts.FindByKey("user:alice"); err == nil {
+				t.Error("expected the deleted attempt to be gone")
+			}
+
+			for _, eventType := range []domain.AuthEventType{domain.AuthEventSignInFailure, domain.AuthEventSignInSuccess} {
+				if _, err := storage.AuthEvents.Save(&domain.AuthEvent{Type: eventType, Username: "alice", CreatedAt: time.Unix(400, 0)}); err != nil {
+					t.Fatalf("failed to save auth event: %v", err)
+				}
+			}
+			storage.AuthEvents.Save(&domain.AuthEvent{Type: domain.AuthEventSignInFailure, Username: "bob"})
+
+			events, err := storage.AuthEvents.FindByUsername("alice")
+			if err != nil || len(events) != 2 || events[0].Type != domain.AuthEventSignInFailure || events[1].Type != domain.AuthEventSignInSuccess {
+				t.Errorf("expected alice's two events in the order they were saved, got %+v, %v", events, err)
+			}
+
+			token, err := storage.PasswordResets.Save(&domain.PasswordResetToken{UserID: "user-1", TokenHash: "reset-1", ExpiresAt: time.Unix(500, 0)})
+			if err != nil {
+				t.Fatalf("failed to save reset token: %v", err)
+			}
+
+			used := *token
+			used.Used = true
+			storage.PasswordResets.Update(&used)
+
+			if found, err := storage.PasswordResets.FindByHash("reset-1"); err != nil || !found.Used || !found.ExpiresAt.Equal(time.Unix(500, 0)) {
+				t.Errorf("expected the used token to be found by its hash, got %+v, %v", found, err)
+			}
+
+			storage.PasswordResets.DeleteByUserID("user-1")
+			if _, err := storage.PasswordResets.FindByHash("reset-1"); err == nil {
+				t.Error("expected the user's reset tokens to be deleted")
+			}
+		})
+	}
+}
+
+func TestStorage_Search(t *testing.T) {
+	tx := func(timestamp int64, name string, transactionType domain.TransactionType, amount int64, status domain.TransactionStatus, description string) domain.Transaction {
+		return domain.Transaction{Timestamp: time.Unix(timestamp, 0), Name: name, Type: transactionType, Amount: amount, Status: status, Description: description, UserID: "user-1"}
+	}
+	amount := func(value int64) *int64 { return &value }
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			transactions := open(t).Transactions
+
+			transactions.SaveAll([]domain.Transaction{
+				tx(100, "Grocer", domain.TransactionTypeDebit, 50, domain.TransactionStatusSuccess, "Weekly groceries"),
+				tx(200, "Employer", domain.TransactionTypeCredit, 5000, domain.TransactionStatusSuccess, "Monthly salary"),
+				tx(300, "grocer", domain.TransactionTypeDebit, 70, domain.TransactionStatusFailed, "Groceries, 50% off"),
+				tx(400, "Café", domain.TransactionTypeDebit, 5, domain.TransactionStatusPending, "coffee_beans"),
+				tx(500, "Landlord", domain.TransactionTypeDebit, 1500, domain.TransactionStatusSuccess, "Rent for May"),
+			})
+			transactions.SaveAll([]domain.Transaction{
+				{Timestamp: time.Unix(300, 0), Name: "Grocer", Type: domain.TransactionTypeDebit, Amount: 60, Status: domain.TransactionStatusSuccess, Description: "groceries", UserID: "user-2"},
+			})
+
+			cases := []struct {
+				label    string
+				filter   domain.TransactionFilter
+				expected string
+			}{
+				{"everything", domain.TransactionFilter{}, "Grocer,Employer,grocer,Café,Landlord"},
+				{"issues preset", domain.IssuesFilter("user-1"), "grocer,Café"},
+				{"statuses", domain.TransactionFilter{Statuses: []domain.TransactionStatus{domain.TransactionStatusSuccess, domain.TransactionStatusPending}}, "Grocer,Employer,Café,Landlord"},
+				{"type", domain.TransactionFilter{Types: []domain.TransactionType{domain.TransactionTypeCredit}}, "Employer"},
+				{"inclusive date range", domain.TransactionFilter{From: time.Unix(200, 0), To: time.Unix(400, 0)}, "Employer,grocer,Café"},
+				{"open ended range", domain.TransactionFilter{From: time.Unix(401, 0)}, "Landlord"},
+				{"amounts", domain.TransactionFilter{MinAmount: amount(50), MaxAmount: amount(1500)}, "Grocer,grocer,Landlord"},
+				{"name ignores case", domain.TransactionFilter{Name: "GROCER"}, "Grocer,grocer"},
+				{"description terms", domain.TransactionFilter{DescriptionTerms: []string{"GROCER", "off"}}, "grocer"},
+				{"wildcards are literal", domain.TransactionFilter{DescriptionTerms: []string{"50%"}}, "grocer"},
+				{"underscore is literal", domain.TransactionFilter{DescriptionTerms: []string{"e_b"}}, "Café"},
+				{"no match", domain.TransactionFilter{DescriptionTerms: []string{"%"}, Types: []domain.TransactionType{domain.TransactionTypeCredit}}, ""},
+				{"non-ASCII is case sensitive", domain.TransactionFilter{Name: "CAFÉ"}, ""},
+			}
+
+			for _, c := range cases {
+				c.filter.UserID = "user-1"
+
+				found, total, err := transactions.Search(c.filter, dto_transaction.PaginationDTO{Limit: 100}, dto_transaction.SortingDTO{}, nil)
+				if err != nil {
+					t.Fatalf("%s: failed to search: %v", c.label, err)
+				}
+
+				var names []string
+				for _, transaction := range found {
+					names = append(names, transaction.Name)
+				}
+
+				if got := strings.Join(names, ","); got != c.expected || total != len(found) {
+					t.Errorf("%s: expected %s, got %s (total %d)", c.label, c.expected, got, total)
+				}
+			}
+
+			// Filters combine with sorting and cursors
+			filter := domain.TransactionFilter{UserID: "user-1", Types: []domain.TransactionType{domain.TransactionTypeDebit}}
+			sorting := dto_transaction.SortingDTO{SortBy: "-amount"}
+
+			first, total, _ := transactions.Search(filter, dto_transaction.PaginationDTO{Limit: 2}, sorting, nil)
+			rest, _, _ := transactions.Search(filter, dto_transaction.PaginationDTO{Limit: 10}, sorting, &domain.TransactionCursor{Anchor: first[1]})
+			if total != 4 || len(first) != 2 || first[0].Name != "Landlord" || len(rest) != 2 || rest[1].Name != "Café" {
+				t.Errorf("expected the debits by descending amount across two pages, got %+v then %+v", first, rest)
+			}
+		})
+	}
+}
+
+func sameID(a domain.Transaction, b domain.Transaction) bool {
+	return a.ID == b.ID
+}
@@ -1,85 +1,294 @@
 package repositories
 
 import (
+	"cmp"
 	"fmt"
-	"strconv"
+	"maps"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"firstpersoncode/go-uploader/domain"
 	dto_transaction "firstpersoncode/go-uploader/dto/transaction"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/util"
 )
 
 type transactionRepository struct {
-	mu           sync.RWMutex
+	mu         sync.RWMutex
+	partitions map[string]*userPartition
+	// owners maps every transaction ID to the user whose partition holds it.
+	owners map[string]string
+	// batches maps every upload batch ID to the user whose partition holds
+	// its transactions.
+	batches map[string]string
+	journal *journal.Journal
+}
+
+// userPartition holds one user's transactions in upload order. byStatus
+// indexes their positions per status, sorted by timestamp and ID, so a user's
+// issues are found without looking at anyone else's rows.
+type userPartition struct {
 	transactions []domain.Transaction
+	positions    map[string]int
+	byStatus     map[domain.TransactionStatus][]int
 }
 
 func NewTransactionRepository() domain.TransactionRepository {
+	return newTransactionRepository(nil)
+}
+
+func newTransactionRepository(j *journal.Journal) *transactionRepository {
 	return &transactionRepository{
-		transactions: make([]domain.Transaction, 0),
+		partitions: make(map[string]*userPartition),
+		owners:     make(map[string]string),
+		batches:    make(map[string]string),
+		journal:    j,
 	}
 }
 
 func (r *transactionRepository) SaveAll(transactions []domain.Transaction) error {
+	defer r.journal.Begin()()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	for index, tx := range transactions {
-		record := []string{
-			strconv.FormatInt(tx.Timestamp.Unix(), 10),
-			tx.Name,
-			string(tx.Type),
-			strconv.FormatInt(tx.Amount, 10),
-			string(tx.Status),
-			tx.Description,
+	if err := validateTransactions(transactions); err != nil {
+		return err
+	}
+
+	for index := range transactions {
+		transactions[index].ID = util.GenerateRandomID()
+	}
+
+	if err := r.journal.Append(transactionStore, journalOpAppend, transactions); err != nil {
+		return err
+	}
+
+	r.add(transactions)
+	return nil
+}
+
+func (r *transactionRepository) add(transactions []domain.Transaction) {
+	byUser := make(map[string][]domain.Transaction)
+	for _, tx := range transactions {
+		// Journals written before transactions had IDs replay without one.
+		if tx.ID == "" {
+			tx.ID = util.GenerateRandomID()
+		}
+		byUser[tx.UserID] = append(byUser[tx.UserID], tx)
+		r.owners[tx.ID] = tx.UserID
+		if tx.BatchID != "" {
+			r.batches[tx.BatchID] = tx.UserID
 		}
+	}
 
-		if err := r.validateRecord(record); err != nil {
-			return fmt.Errorf("line %d: %v", index, err)
+	for userID, userTransactions := range byUser {
+		partition, exists := r.partitions[userID]
+		if !exists {
+			partition = &userPartition{
+				positions: make(map[string]int),
+				byStatus:  make(map[domain.TransactionStatus][]int),
+			}
+			r.partitions[userID] = partition
 		}
+
+		partition.add(userTransactions)
+	}
+}
+
+func (r *transactionRepository) FindByID(id string) (*domain.Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	partition, position, exists := r.locate(id)
+	if !exists {
+		return nil, domain.ErrTransactionNotFound
+	}
+
+	transaction := partition.transactions[position]
+	return &transaction, nil
+}
+
+func (r *transactionRepository) Update(transaction *domain.Transaction) (*domain.Transaction, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	partition, position, exists := r.locate(transaction.ID)
+	if !exists {
+		return nil, domain.ErrTransactionNotFound
+	}
+
+	if partition.transactions[position].UserID != transaction.UserID {
+		return nil, fmt.Errorf("transaction owner can't be changed")
+	}
+
+	if err := transaction.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Like its owner, the batch a transaction was uploaded in is fixed, and
+	// so is the bank's ID for it.
+	updated := *transaction
+	updated.BatchID = partition.transactions[position].BatchID
+	updated.ExternalID = partition.transactions[position].ExternalID
+	updated.Account = partition.transactions[position].Account
+
+	if err := r.journal.Append(transactionStore, journalOpPut, &updated); err != nil {
+		return nil, err
+	}
+
+	partition.update(position, updated)
+	return &updated, nil
+}
+
+func (r *transactionRepository) Delete(id string) error {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.owners[id]; !exists {
+		return domain.ErrTransactionNotFound
+	}
+
+	if err := r.journal.Append(transactionStore, journalOpDelete, id); err != nil {
+		return err
 	}
 
-	r.transactions = append(r.transactions, transactions...)
+	r.delete(id)
 	return nil
 }
 
-func (r *transactionRepository) GetAll() []domain.Transaction {
+func (r *transactionRepository) DeleteByBatchID(batchID string) (int, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.batches[batchID]; !exists {
+		return 0, nil
+	}
+
+	if err := r.journal.Append(transactionStore, journalOpDeleteBatch, batchID); err != nil {
+		return 0, err
+	}
+
+	return r.deleteBatch(batchID), nil
+}
+
+func (r *transactionRepository) locate(id string) (*userPartition, int, bool) {
+	userID, exists := r.owners[id]
+	if !exists {
+		return nil, 0, false
+	}
+
+	partition := r.partitions[userID]
+	return partition, partition.positions[id], true
+}
+
+func (r *transactionRepository) delete(id string) {
+	userID := r.owners[id]
+	partition := r.partitions[userID]
+
+	partition.remove(partition.positions[id])
+	delete(r.owners, id)
+
+	if len(partition.transactions) == 0 {
+		delete(r.partitions, userID)
+	}
+}
+
+func (r *transactionRepository) deleteBatch(batchID string) int {
+	userID, exists := r.batches[batchID]
+	if !exists {
+		return 0
+	}
+	delete(r.batches, batchID)
+
+	// Single deletes leave the batch entry behind, so the partition may
+	// already be gone.
+	partition, exists := r.partitions[userID]
+	if !exists {
+		return 0
+	}
+
+	removed := partition.removeFunc(func(tx *domain.Transaction) bool {
+		return tx.BatchID == batchID
+	})
+	for _, id := range removed {
+		delete(r.owners, id)
+	}
+
+	if len(partition.transactions) == 0 {
+		delete(r.partitions, userID)
+	}
+
+	return len(removed)
+}
+
+// GetAll returns a copy of every transaction, grouped by user.
+func (r *transactionRepository) GetAll() ([]domain.Transaction, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.transactions
+
+	userIDs := make([]string, 0, len(r.partitions))
+	total := 0
+	for userID, partition := range r.partitions {
+		userIDs = append(userIDs, userID)
+		total += len(partition.transactions)
+	}
+	slices.Sort(userIDs)
+
+	transactions := make([]domain.Transaction, 0, total)
+	for _, userID := range userIDs {
+		transactions = append(transactions, r.partitions[userID].transactions...)
+	}
+
+	return transactions, nil
 }
 
-func (r *transactionRepository) GetAllByUserID(userID string) []domain.Transaction {
-	allTransactions := r.GetAll()
-	var userTransactions []domain.Transaction
-	for _, tx := range allTransactions {
-		if tx.UserID == userID {
-			userTransactions = append(userTransactions, tx)
-		}
+func (r *transactionRepository) GetAllByUserID(userID string) ([]domain.Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	partition, exists := r.partitions[userID]
+	if !exists {
+		return nil, nil
 	}
-	return userTransactions
+
+	return slices.Clone(partition.transactions), nil
 }
 
-func (r *transactionRepository) GetAllIssues(userID string, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO) ([]domain.Transaction, int, error) {
-	userTransactions := r.GetAllByUserID(userID)
-	var issues []domain.Transaction
+func (r *transactionRepository) CountFingerprints(userID string, transactions []domain.Transaction) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	for _, tx := range userTransactions {
-		if tx.Status == domain.TransactionStatusFailed || tx.Status == domain.TransactionStatusPending {
-			issues = append(issues, tx)
-		}
+	counts := make(map[string]int)
+
+	partition, exists := r.partitions[userID]
+	if !exists || len(transactions) == 0 {
+		return counts, nil
 	}
 
-	if sorting.SortBy == "" {
-		sorting.SortBy = "timestamp"
+	wanted := make(map[string]bool, len(transactions))
+	for index := range transactions {
+		wanted[transactions[index].Fingerprint()] = true
 	}
 
-	if sorting.Sort == "" {
-		sorting.Sort = "ASC"
+	// Duplicates share their timestamp, so only the stored rows within the
+	// time span of transactions need fingerprinting.
+	for _, position := range partition.find(fingerprintSpan(userID, transactions)) {
+		if fingerprint := partition.transactions[position].Fingerprint(); wanted[fingerprint] {
+			counts[fingerprint]++
+		}
 	}
 
-	err := r.sortTransactions(issues, sorting.Sort, sorting.SortBy)
+	return counts, nil
+}
+
+func (r *transactionRepository) Search(filter domain.TransactionFilter, pagination dto_transaction.PaginationDTO, sorting dto_transaction.SortingDTO, cursor *domain.TransactionCursor) ([]domain.Transaction, int, error) {
+	keys, err := sorting.Keys()
 	if err != nil {
 		return nil, 0, err
 	}
@@ -92,124 +301,355 @@ func (r *transactionRepository) GetAllIssues(userID string, pagination dto_trans
 		pagination.Limit = 10
 	}
 
-	total := len(issues)
-	start := (pagination.Page - 1) * pagination.Limit
-	end := start + pagination.Limit
-	if start >= total {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	partition, exists := r.partitions[filter.UserID]
+	if !exists {
 		return make([]domain.Transaction, 0), 0, nil
 	}
 
-	if end > total {
-		end = total
+	positions := partition.find(filter)
+
+	compare := func(a *domain.Transaction, b *domain.Transaction) int {
+		if order := compareTransactions(a, b, keys); order != 0 {
+			return order
+		}
+		return strings.Compare(a.ID, b.ID)
+	}
+
+	// The index is already in (timestamp, ID) order. Anything else is sorted
+	// with the ID as the final tie-breaker, so pages never shift between
+	// requests and a cursor always has a single position.
+	if !slices.Equal(keys, []dto_transaction.SortKey{{Field: "timestamp"}}) {
+		slices.SortFunc(positions, func(a int, b int) int {
+			return compare(&partition.transactions[a], &partition.transactions[b])
+		})
+	}
+
+	total := len(positions)
+	start := (pagination.Page - 1) * pagination.Limit
+
+	if cursor != nil {
+		index, found := slices.BinarySearchFunc(positions, &cursor.Anchor, func(position int, anchor *domain.Transaction) int {
+			return compare(&partition.transactions[position], anchor)
+		})
+
+		if cursor.Backward {
+			start = max(index-pagination.Limit, 0)
+			positions = positions[:index]
+		} else if found {
+			start = index + 1
+		} else {
+			start = index
+		}
 	}
 
-	return issues[start:end], total, nil
+	if start >= len(positions) {
+		return make([]domain.Transaction, 0), total, nil
+	}
+
+	end := min(start+pagination.Limit, len(positions))
+
+	page := make([]domain.Transaction, 0, end-start)
+	for _, position := range positions[start:end] {
+		page = append(page, partition.transactions[position])
+	}
+
+	return page, total, nil
 }
 
-func (r *transactionRepository) Clear() {
+func (r *transactionRepository) Clear() error {
+	defer r.journal.Begin()()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.transactions = make([]domain.Transaction, 0)
+
+	if err := r.journal.Append(transactionStore, journalOpClear, nil); err != nil {
+		return err
+	}
+
+	r.partitions = make(map[string]*userPartition)
+	r.owners = make(map[string]string)
+	r.batches = make(map[string]string)
+	return nil
 }
 
-func (r *transactionRepository) sortTransactions(transactions []domain.Transaction, sort dto_transaction.SortDirection, sortBy string) error {
-	if len(transactions) == 0 {
-		return nil
+func (p *userPartition) add(transactions []domain.Transaction) {
+	start := len(p.transactions)
+	p.transactions = append(p.transactions, transactions...)
+
+	touched := make(map[domain.TransactionStatus]bool)
+	for position := start; position < len(p.transactions); position++ {
+		p.positions[p.transactions[position].ID] = position
+
+		status := p.transactions[position].Status
+		p.byStatus[status] = append(p.byStatus[status], position)
+		touched[status] = true
+	}
+
+	// New uploads are mostly later than what is already there, which pdqsort
+	// handles in close to linear time.
+	for status := range touched {
+		slices.SortFunc(p.byStatus[status], p.compareByTimestamp)
+	}
+}
+
+// update replaces the transaction at position, moving it between the status
+// indexes if its status or timestamp changed.
+func (p *userPartition) update(position int, transaction domain.Transaction) {
+	previous := p.transactions[position]
+
+	if previous.Status == transaction.Status && previous.Timestamp.Equal(transaction.Timestamp) {
+		p.transactions[position] = transaction
+		return
 	}
 
-	ascending := strings.ToUpper(string(sort)) != "DESC"
-
-	for i := 0; i < len(transactions)-1; i++ {
-		for j := i + 1; j < len(transactions); j++ {
-			shouldSwap := false
-
-			switch strings.ToLower(sortBy) {
-			case "timestamp":
-				if ascending {
-					shouldSwap = transactions[i].Timestamp.After(transactions[j].Timestamp)
-				} else {
-					shouldSwap = transactions[i].Timestamp.Before(transactions[j].Timestamp)
-				}
-			case "name":
-				if ascending {
-					shouldSwap = transactions[i].Name > transactions[j].Name
-				} else {
-					shouldSwap = transactions[i].Name < transactions[j].Name
-				}
-			case "amount":
-				if ascending {
-					shouldSwap = transactions[i].Amount > transactions[j].Amount
-				} else {
-					shouldSwap = transactions[i].Amount < transactions[j].Amount
-				}
-			case "type":
-				if ascending {
-					shouldSwap = transactions[i].Type > transactions[j].Type
-				} else {
-					shouldSwap = transactions[i].Type < transactions[j].Type
-				}
-			case "status":
-				if ascending {
-					shouldSwap = transactions[i].Status > transactions[j].Status
-				} else {
-					shouldSwap = transactions[i].Status < transactions[j].Status
-				}
-			default:
-				return fmt.Errorf("invalid sortBy field: %s", sortBy)
+	index := p.byStatus[previous.Status]
+	at := slices.Index(index, position)
+	p.byStatus[previous.Status] = slices.Delete(index, at, at+1)
+
+	p.transactions[position] = transaction
+
+	index = p.byStatus[transaction.Status]
+	insertAt, _ := slices.BinarySearchFunc(index, position, p.compareByTimestamp)
+	p.byStatus[transaction.Status] = slices.Insert(index, insertAt, position)
+}
+
+// remove deletes the transaction at position. Later transactions move down
+// by one, so their positions are renumbered in every index.
+func (p *userPartition) remove(position int) {
+	delete(p.positions, p.transactions[position].ID)
+	p.transactions = slices.Delete(p.transactions, position, position+1)
+
+	for index := position; index < len(p.transactions); index++ {
+		p.positions[p.transactions[index].ID] = index
+	}
+
+	for status, index := range p.byStatus {
+		renumbered := index[:0]
+		for _, existing := range index {
+			if existing == position {
+				continue
+			}
+			if existing > position {
+				existing--
 			}
+			renumbered = append(renumbered, existing)
+		}
+		p.byStatus[status] = renumbered
+	}
+}
 
-			if shouldSwap {
-				transactions[i], transactions[j] = transactions[j], transactions[i]
+// removeFunc deletes every transaction drop reports true for, in one pass
+// over the indexes, and returns their IDs.
+func (p *userPartition) removeFunc(drop func(tx *domain.Transaction) bool) []string {
+	var removed []string
+	moved := make([]int, len(p.transactions))
+
+	kept := p.transactions[:0]
+	for position := range p.transactions {
+		tx := p.transactions[position]
+		if drop(&tx) {
+			removed = append(removed, tx.ID)
+			delete(p.positions, tx.ID)
+			moved[position] = -1
+			continue
+		}
+
+		moved[position] = len(kept)
+		p.positions[tx.ID] = len(kept)
+		kept = append(kept, tx)
+	}
+	clear(p.transactions[len(kept):])
+	p.transactions = kept
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	// Renumbering keeps each index in order, since kept rows don't swap.
+	for status, index := range p.byStatus {
+		renumbered := index[:0]
+		for _, existing := range index {
+			if moved[existing] >= 0 {
+				renumbered = append(renumbered, moved[existing])
 			}
 		}
+		p.byStatus[status] = renumbered
 	}
 
-	return nil
+	return removed
 }
 
-func (r *transactionRepository) validateRecord(record []string) error {
-	if len(record) != 6 {
-		return fmt.Errorf("expected 6 fields, got %d", len(record))
+func (p *userPartition) compareByTimestamp(a int, b int) int {
+	if order := p.transactions[a].Timestamp.Compare(p.transactions[b].Timestamp); order != 0 {
+		return order
 	}
 
-	if strings.TrimSpace(record[0]) == "" {
-		return fmt.Errorf("timestamp is required")
+	return strings.Compare(p.transactions[a].ID, p.transactions[b].ID)
+}
+
+// find returns the positions of the transactions matching filter, in
+// (timestamp, ID) order. Only the indexes of the requested statuses are
+// read, and since they are sorted by timestamp, the date range is found by
+// binary search. The remaining criteria are checked row by row.
+func (p *userPartition) find(filter domain.TransactionFilter) []int {
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		statuses = slices.Collect(maps.Keys(p.byStatus))
 	}
-	if strings.TrimSpace(record[1]) == "" {
-		return fmt.Errorf("name is required")
+
+	positions := make([]int, 0)
+	for _, status := range statuses {
+		positions = p.merge(positions, p.between(p.byStatus[status], filter.From, filter.To))
 	}
-	if strings.TrimSpace(record[2]) == "" {
-		return fmt.Errorf("type is required")
+
+	name := foldASCII(filter.Name)
+	terms := make([]string, 0, len(filter.DescriptionTerms))
+	for _, term := range filter.DescriptionTerms {
+		terms = append(terms, foldASCII(term))
 	}
-	if strings.TrimSpace(record[3]) == "" {
-		return fmt.Errorf("amount is required")
+
+	matches := func(tx *domain.Transaction) bool {
+		if len(filter.Types) > 0 && !slices.Contains(filter.Types, tx.Type) {
+			return false
+		}
+		if filter.MinAmount != nil && tx.Amount < *filter.MinAmount {
+			return false
+		}
+		if filter.MaxAmount != nil && tx.Amount > *filter.MaxAmount {
+			return false
+		}
+		if name != "" && foldASCII(tx.Name) != name {
+			return false
+		}
+		return len(terms) == 0 || containsAll(foldASCII(tx.Description), terms)
 	}
-	if strings.TrimSpace(record[4]) == "" {
-		return fmt.Errorf("status is required")
+
+	return slices.DeleteFunc(positions, func(position int) bool {
+		return !matches(&p.transactions[position])
+	})
+}
+
+// between narrows a timestamp sorted position index to the timestamps from
+// from to to, inclusive. Zero bounds are open.
+func (p *userPartition) between(index []int, from time.Time, to time.Time) []int {
+	start, end := 0, len(index)
+
+	if !from.IsZero() {
+		start = sort.Search(len(index), func(i int) bool {
+			return !p.transactions[index[i]].Timestamp.Before(from)
+		})
 	}
-	if strings.TrimSpace(record[5]) == "" {
-		return fmt.Errorf("description is required")
+
+	if !to.IsZero() {
+		end = sort.Search(len(index), func(i int) bool {
+			return p.transactions[index[i]].Timestamp.After(to)
+		})
 	}
 
-	// Validate type
-	txType := strings.ToUpper(strings.TrimSpace(record[2]))
-	if txType != string(domain.TransactionTypeDebit) && txType != string(domain.TransactionTypeCredit) {
-		return fmt.Errorf("invalid type")
+	if start >= end {
+		return nil
 	}
 
-	status := strings.ToUpper(strings.TrimSpace(record[4]))
-	if status != string(domain.TransactionStatusSuccess) && status != string(domain.TransactionStatusFailed) && status != string(domain.TransactionStatusPending) {
-		return fmt.Errorf("invalid status")
+	return index[start:end]
+}
+
+// merge merges two timestamp sorted position indexes into one.
+func (p *userPartition) merge(a []int, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if p.compareByTimestamp(b[0], a[0]) < 0 {
+			merged = append(merged, b[0])
+			b = b[1:]
+		} else {
+			merged = append(merged, a[0])
+			a = a[1:]
+		}
 	}
 
-	timestamp, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
-	if err != nil || timestamp <= 0 {
-		return fmt.Errorf("invalid timestamp")
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// fingerprintSpan selects the user's transactions in the whole seconds
+// spanned by transactions, which fingerprints are taken at.
+func fingerprintSpan(userID string, transactions []domain.Transaction) domain.TransactionFilter {
+	from, to := transactions[0].Timestamp, transactions[0].Timestamp
+	for index := range transactions {
+		if timestamp := transactions[index].Timestamp; timestamp.Before(from) {
+			from = timestamp
+		} else if timestamp.After(to) {
+			to = timestamp
+		}
+	}
+
+	return domain.TransactionFilter{
+		UserID: userID,
+		From:   from.Truncate(time.Second),
+		To:     to.Truncate(time.Second).Add(time.Second - 1),
+	}
+}
+
+// compareTransactions orders a and b by each of keys in turn.
+func compareTransactions(a *domain.Transaction, b *domain.Transaction, keys []dto_transaction.SortKey) int {
+	for _, key := range keys {
+		var order int
+
+		switch key.Field {
+		case "timestamp":
+			order = a.Timestamp.Compare(b.Timestamp)
+		case "name":
+			order = strings.Compare(a.Name, b.Name)
+		case "type":
+			order = strings.Compare(string(a.Type), string(b.Type))
+		case "amount":
+			order = cmp.Compare(a.Amount, b.Amount)
+		case "status":
+			order = strings.Compare(string(a.Status), string(b.Status))
+		}
+
+		if key.Descending {
+			order = -order
+		}
+
+		if order != 0 {
+			return order
+		}
 	}
 
-	amount, err := strconv.ParseInt(strings.TrimSpace(record[3]), 10, 64)
-	if err != nil || amount < 0 {
-		return fmt.Errorf("invalid amount")
+	return 0
+}
+
+// foldASCII lowers ASCII letters only, the way SQLite's LIKE and NOCASE
+// compare text, so both backends match the same rows.
+func foldASCII(text string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, text)
+}
+
+func containsAll(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateTransactions applies Transaction.Validate to every transaction,
+// so each storage backend accepts exactly the same data.
+func validateTransactions(transactions []domain.Transaction) error {
+	for index := range transactions {
+		if err := transactions[index].Validate(); err != nil {
+			return fmt.Errorf("line %d: %w", index, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
+	"firstpersoncode/go-uploader/internal/util"
+)
+
+type uploadBatchRepository struct {
+	mu      sync.RWMutex
+	batches map[string]*domain.UploadBatch
+	// transactions holds the batches' transactions, which RollBack deletes.
+	transactions *transactionRepository
+	journal      *journal.Journal
+}
+
+func newUploadBatchRepository(transactions *transactionRepository, j *journal.Journal) *uploadBatchRepository {
+	return &uploadBatchRepository{
+		batches:      make(map[string]*domain.UploadBatch),
+		transactions: transactions,
+		journal:      j,
+	}
+}
+
+func (r *uploadBatchRepository) Save(batch *domain.UploadBatch) (*domain.UploadBatch, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if batch.UserID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+
+	if err := r.checkIdempotencyKey(batch); err != nil {
+		return nil, err
+	}
+
+	saved := copyUploadBatch(batch)
+	saved.ID = util.GenerateRandomID()
+
+	if err := r.journal.Append(uploadStore, journalOpPut, saved); err != nil {
+		return nil, err
+	}
+
+	r.batches[saved.ID] = saved
+	batch.ID = saved.ID
+	return batch, nil
+}
+
+func (r *uploadBatchRepository) Update(batch *domain.UploadBatch) (*domain.UploadBatch, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.batches[batch.ID]; !exists {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	if err := r.checkIdempotencyKey(batch); err != nil {
+		return nil, err
+	}
+
+	updated := copyUploadBatch(batch)
+	if err := r.journal.Append(uploadStore, journalOpPut, updated); err != nil {
+		return nil, err
+	}
+
+	r.batches[batch.ID] = updated
+	return batch, nil
+}
+
+func (r *uploadBatchRepository) RollBack(id string) (int, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.batches[id]; !exists {
+		return 0, domain.ErrUploadNotFound
+	}
+
+	if err := r.journal.Append(uploadStore, journalOpRollBack, id); err != nil {
+		return 0, err
+	}
+
+	return r.rollBack(id), nil
+}
+
+// rollBack marks the batch rolled back and deletes its transactions, under
+// both repositories' locks so no one sees one without the other.
+func (r *uploadBatchRepository) rollBack(id string) int {
+	rolledBack := copyUploadBatch(r.batches[id])
+	rolledBack.Status = domain.UploadStatusRolledBack
+	r.batches[id] = rolledBack
+
+	r.transactions.mu.Lock()
+	defer r.transactions.mu.Unlock()
+
+	return r.transactions.deleteBatch(id)
+}
+
+func (r *uploadBatchRepository) FindByID(id string) (*domain.UploadBatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batch, exists := r.batches[id]
+	if !exists {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	return copyUploadBatch(batch), nil
+}
+
+func (r *uploadBatchRepository) FindByIdempotencyKey(userID string, key string) (*domain.UploadBatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, batch := range r.batches {
+		if batch.UserID == userID && batch.IdempotencyKey == key && key != "" {
+			return copyUploadBatch(batch), nil
+		}
+	}
+
+	return nil, domain.ErrUploadNotFound
+}
+
+func (r *uploadBatchRepository) FindByChecksum(userID string, checksum string) ([]*domain.UploadBatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batches := make([]*domain.UploadBatch, 0)
+	for _, batch := range r.batches {
+		if batch.UserID == userID && batch.Checksum == checksum {
+			batches = append(batches, copyUploadBatch(batch))
+		}
+	}
+
+	slices.SortFunc(batches, compareUploadBatches)
+	return batches, nil
+}
+
+func (r *uploadBatchRepository) FindByUserID(userID string, page int, limit int) ([]*domain.UploadBatch, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batches := make([]*domain.UploadBatch, 0)
+	for _, batch := range r.batches {
+		if batch.UserID == userID {
+			batches = append(batches, batch)
+		}
+	}
+
+	slices.SortFunc(batches, compareUploadBatches)
+
+	total := len(batches)
+	start := (page - 1) * limit
+	if start >= total {
+		return make([]*domain.UploadBatch, 0), total, nil
+	}
+
+	end := min(start+limit, total)
+	listed := make([]*domain.UploadBatch, 0, end-start)
+	for _, batch := range batches[start:end] {
+		listed = append(listed, copyUploadBatch(batch))
+	}
+
+	return listed, total, nil
+}
+
+func (r *uploadBatchRepository) checkIdempotencyKey(batch *domain.UploadBatch) error {
+	if batch.IdempotencyKey == "" {
+		return nil
+	}
+
+	for _, existing := range r.batches {
+		if existing.ID != batch.ID && existing.UserID == batch.UserID && existing.IdempotencyKey == batch.IdempotencyKey {
+			return fmt.Errorf("%w: it was used by upload %s", domain.ErrIdempotencyKeyConflict, existing.ID)
+		}
+	}
+
+	return nil
+}
+
+// compareUploadBatches orders the newest batch first, then by ID, the order
+// the SQLite repository lists them in.
+func compareUploadBatches(a *domain.UploadBatch, b *domain.UploadBatch) int {
+	if order := b.UploadedAt.Compare(a.UploadedAt); order != 0 {
+		return order
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+// copyUploadBatch copies the batch, balances included, so the stored batch
+// and the callers' never share anything.
+func copyUploadBatch(batch *domain.UploadBatch) *domain.UploadBatch {
+	copied := *batch
+	copied.Balances = nil
+	for _, balance := range batch.Balances {
+		if balance.Opening != nil {
+			opening := *balance.Opening
+			balance.Opening = &opening
+		}
+		if balance.Closing != nil {
+			closing := *balance.Closing
+			balance.Closing = &closing
+		}
+		copied.Balances = append(copied.Balances, balance)
+	}
+
+	return &copied
+}
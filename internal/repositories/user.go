@@ -2,15 +2,19 @@ package repositories
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
 
 	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/journal"
 	"firstpersoncode/go-uploader/internal/util"
 )
 
 type userRepository struct {
-	mu    sync.RWMutex
-	users map[string]*domain.User
+	mu      sync.RWMutex
+	users   map[string]*domain.User
+	journal *journal.Journal
 }
 
 func NewUserRepository() domain.UserRepository {
@@ -20,6 +24,7 @@ func NewUserRepository() domain.UserRepository {
 }
 
 func (r *userRepository) Save(user *domain.User) (*domain.User, error) {
+	defer r.journal.Begin()()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -37,9 +42,39 @@ func (r *userRepository) Save(user *domain.User) (*domain.User, error) {
 		return nil, fmt.Errorf("user password is required")
 	}
 
-	user.ID = util.GenerateRandomID()
+	stored := copyUser(user)
+	stored.ID = util.GenerateRandomID()
 
-	r.users[user.ID] = user
+	if err := r.journal.Append(userStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.users[stored.ID] = stored
+	user.ID = stored.ID
+	return user, nil
+}
+
+func (r *userRepository) Update(user *domain.User) (*domain.User, error) {
+	defer r.journal.Begin()()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.ID]; !exists {
+		return nil, domain.ErrUserNotFound
+	}
+
+	for _, record := range r.users {
+		if record.ID != user.ID && user.Username == record.Username {
+			return nil, fmt.Errorf("username already exists")
+		}
+	}
+
+	stored := copyUser(user)
+	if err := r.journal.Append(userStore, journalOpPut, stored); err != nil {
+		return nil, err
+	}
+
+	r.users[user.ID] = stored
 	return user, nil
 }
 
@@ -49,10 +84,10 @@ func (r *userRepository) FindByID(id string) (*domain.User, error) {
 
 	user, exists := r.users[id]
 	if !exists {
-		return nil, fmt.Errorf("user not found")
+		return nil, domain.ErrUserNotFound
 	}
 
-	return user, nil
+	return copyUser(user), nil
 }
 
 func (r *userRepository) FindByUsername(username string) (*domain.User, error) {
@@ -61,9 +96,44 @@ func (r *userRepository) FindByUsername(username string) (*domain.User, error) {
 
 	for _, user := range r.users {
 		if user.Username == username {
-			return user, nil
+			return copyUser(user), nil
 		}
 	}
 
-	return nil, fmt.Errorf("user not found")
+	return nil, domain.ErrUserNotFound
+}
+
+func (r *userRepository) FindAll(page int, limit int) ([]*domain.User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*domain.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, copyUser(user))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	total := len(users)
+	start := (page - 1) * limit
+	if start >= total {
+		return make([]*domain.User, 0), total, nil
+	}
+
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	return users[start:end], total, nil
+}
+
+// copyUser copies a user, recovery codes included, so the stored user and
+// the callers' never share anything.
+func copyUser(user *domain.User) *domain.User {
+	copied := *user
+	copied.MFARecoveryCodes = slices.Clone(user.MFARecoveryCodes)
+	return &copied
 }
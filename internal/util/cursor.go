@@ -0,0 +1,81 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"firstpersoncode/go-uploader/internal/config"
+)
+
+// CursorSigner turns pagination state into opaque tokens that clients can
+// hand back but not forge or alter. A token is the base64url encoded JSON
+// payload and its HMAC-SHA256, separated by a dot.
+type CursorSigner struct {
+	secret []byte
+}
+
+// LoadCursorSigner returns a signer for CURSOR_SECRET. Outside development
+// the secret is required, as cursors signed with a made up one stop working
+// on a restart and aren't accepted by other instances.
+func LoadCursorSigner(cfg config.App) (*CursorSigner, error) {
+	secret := cfg.CursorSecret
+	if secret == "" {
+		if cfg.Env != config.EnvDevelopment {
+			return nil, fmt.Errorf("CURSOR_SECRET is required unless APP_ENV=%s", config.EnvDevelopment)
+		}
+
+		log.Printf("CURSOR_SECRET is not set, signing cursors with an ephemeral key that will not survive a restart")
+		secret = GenerateRandomID() + GenerateRandomID()
+	}
+
+	return NewCursorSigner(secret), nil
+}
+
+func NewCursorSigner(secret string) *CursorSigner {
+	return &CursorSigner{secret: []byte(secret)}
+}
+
+func (c *CursorSigner) Sign(payload any) (string, error) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(content)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.mac(encoded)), nil
+}
+
+// Verify checks the token's signature and decodes its payload into payload.
+func (c *CursorSigner) Verify(token string, payload any) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return fmt.Errorf("malformed cursor")
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.mac(encoded)) {
+		return fmt.Errorf("invalid cursor signature")
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("malformed cursor")
+	}
+
+	if err := json.Unmarshal(content, payload); err != nil {
+		return fmt.Errorf("malformed cursor")
+	}
+
+	return nil
+}
+
+func (c *CursorSigner) mac(encoded string) []byte {
+	hash := hmac.New(sha256.New, c.secret)
+	hash.Write([]byte(encoded))
+	return hash.Sum(nil)
+}
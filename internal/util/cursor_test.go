@@ -0,0 +1,88 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"firstpersoncode/go-uploader/internal/config"
+)
+
+type testCursor struct {
+	ID    string `json:"id"`
+	Value int64  `json:"v"`
+}
+
+func TestCursorSigner_RoundTrip(t *testing.T) {
+	signer := NewCursorSigner("secret")
+
+	token, err := signer.Sign(testCursor{ID: "abc", Value: 42})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var decoded testCursor
+	if err := signer.Verify(token, &decoded); err != nil {
+		t.Fatalf("expected the cursor to verify, got %v", err)
+	}
+
+	if decoded.ID != "abc" || decoded.Value != 42 {
+		t.Errorf("expected the payload back, got %+v", decoded)
+	}
+}
+
+func TestCursorSigner_Rejected(t *testing.T) {
+	signer := NewCursorSigner("secret")
+
+	token, err := signer.Sign(testCursor{ID: "abc", Value: 42})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	forged, err := NewCursorSigner("other").Sign(testCursor{ID: "abc", Value: 42})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	tampered, _ := signer.Sign(testCursor{ID: "abd", Value: 42})
+	tamperedPayload, _, _ := strings.Cut(tampered, ".")
+
+	for _, bad := range []string{
+		"",
+		"not-a-cursor",
+		encoded,
+		forged,
+		tamperedPayload + "." + signature,
+		encoded + "." + signature + "x",
+	} {
+		var decoded testCursor
+		if err := signer.Verify(bad, &decoded); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLoadCursorSigner(t *testing.T) {
+	if _, err := LoadCursorSigner(config.App{Env: "production"}); err == nil {
+		t.Error("expected a missing secret to be refused outside development")
+	}
+
+	signer, err := LoadCursorSigner(config.App{Env: config.EnvDevelopment})
+	if err != nil {
+		t.Fatalf("expected an ephemeral secret in development, got %v", err)
+	}
+
+	configured, err := LoadCursorSigner(config.App{Env: "production", CursorSecret: "secret"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	token, _ := NewCursorSigner("secret").Sign(testCursor{ID: "abc"})
+	var decoded testCursor
+	if err := configured.Verify(token, &decoded); err != nil {
+		t.Errorf("expected the configured secret to be used, got %v", err)
+	}
+	if err := signer.Verify(token, &decoded); err == nil {
+		t.Error("expected the ephemeral secret not to be the configured one")
+	}
+}
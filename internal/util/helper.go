@@ -2,6 +2,7 @@ package util
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 )
 
@@ -10,3 +11,10 @@ func GenerateRandomID() string {
 	rand.Read(bytes)
 	return hex.EncodeToString(bytes)
 }
+
+// HashToken hashes a high-entropy secret for storage. Plain SHA-256 is enough
+// here because the input is random, unlike a user-chosen password.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
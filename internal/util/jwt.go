@@ -1,47 +1,284 @@
 package util
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
 	"time"
 
+	"firstpersoncode/go-uploader/internal/config"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-this-in-production")
-
 type JWTClaims struct {
 	Sub string `json:"sub"`
+	// Purpose is empty on access tokens. Other tokens, like the MFA challenge,
+	// name what they are for so they can't be replayed as an access token.
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(sessionID string, expiry time.Time) (string, error) {
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// PublicKey is a verification key that can be shared with other services.
+type PublicKey struct {
+	ID        string
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+type KeySet struct {
+	issuer   string
+	audience string
+	active   *signingKey
+	keys     map[string]*signingKey
+}
+
+var loadDefaultKeySet = sync.OnceValues(func() (*KeySet, error) {
+	cfg := config.Get()
+	return NewKeySet(cfg.JWT, cfg.App.Env)
+})
+
+// NewKeySet loads the keys in cfg. Outside development JWT_KEYS is
+// required, as tokens signed with a made up key stop working on a restart,
+// aren't accepted by other instances and can't be published in the JWKS.
+func NewKeySet(cfg config.JWT, env string) (*KeySet, error) {
+	keySet := &KeySet{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		keys:     make(map[string]*signingKey),
+	}
+
+	if len(cfg.Keys) == 0 {
+		if env != config.EnvDevelopment {
+			return nil, fmt.Errorf("JWT_KEYS is required unless APP_ENV=%s", config.EnvDevelopment)
+		}
+
+		log.Printf("JWT_KEYS is not set, signing tokens with an ephemeral key that will not survive a restart")
+		cfg.Keys = []config.JWTKey{{
+			ID:        GenerateRandomID(),
+			Algorithm: jwt.SigningMethodHS256.Alg(),
+			Secret:    GenerateRandomID() + GenerateRandomID(),
+		}}
+		cfg.ActiveKeyID = cfg.Keys[0].ID
+	}
+
+	for _, keyConfig := range cfg.Keys {
+		key, err := loadSigningKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %v", keyConfig.ID, err)
+		}
+
+		if _, exists := keySet.keys[key.id]; exists {
+			return nil, fmt.Errorf("jwt key %q is defined twice", key.id)
+		}
+
+		keySet.keys[key.id] = key
+	}
+
+	activeKeyID := cfg.ActiveKeyID
+	if activeKeyID == "" {
+		activeKeyID = cfg.Keys[0].ID
+	}
+
+	active, exists := keySet.keys[activeKeyID]
+	if !exists {
+		return nil, fmt.Errorf("active jwt key %q is not configured", activeKeyID)
+	}
+
+	if active.signKey == nil {
+		return nil, fmt.Errorf("active jwt key %q has no private key", activeKeyID)
+	}
+
+	keySet.active = active
+	return keySet, nil
+}
+
+func loadSigningKey(keyConfig config.JWTKey) (*signingKey, error) {
+	if keyConfig.ID == "" {
+		return nil, fmt.Errorf("key id is required")
+	}
+
+	key := &signingKey{id: keyConfig.ID}
+
+	switch keyConfig.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if keyConfig.Secret == "" {
+			return nil, fmt.Errorf("secret is required")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(keyConfig.Secret)
+		key.verifyKey = key.signKey
+
+	case jwt.SigningMethodRS256.Alg():
+		pemBytes, err := os.ReadFile(keyConfig.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.method = jwt.SigningMethodRS256
+		if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+			key.signKey = privateKey
+			key.verifyKey = &privateKey.PublicKey
+		} else if publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+			key.verifyKey = publicKey
+		} else {
+			return nil, fmt.Errorf("invalid RSA key in %s", keyConfig.KeyFile)
+		}
+
+	case jwt.SigningMethodEdDSA.Alg():
+		pemBytes, err := os.ReadFile(keyConfig.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.method = jwt.SigningMethodEdDSA
+		if privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes); err == nil {
+			key.signKey = privateKey
+			key.verifyKey = privateKey.(ed25519.PrivateKey).Public()
+		} else if publicKey, err := jwt.ParseEdPublicKeyFromPEM(pemBytes); err == nil {
+			key.verifyKey = publicKey
+		} else {
+			return nil, fmt.Errorf("invalid Ed25519 key in %s", keyConfig.KeyFile)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", keyConfig.Algorithm)
+	}
+
+	return key, nil
+}
+
+func (k *KeySet) Sign(subject string, expiry time.Time) (string, error) {
+	return k.SignPurpose(subject, "", expiry)
+}
+
+func (k *KeySet) SignPurpose(subject string, purpose string, expiry time.Time) (string, error) {
 	claims := JWTClaims{
-		Sub: sessionID,
+		Sub:     subject,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    k.issuer,
+			Audience:  jwt.ClaimStrings{k.audience},
 			ExpiresAt: jwt.NewNumericDate(expiry),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.signKey)
 }
 
-func ValidateJWT(tokenString string) (*JWTClaims, error) {
+func (k *KeySet) Validate(tokenString string) (*JWTClaims, error) {
+	return k.ValidatePurpose(tokenString, "")
+}
+
+func (k *KeySet) ValidatePurpose(tokenString string, purpose string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, exists := k.keys[kid]
+		if !exists {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+
+		// Pinning the method to the key stops a token from claiming e.g. HS256
+		// and being checked against an RSA public key used as an HMAC secret.
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
-	})
+
+		return key.verifyKey, nil
+	},
+		jwt.WithIssuer(k.issuer),
+		jwt.WithAudience(k.audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid && claims.Purpose == purpose {
 		return claims, nil
 	}
 
 	return nil, fmt.Errorf("invalid token")
 }
+
+// PublicKeys returns the verification keys of every asymmetric key in the
+// set. HMAC secrets are never exposed.
+func (k *KeySet) PublicKeys() []PublicKey {
+	publicKeys := make([]PublicKey, 0, len(k.keys))
+
+	for _, key := range k.keys {
+		switch verifyKey := key.verifyKey.(type) {
+		case *rsa.PublicKey, ed25519.PublicKey:
+			publicKeys = append(publicKeys, PublicKey{
+				ID:        key.id,
+				Algorithm: key.method.Alg(),
+				Key:       verifyKey,
+			})
+		}
+	}
+
+	sort.Slice(publicKeys, func(i, j int) bool {
+		return publicKeys[i].ID < publicKeys[j].ID
+	})
+
+	return publicKeys
+}
+
+func GenerateJWT(sessionID string, expiry time.Time) (string, error) {
+	keySet, err := loadDefaultKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	return keySet.Sign(sessionID, expiry)
+}
+
+func ValidateJWT(tokenString string) (*JWTClaims, error) {
+	keySet, err := loadDefaultKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	return keySet.Validate(tokenString)
+}
+
+func GeneratePurposeJWT(subject string, purpose string, expiry time.Time) (string, error) {
+	keySet, err := loadDefaultKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	return keySet.SignPurpose(subject, purpose, expiry)
+}
+
+func ValidatePurposeJWT(tokenString string, purpose string) (*JWTClaims, error) {
+	keySet, err := loadDefaultKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	return keySet.ValidatePurpose(tokenString, purpose)
+}
+
+func JWTPublicKeys() ([]PublicKey, error) {
+	keySet, err := loadDefaultKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	return keySet.PublicKeys(), nil
+}
@@ -0,0 +1,191 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"firstpersoncode/go-uploader/internal/config"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), GenerateRandomID()+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	return path
+}
+
+func writeRSAKey(t *testing.T) (privatePath string, publicPath string) {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal RSA public key: %v", err)
+	}
+
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey)), writePEM(t, "PUBLIC KEY", publicDER)
+}
+
+func writeEd25519Key(t *testing.T) string {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal Ed25519 key: %v", err)
+	}
+
+	return writePEM(t, "PRIVATE KEY", der)
+}
+
+func TestKeySet_SignAndValidate(t *testing.T) {
+	rsaPrivate, _ := writeRSAKey(t)
+
+	keys := map[string]config.JWTKey{
+		"HS256": {ID: "hmac", Algorithm: "HS256", Secret: "a-long-enough-test-secret-value"},
+		"RS256": {ID: "rsa", Algorithm: "RS256", KeyFile: rsaPrivate},
+		"EdDSA": {ID: "ed", Algorithm: "EdDSA", KeyFile: writeEd25519Key(t)},
+	}
+
+	for alg, key := range keys {
+		t.Run(alg, func(t *testing.T) {
+			keySet, err := NewKeySet(config.JWT{Issuer: "iss", Audience: "aud", Keys: []config.JWTKey{key}}, config.EnvDevelopment)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			token, err := keySet.Sign("session-id", time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+
+			claims, err := keySet.Validate(token)
+			if err != nil {
+				t.Fatalf("expected token to validate, got %v", err)
+			}
+
+			if claims.Sub != "session-id" {
+				t.Errorf("expected subject 'session-id', got %s", claims.Sub)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	rsaPrivate, rsaPublic := writeRSAKey(t)
+	edPrivate := writeEd25519Key(t)
+
+	oldKeySet, err := NewKeySet(config.JWT{
+		Issuer:   "iss",
+		Audience: "aud",
+		Keys:     []config.JWTKey{{ID: "old", Algorithm: "RS256", KeyFile: rsaPrivate}},
+	}, config.EnvDevelopment)
+	if err != nil {
+		t.Fatalf("failed to create key set: %v", err)
+	}
+
+	oldToken, err := oldKeySet.Sign("session-id", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	// After rotation the old key is only kept for verification
+	rotated, err := NewKeySet(config.JWT{
+		Issuer:      "iss",
+		Audience:    "aud",
+		ActiveKeyID: "new",
+		Keys: []config.JWTKey{
+			{ID: "new", Algorithm: "EdDSA", KeyFile: edPrivate},
+			{ID: "old", Algorithm: "RS256", KeyFile: rsaPublic},
+		},
+	}, config.EnvDevelopment)
+	if err != nil {
+		t.Fatalf("failed to create rotated key set: %v", err)
+	}
+
+	if _, err := rotated.Validate(oldToken); err != nil {
+		t.Errorf("expected token signed by the retired key to validate, got %v", err)
+	}
+
+	newToken, err := rotated.Sign("session-id", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	if _, err := oldKeySet.Validate(newToken); err == nil {
+		t.Error("expected a key set without the new key to reject the token")
+	}
+
+	if len(rotated.PublicKeys()) != 2 {
+		t.Errorf("expected both keys to be published, got %d", len(rotated.PublicKeys()))
+	}
+
+	if _, err := NewKeySet(config.JWT{
+		ActiveKeyID: "old",
+		Keys:        []config.JWTKey{{ID: "old", Algorithm: "RS256", KeyFile: rsaPublic}},
+	}, config.EnvDevelopment); err == nil {
+		t.Error("expected a verify-only key to be refused as the active key")
+	}
+}
+
+func TestKeySet_RejectsForeignClaims(t *testing.T) {
+	key := config.JWTKey{ID: "hmac", Algorithm: "HS256", Secret: "a-long-enough-test-secret-value"}
+
+	issuer, _ := NewKeySet(config.JWT{Issuer: "other", Audience: "aud", Keys: []config.JWTKey{key}}, config.EnvDevelopment)
+	audience, _ := NewKeySet(config.JWT{Issuer: "iss", Audience: "other", Keys: []config.JWTKey{key}}, config.EnvDevelopment)
+	keySet, _ := NewKeySet(config.JWT{Issuer: "iss", Audience: "aud", Keys: []config.JWTKey{key}}, config.EnvDevelopment)
+
+	for name, signer := range map[string]*KeySet{"issuer": issuer, "audience": audience} {
+		token, err := signer.Sign("session-id", time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+
+		if _, err := keySet.Validate(token); err == nil {
+			t.Errorf("expected token with a foreign %s to be rejected", name)
+		}
+	}
+
+	if len(keySet.PublicKeys()) != 0 {
+		t.Error("expected HMAC secrets never to be published")
+	}
+}
+
+func TestNewKeySet_RequiresKeysOutsideDevelopment(t *testing.T) {
+	if _, err := NewKeySet(config.JWT{Issuer: "iss", Audience: "aud"}, "production"); err == nil {
+		t.Error("expected missing JWT_KEYS to be refused outside development")
+	}
+
+	keySet, err := NewKeySet(config.JWT{Issuer: "iss", Audience: "aud"}, config.EnvDevelopment)
+	if err != nil {
+		t.Fatalf("expected an ephemeral key in development, got %v", err)
+	}
+
+	token, err := keySet.Sign("user-1", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to sign with the ephemeral key: %v", err)
+	}
+
+	if _, err := keySet.Validate(token); err != nil {
+		t.Errorf("expected the ephemeral key to verify its own token, got %v", err)
+	}
+}
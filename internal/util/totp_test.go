@@ -0,0 +1,48 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B vectors (SHA1), truncated to six digits.
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if code != expected {
+			t.Errorf("at %d: expected %s, got %s", unix, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	now := time.Now()
+	step := TOTPStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := TOTPCode(secret, step+offset)
+		matched, ok := ValidateTOTP(secret, code, now)
+		if !ok || matched != step+offset {
+			t.Errorf("expected code of step offset %d to be accepted", offset)
+		}
+	}
+
+	code, _ := TOTPCode(secret, step+3)
+	if _, ok := ValidateTOTP(secret, code, now); ok {
+		t.Error("expected code far outside the skew window to be refused")
+	}
+}
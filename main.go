@@ -2,13 +2,25 @@ package main
 
 import (
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	// Import profiles name time zones, which must resolve even where the
+	// system has no zoneinfo, such as in a scratch container.
+	_ "time/tzdata"
+
+	"firstpersoncode/go-uploader/domain"
 	"firstpersoncode/go-uploader/internal/config"
 	"firstpersoncode/go-uploader/internal/middlewares"
+	"firstpersoncode/go-uploader/internal/modules/admin"
+	"firstpersoncode/go-uploader/internal/modules/apikey"
 	"firstpersoncode/go-uploader/internal/modules/auth"
 	"firstpersoncode/go-uploader/internal/modules/transaction"
+	"firstpersoncode/go-uploader/internal/notifiers"
 	"firstpersoncode/go-uploader/internal/repositories"
+	"firstpersoncode/go-uploader/internal/util"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,9 +29,24 @@ import (
 
 func main() {
 
-	app := fiber.New()
 	config := config.Get()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(config.Storage, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "promote" {
+		if err := runPromote(config.Storage, os.Args[2:]); err != nil {
+			log.Fatalf("promote: %v", err)
+		}
+		return
+	}
+
+	app := fiber.New()
+
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     config.App.AllowedOrigins,
 		AllowCredentials: true,
@@ -35,30 +62,110 @@ func main() {
 		return c.JSON(fiber.Map{"status": "ok"})
 	})
 
-	userRepo := repositories.NewUserRepository()
-	sessionRepo := repositories.NewSessionRepository()
-	transactionRepo := repositories.NewTransactionRepository()
-
-	sessionMiddleware := middlewares.NewSessionMiddleware(sessionRepo)
-
-	authService := auth.NewAuthService(userRepo, sessionRepo)
+	storage, err := repositories.OpenStorage(config.Storage)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", config.Storage.Driver, err)
+	}
+	defer storage.Close()
+
+	userRepo := storage.Users
+	sessionRepo := storage.Sessions
+	apiKeyRepo := storage.APIKeys
+	loginAttemptRepo := storage.LoginAttempts
+	authEventRepo := storage.AuthEvents
+	passwordResetRepo := storage.PasswordResets
+	transactionRepo := storage.Transactions
+	uploadRepo := storage.Uploads
+	importProfileRepo := storage.Profiles
+
+	sessionMiddleware := middlewares.NewSessionMiddleware(sessionRepo, apiKeyRepo, userRepo)
+	requireAccount := sessionMiddleware.RequireScope(domain.ScopeAccount)
+	requireRead := sessionMiddleware.RequireScope(domain.ScopeRead)
+	requireUpload := sessionMiddleware.RequireScope(domain.ScopeUpload)
+	requireWrite := sessionMiddleware.RequireScope(domain.ScopeWrite)
+	permissionMiddleware := middlewares.NewPermissionMiddleware(userRepo)
+
+	authService := auth.NewAuthService(userRepo, sessionRepo, loginAttemptRepo, authEventRepo, passwordResetRepo, notifiers.New(config.Password))
 	authHandler := auth.NewAuthHandler(authService)
 
+	if _, err := util.JWTPublicKeys(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	app.Get("/.well-known/jwks.json", authHandler.JWKS)
 	app.Post("/signup", authHandler.SignUp)
 	app.Post("/signin", authHandler.SignIn)
-	app.Post("/signout", authHandler.SignOut)
-	app.Get("/session", sessionMiddleware.Handle, authHandler.Session)
-
-	transactionService := transaction.NewTransactionService(transactionRepo)
+	app.Post("/signin/mfa", authHandler.SignInMFA)
+	// Signing out also takes the refresh token, for when the access token
+	// has expired.
+	app.Post("/signout", sessionMiddleware.Optional, authHandler.SignOut)
+	app.Post("/signout/all", sessionMiddleware.Optional, authHandler.SignOutAll)
+	app.Post("/refresh", authHandler.Refresh)
+	app.Get("/session", sessionMiddleware.Handle, requireAccount, authHandler.Session)
+	app.Get("/sessions", sessionMiddleware.Handle, requireAccount, authHandler.ListSessions)
+	app.Delete("/sessions/:id", sessionMiddleware.Handle, requireAccount, authHandler.DeleteSession)
+	app.Post("/mfa/setup", sessionMiddleware.Handle, requireAccount, authHandler.SetupMFA)
+	app.Post("/mfa/verify", sessionMiddleware.Handle, requireAccount, authHandler.VerifyMFA)
+	app.Post("/password/change", sessionMiddleware.Handle, requireAccount, authHandler.ChangePassword)
+	app.Post("/password/reset/request", authHandler.RequestPasswordReset)
+	app.Post("/password/reset/confirm", authHandler.ConfirmPasswordReset)
+
+	apiKeyService := apikey.NewAPIKeyService(apiKeyRepo)
+	apiKeyHandler := apikey.NewAPIKeyHandler(apiKeyService)
+
+	app.Post("/api-keys", sessionMiddleware.Handle, requireAccount, apiKeyHandler.Create)
+	app.Get("/api-keys", sessionMiddleware.Handle, requireAccount, apiKeyHandler.List)
+	app.Delete("/api-keys/:id", sessionMiddleware.Handle, requireAccount, apiKeyHandler.Revoke)
+
+	cursorSigner, err := util.LoadCursorSigner(config.App)
+	if err != nil {
+		log.Fatalf("Failed to load the cursor secret: %v", err)
+	}
+
+	transactionService := transaction.NewTransactionService(transactionRepo, uploadRepo, importProfileRepo, cursorSigner)
 	transactionHandler := transaction.NewTransactionHandler(transactionService)
 
-	app.Post("/upload", sessionMiddleware.Handle, transactionHandler.UploadStatement)
-	app.Get("/balance", sessionMiddleware.Handle, transactionHandler.GetBalance)
-	app.Get("/issues", sessionMiddleware.Handle, transactionHandler.GetIssues)
+	app.Post("/upload", sessionMiddleware.Handle, requireUpload, transactionHandler.UploadStatement)
+	app.Get("/balance", sessionMiddleware.Handle, requireRead, transactionHandler.GetBalance)
+	app.Get("/issues", sessionMiddleware.Handle, requireRead, transactionHandler.GetIssues)
+	app.Get("/transactions", sessionMiddleware.Handle, requireRead, transactionHandler.SearchTransactions)
+	app.Get("/transactions/:id", sessionMiddleware.Handle, requireRead, transactionHandler.GetTransaction)
+	app.Patch("/transactions/:id", sessionMiddleware.Handle, requireWrite, transactionHandler.UpdateTransaction)
+	app.Delete("/transactions/:id", sessionMiddleware.Handle, requireWrite, transactionHandler.DeleteTransaction)
+	app.Get("/uploads", sessionMiddleware.Handle, requireRead, transactionHandler.ListUploads)
+	app.Get("/uploads/:id", sessionMiddleware.Handle, requireRead, transactionHandler.GetUpload)
+	app.Delete("/uploads/:id", sessionMiddleware.Handle, requireWrite, transactionHandler.DeleteUpload)
+	app.Get("/import-profiles", sessionMiddleware.Handle, requireRead, transactionHandler.ListImportProfiles)
+	app.Post("/import-profiles", sessionMiddleware.Handle, requireWrite, transactionHandler.CreateImportProfile)
+	app.Get("/import-profiles/:id", sessionMiddleware.Handle, requireRead, transactionHandler.GetImportProfile)
+	app.Put("/import-profiles/:id", sessionMiddleware.Handle, requireWrite, transactionHandler.UpdateImportProfile)
+	app.Delete("/import-profiles/:id", sessionMiddleware.Handle, requireWrite, transactionHandler.DeleteImportProfile)
+
+	adminService := admin.NewAdminService(userRepo, sessionRepo, transactionRepo, transactionService)
+	adminHandler := admin.NewAdminHandler(adminService)
+
+	app.Get("/admin/users", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionUsersRead), adminHandler.ListUsers)
+	app.Get("/admin/users/:id", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionUsersRead), adminHandler.GetUser)
+	app.Post("/admin/users/:id/disable", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionUsersManage), adminHandler.DisableUser)
+	app.Post("/admin/users/:id/enable", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionUsersManage), adminHandler.EnableUser)
+	app.Put("/admin/users/:id/role", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionUsersManage), adminHandler.UpdateRole)
+	app.Delete("/admin/users/:id/sessions", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionSessionsRevoke), adminHandler.RevokeSessions)
+	app.Get("/admin/users/:id/transactions", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionTransactionsRead), adminHandler.GetUserTransactions)
+	app.Get("/admin/users/:id/balance", sessionMiddleware.Handle, requireAccount, permissionMiddleware.Require(domain.PermissionTransactionsRead), adminHandler.GetUserBalance)
 
 	host := config.Server.Host
 	port := config.Server.Port
 
+	// Shut down cleanly on SIGINT/SIGTERM so the deferred storage.Close runs.
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		app.Shutdown()
+	}()
+
 	log.Printf("Server running on %s:%s", host, port)
-	log.Fatal(app.Listen(host + ":" + port))
+	if err := app.Listen(host + ":" + port); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"firstpersoncode/go-uploader/domain"
+	"firstpersoncode/go-uploader/internal/config"
+	"firstpersoncode/go-uploader/internal/repositories"
+)
+
+const promoteUsage = "usage: promote <username>..."
+
+// runPromote implements the "promote" subcommand, which gives existing users
+// the admin role. Signing up never does, or anyone could claim a username
+// meant for an admin.
+func runPromote(cfg config.Storage, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(promoteUsage)
+	}
+
+	if (cfg.Driver == "" || cfg.Driver == repositories.StorageDriverMemory) && cfg.JournalDir == "" {
+		return fmt.Errorf("the memory storage driver keeps nothing between runs, set STORAGE_JOURNAL_DIR or use the %s driver", repositories.StorageDriverSQLite)
+	}
+
+	storage, err := repositories.OpenStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	for _, username := range args {
+		user, err := storage.Users.FindByUsername(username)
+		if err != nil {
+			return fmt.Errorf("user %q not found", username)
+		}
+
+		if user.Role == domain.RoleAdmin {
+			fmt.Printf("%s is already an admin\n", username)
+			continue
+		}
+
+		updated := *user
+		updated.Role = domain.RoleAdmin
+		if _, err := storage.Users.Update(&updated); err != nil {
+			return fmt.Errorf("failed to promote %s: %v", username, err)
+		}
+		fmt.Printf("promoted %s\n", username)
+	}
+
+	return nil
+}